@@ -0,0 +1,65 @@
+package logdot
+
+import "context"
+
+// Attr is a typed tag key/value pair for LogAttrs
+type Attr struct {
+	Key   string
+	Value interface{}
+}
+
+// String returns an Attr for a string value
+func String(key, value string) Attr {
+	return Attr{Key: key, Value: value}
+}
+
+// Int returns an Attr for an int value
+func Int(key string, value int) Attr {
+	return Attr{Key: key, Value: value}
+}
+
+// Bool returns an Attr for a bool value
+func Bool(key string, value bool) Attr {
+	return Attr{Key: key, Value: value}
+}
+
+// Float returns an Attr for a float64 value
+func Float(key string, value float64) Attr {
+	return Attr{Key: key, Value: value}
+}
+
+// LogAttrs sends a log entry at the specified level with tags given as
+// Attrs instead of a map. Later attrs override earlier ones and the
+// logger's context.
+//
+// Example:
+//
+//	logger.LogAttrs(ctx, logdot.LevelInfo, "order placed",
+//		logdot.String("order_id", id),
+//		logdot.Int("items", len(items)),
+//		logdot.Float("total", total),
+//	)
+func (l *Logger) LogAttrs(ctx context.Context, level LogLevel, message string, attrs ...Attr) error {
+	if !l.EnabledContext(ctx, level) {
+		return nil
+	}
+
+	var tags map[string]interface{}
+	if len(attrs) == 0 {
+		tags, _ = l.mergeTags(nil)
+	} else {
+		tags = make(map[string]interface{}, len(l.logCtx)+len(attrs))
+		for k, v := range l.logCtx {
+			tags[k] = v
+		}
+		for _, attr := range attrs {
+			tags[attr.Key], _ = normalizeNumber(attr.Value)
+		}
+	}
+
+	return l.dispatch(ctx, LogEntry{
+		Message: message,
+		Level:   level,
+		Tags:    tags,
+	}, false)
+}
@@ -0,0 +1,38 @@
+package logdot
+
+import (
+	"context"
+	"testing"
+)
+
+func TestLogAttrs(t *testing.T) {
+	logger := NewLogger("test_key", "test-service").WithContext(map[string]interface{}{"service": "api"})
+	logger.BeginBatch()
+
+	logger.LogAttrs(context.Background(), LevelInfo, "order placed",
+		String("order_id", "A-1"),
+		Int("items", 3),
+		Bool("gift", true),
+		Float("total", 19.99),
+	)
+
+	if logger.BatchSize() != 1 {
+		t.Fatalf("expected 1 log entry, got %d", logger.BatchSize())
+	}
+	tags := logger.batchQueue[0].Tags
+	if tags["service"] != "api" || tags["order_id"] != "A-1" || tags["items"] != 3 ||
+		tags["gift"] != true || tags["total"] != 19.99 {
+		t.Errorf("unexpected tags: %v", tags)
+	}
+}
+
+func TestLogAttrsRespectsMinLevel(t *testing.T) {
+	logger := NewLogger("test_key", "test-service", WithLoggerMinLevel(LevelWarn))
+	logger.BeginBatch()
+
+	logger.LogAttrs(context.Background(), LevelDebug, "noisy", String("k", "v"))
+
+	if logger.BatchSize() != 0 {
+		t.Errorf("expected debug entry to be dropped, got %d", logger.BatchSize())
+	}
+}
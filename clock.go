@@ -0,0 +1,18 @@
+package logdot
+
+import "time"
+
+// Clock is the time source used by the SDK for retry backoff and any
+// other time-based behaviour. Replace it in tests to make timing
+// deterministic.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// realClock is the default Clock backed by the time package
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
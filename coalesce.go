@@ -0,0 +1,63 @@
+package logdot
+
+import (
+	"sort"
+	"strings"
+)
+
+// CoalesceMode selects how WithMetricsCoalesce combines identical metrics
+type CoalesceMode int
+
+const (
+	// CoalesceOff sends every metric as added
+	CoalesceOff CoalesceMode = iota
+	// CoalesceSum sends the sum of the values
+	CoalesceSum
+	// CoalesceLast sends the last value added
+	CoalesceLast
+)
+
+// String returns the mode's name
+func (m CoalesceMode) String() string {
+	switch m {
+	case CoalesceOff:
+		return "off"
+	case CoalesceSum:
+		return "sum"
+	case CoalesceLast:
+		return "last"
+	default:
+		return "unknown"
+	}
+}
+
+// coalesceMetrics combines entries with the same name, unit and tags (in
+// any order), keeping the position of the first occurrence. The input is
+// not modified.
+func coalesceMetrics(entries []MetricEntry, mode CoalesceMode) []MetricEntry {
+	index := make(map[string]int, len(entries))
+	coalesced := make([]MetricEntry, 0, len(entries))
+	for _, entry := range entries {
+		key := metricKey(entry)
+		i, seen := index[key]
+		if !seen {
+			index[key] = len(coalesced)
+			coalesced = append(coalesced, entry)
+			continue
+		}
+		if mode == CoalesceSum {
+			coalesced[i].Value += entry.Value
+		} else {
+			coalesced[i].Value = entry.Value
+		}
+	}
+	return coalesced
+}
+
+// metricKey identifies a metric by name, unit and sorted tags
+func metricKey(entry MetricEntry) string {
+	tags := make([]string, len(entry.Tags))
+	copy(tags, entry.Tags)
+	sort.Strings(tags)
+	return entry.Name + "\x00" + entry.Unit + "\x00" + strings.Join(tags, "\x00")
+}
@@ -0,0 +1,77 @@
+package logdot
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCoalesceMetrics(t *testing.T) {
+	entries := []MetricEntry{
+		{Name: "requests", Value: 1, Unit: "count", Tags: []string{"route:/a", "method:GET"}},
+		{Name: "latency", Value: 10, Unit: "ms"},
+		{Name: "requests", Value: 2, Unit: "count", Tags: []string{"method:GET", "route:/a"}},
+		{Name: "requests", Value: 4, Unit: "count", Tags: []string{"route:/b"}},
+		{Name: "latency", Value: 30, Unit: "ms"},
+	}
+
+	sum := coalesceMetrics(entries, CoalesceSum)
+	if len(sum) != 3 {
+		t.Fatalf("Expected 3 coalesced metrics, got %d", len(sum))
+	}
+	if sum[0].Value != 3 || sum[1].Value != 40 || sum[2].Value != 4 {
+		t.Errorf("Expected sums 3, 40, 4 in first-seen order, got %+v", sum)
+	}
+
+	last := coalesceMetrics(entries, CoalesceLast)
+	if last[0].Value != 2 || last[1].Value != 30 || last[2].Value != 4 {
+		t.Errorf("Expected last values 2, 30, 4, got %+v", last)
+	}
+	if entries[0].Value != 1 {
+		t.Error("Expected the input to be left untouched")
+	}
+}
+
+func TestBoundMetricsCoalesce(t *testing.T) {
+	var payloads []BatchMetricsPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload BatchMetricsPayload
+		json.NewDecoder(r.Body).Decode(&payload)
+		payloads = append(payloads, payload)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	metrics := NewMetrics("test_key", WithMetricsCoalesce(CoalesceSum))
+	redirectToServer(metrics.http, server)
+	client := metrics.ForEntity("entity-1")
+	ctx := context.Background()
+
+	client.BeginMultiBatch()
+	client.AddMetric("jobs.done", 1, "count", nil)
+	client.AddMetric("jobs.done", 1, "count", nil)
+	client.AddMetric("jobs.failed", 1, "count", nil)
+	if err := client.SendBatch(ctx); err != nil {
+		t.Fatalf("SendBatch failed: %v", err)
+	}
+
+	client.BeginBatch("temperature", "celsius")
+	client.Add(20, nil)
+	client.Add(20, nil)
+	if err := client.SendBatch(ctx); err != nil {
+		t.Fatalf("SendBatch failed: %v", err)
+	}
+
+	if len(payloads) != 2 {
+		t.Fatalf("Expected 2 requests, got %d", len(payloads))
+	}
+	multi := payloads[0].Metrics
+	if len(multi) != 2 || multi[0].Name != "jobs.done" || multi[0].Value != 2 {
+		t.Errorf("Expected coalesced multi-batch, got %+v", multi)
+	}
+	if len(payloads[1].Metrics) != 2 {
+		t.Errorf("Expected single-metric batch left as is, got %+v", payloads[1].Metrics)
+	}
+}
@@ -0,0 +1,163 @@
+package logdot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// configFile is the JSON layout read by NewLoggerFromConfigFile and
+// NewMetricsFromConfigFile. Durations use time.ParseDuration syntax
+// ("5s", "250ms"); omitted fields keep their defaults.
+type configFile struct {
+	APIKey         string   `json:"api_key"`
+	Hostname       string   `json:"hostname"`
+	Timeout        string   `json:"timeout"`
+	RetryAttempts  *int     `json:"retry_attempts"`
+	RetryBaseDelay string   `json:"retry_base_delay"`
+	RetryMaxDelay  string   `json:"retry_max_delay"`
+	Debug          bool     `json:"debug"`
+	MinLevel       LogLevel `json:"min_level"`
+	BatchID        bool     `json:"batch_id"`
+	DefaultUnit    string   `json:"default_unit"`
+
+	timeout, baseDelay, maxDelay time.Duration
+}
+
+// NewLoggerFromConfigFile creates a Logger from a JSON config file.
+// api_key is required; the other keys are optional:
+//
+//	{
+//	  "api_key": "ilog_live_YOUR_API_KEY",
+//	  "hostname": "my-service",
+//	  "timeout": "5s",
+//	  "retry_attempts": 3,
+//	  "retry_base_delay": "1s",
+//	  "retry_max_delay": "30s",
+//	  "debug": false,
+//	  "min_level": "info",
+//	  "batch_id": false
+//	}
+//
+// opts are applied after the file, so they take precedence. Unknown keys
+// and invalid values are reported as errors. YAML files are not supported.
+func NewLoggerFromConfigFile(path string, opts ...LoggerOption) (*Logger, error) {
+	cfg, err := loadConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fileOpts := []LoggerOption{
+		WithLoggerDebug(cfg.Debug),
+		WithLoggerBatchID(cfg.BatchID),
+	}
+	if cfg.Timeout != "" {
+		fileOpts = append(fileOpts, WithLoggerTimeout(cfg.timeout))
+	}
+	if cfg.MinLevel != "" {
+		fileOpts = append(fileOpts, WithLoggerMinLevel(cfg.MinLevel))
+	}
+	fileOpts = append(fileOpts, func(c *LoggerConfig) {
+		if cfg.RetryAttempts != nil {
+			c.RetryAttempts = *cfg.RetryAttempts
+		}
+		if cfg.RetryBaseDelay != "" {
+			c.RetryBaseDelay = cfg.baseDelay
+		}
+		if cfg.RetryMaxDelay != "" {
+			c.RetryMaxDelay = cfg.maxDelay
+		}
+	})
+
+	return NewLogger(cfg.APIKey, cfg.Hostname, append(fileOpts, opts...)...), nil
+}
+
+// NewMetricsFromConfigFile creates a Metrics client from a JSON config
+// file with the same layout as NewLoggerFromConfigFile. hostname,
+// min_level and batch_id are ignored; default_unit sets the unit used
+// when none is given.
+func NewMetricsFromConfigFile(path string, opts ...MetricsOption) (*Metrics, error) {
+	cfg, err := loadConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fileOpts := []MetricsOption{WithMetricsDebug(cfg.Debug)}
+	if cfg.Timeout != "" {
+		fileOpts = append(fileOpts, WithMetricsTimeout(cfg.timeout))
+	}
+	if cfg.DefaultUnit != "" {
+		fileOpts = append(fileOpts, WithMetricsDefaultUnit(cfg.DefaultUnit))
+	}
+	fileOpts = append(fileOpts, func(c *MetricsConfig) {
+		if cfg.RetryAttempts != nil {
+			c.RetryAttempts = *cfg.RetryAttempts
+		}
+		if cfg.RetryBaseDelay != "" {
+			c.RetryBaseDelay = cfg.baseDelay
+		}
+		if cfg.RetryMaxDelay != "" {
+			c.RetryMaxDelay = cfg.maxDelay
+		}
+	})
+
+	return NewMetrics(cfg.APIKey, append(fileOpts, opts...)...), nil
+}
+
+// loadConfigFile reads and validates a JSON config file
+func loadConfigFile(path string) (*configFile, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return nil, fmt.Errorf("config file %s: YAML is not supported, use JSON", path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	var cfg configFile
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("config file %s: invalid JSON: %w", path, err)
+	}
+
+	if cfg.APIKey == "" {
+		return nil, fmt.Errorf("config file %s: api_key is required", path)
+	}
+	if cfg.RetryAttempts != nil && *cfg.RetryAttempts < 1 {
+		return nil, fmt.Errorf("config file %s: retry_attempts must be at least 1, got %d", path, *cfg.RetryAttempts)
+	}
+	switch cfg.MinLevel {
+	case "", LevelDebug, LevelInfo, LevelWarn, LevelError:
+	default:
+		return nil, fmt.Errorf("config file %s: min_level must be debug, info, warn or error, got %q", path, cfg.MinLevel)
+	}
+
+	durations := []struct {
+		key   string
+		value string
+		dst   *time.Duration
+	}{
+		{"timeout", cfg.Timeout, &cfg.timeout},
+		{"retry_base_delay", cfg.RetryBaseDelay, &cfg.baseDelay},
+		{"retry_max_delay", cfg.RetryMaxDelay, &cfg.maxDelay},
+	}
+	for _, d := range durations {
+		if d.value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(d.value)
+		if err != nil || parsed < 0 {
+			return nil, fmt.Errorf("config file %s: %s must be a non-negative duration like \"5s\", got %q", path, d.key, d.value)
+		}
+		*d.dst = parsed
+	}
+
+	return &cfg, nil
+}
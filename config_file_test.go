@@ -0,0 +1,87 @@
+package logdot
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	return path
+}
+
+func TestNewLoggerFromConfigFile(t *testing.T) {
+	path := writeConfigFile(t, "logdot.json", `{
+		"api_key": "ilog_live_abcdef123456",
+		"hostname": "file-service",
+		"timeout": "2s",
+		"retry_attempts": 5,
+		"retry_base_delay": "250ms",
+		"min_level": "warn",
+		"batch_id": true
+	}`)
+
+	logger, err := NewLoggerFromConfigFile(path)
+	if err != nil {
+		t.Fatalf("NewLoggerFromConfigFile failed: %v", err)
+	}
+
+	cfg := logger.Config()
+	if logger.Hostname() != "file-service" {
+		t.Errorf("expected hostname file-service, got %s", logger.Hostname())
+	}
+	if cfg.Timeout != 2*time.Second || cfg.RetryAttempts != 5 || cfg.RetryBaseDelay != 250*time.Millisecond {
+		t.Errorf("unexpected timing config: %+v", cfg)
+	}
+	if cfg.RetryMaxDelay != 30*time.Second {
+		t.Errorf("expected default max delay, got %v", cfg.RetryMaxDelay)
+	}
+	if cfg.MinLevel != LevelWarn || !cfg.BatchID {
+		t.Errorf("unexpected level/batch config: %+v", cfg)
+	}
+}
+
+func TestNewMetricsFromConfigFile(t *testing.T) {
+	path := writeConfigFile(t, "logdot.json", `{"api_key": "ilog_live_abcdef123456", "default_unit": "ms"}`)
+
+	metrics, err := NewMetricsFromConfigFile(path)
+	if err != nil {
+		t.Fatalf("NewMetricsFromConfigFile failed: %v", err)
+	}
+	if metrics.Config().DefaultUnit != "ms" {
+		t.Errorf("expected default unit ms, got %q", metrics.Config().DefaultUnit)
+	}
+}
+
+func TestConfigFileErrors(t *testing.T) {
+	cases := map[string]struct {
+		name    string
+		content string
+		want    string
+	}{
+		"missing key":   {"a.json", `{"hostname": "x"}`, "api_key is required"},
+		"unknown field": {"a.json", `{"api_key": "k", "apikey": "k"}`, "unknown field"},
+		"bad duration":  {"a.json", `{"api_key": "k", "timeout": "5"}`, "timeout must be"},
+		"bad level":     {"a.json", `{"api_key": "k", "min_level": "trace"}`, "min_level must be"},
+		"bad attempts":  {"a.json", `{"api_key": "k", "retry_attempts": 0}`, "retry_attempts"},
+		"yaml":          {"a.yaml", `api_key: k`, "YAML is not supported"},
+	}
+	for name, tc := range cases {
+		path := writeConfigFile(t, tc.name, tc.content)
+		_, err := NewLoggerFromConfigFile(path)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: expected error containing %q, got %v", name, tc.want, err)
+		}
+	}
+
+	if _, err := NewLoggerFromConfigFile(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
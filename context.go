@@ -0,0 +1,165 @@
+package logdot
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// noTags is the sentinel returned by NoTags
+var noTags = map[string]interface{}{}
+
+// NoTags returns a value that, passed as the tags of a log or metric call,
+// sends that entry without any tags: the logger's context, context
+// extractor tags and metric default tags are not applied, and log entries
+// carry an explicit empty "tags" object. The returned map is shared and
+// must not be modified.
+//
+// Example:
+//
+//	reqLogger.Info(ctx, "heartbeat", logdot.NoTags())
+func NoTags() map[string]interface{} {
+	return noTags
+}
+
+// isNoTags reports whether tags is the NoTags sentinel itself, as opposed
+// to any other empty map
+func isNoTags(tags map[string]interface{}) bool {
+	return tags != nil && len(tags) == 0 &&
+		reflect.ValueOf(tags).Pointer() == reflect.ValueOf(noTags).Pointer()
+}
+
+type levelContextKey struct{}
+
+type loggerContextKey struct{}
+
+// NewContext returns a context carrying logger, for retrieval with
+// FromContext further down the call chain
+func NewContext(ctx context.Context, logger *Logger) context.Context {
+	return context.WithValue(ctx, loggerContextKey{}, logger)
+}
+
+// FromContext returns the logger stored with NewContext, or nil. Inside
+// handlers wrapped by Middleware it returns a logger tagged with the
+// request's request_id, http_method and http_path.
+//
+// Example:
+//
+//	func handler(w http.ResponseWriter, r *http.Request) {
+//		logdot.FromContext(r.Context()).Info(r.Context(), "loading cart", nil)
+//	}
+func FromContext(ctx context.Context) *Logger {
+	if ctx == nil {
+		return nil
+	}
+	logger, _ := ctx.Value(loggerContextKey{}).(*Logger)
+	return logger
+}
+
+// WithLevel returns a context that overrides the logger's minimum level for
+// logs made with it. Use it to capture debug logs for a single request
+// without lowering the global level.
+//
+// Example:
+//
+//	ctx = logdot.WithLevel(ctx, logdot.LevelDebug)
+//	logger.Debug(ctx, "Sent even though the logger's minimum level is info", nil)
+func WithLevel(ctx context.Context, level LogLevel) context.Context {
+	return context.WithValue(ctx, levelContextKey{}, level)
+}
+
+// levelFromContext returns the level override stored by WithLevel, if any
+func levelFromContext(ctx context.Context) (LogLevel, bool) {
+	if ctx == nil {
+		return "", false
+	}
+	level, ok := ctx.Value(levelContextKey{}).(LogLevel)
+	return level, ok
+}
+
+type sampledContextKey struct{}
+
+// WithSampled returns a context recording whether the work it carries is
+// sampled in. Logs made with a sampled-out context are dropped, and
+// Middleware follows the decision instead of drawing its own, so a
+// request's logs and metrics are kept or dropped together. Middleware with
+// a SampleRate stores its decision this way.
+//
+// Example:
+//
+//	ctx = logdot.WithSampled(ctx, traceSampled)
+//	logger.Info(ctx, "dropped if traceSampled is false", nil)
+func WithSampled(ctx context.Context, sampled bool) context.Context {
+	return context.WithValue(ctx, sampledContextKey{}, sampled)
+}
+
+// sampledFromContext returns the decision stored by WithSampled, if any
+func sampledFromContext(ctx context.Context) (sampled, ok bool) {
+	if ctx == nil {
+		return false, false
+	}
+	sampled, ok = ctx.Value(sampledContextKey{}).(bool)
+	return sampled, ok
+}
+
+// ContextExtractor returns tags derived from a log call's context, such
+// as trace IDs or OTel baggage (see the logdototel package). It is called
+// on every log call, so it should be cheap and return nil when there is
+// nothing to add.
+type ContextExtractor func(ctx context.Context) map[string]interface{}
+
+// addExtractedTags returns tags plus the extracted tags whose keys it
+// doesn't already have. tags is not modified.
+func addExtractedTags(tags, extracted map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(tags)+len(extracted))
+	for k, v := range extracted {
+		result[k], _ = normalizeNumber(v)
+	}
+	for k, v := range tags {
+		result[k] = v
+	}
+	return result
+}
+
+// contextKeysExtractor returns a ContextExtractor that adds the non-nil
+// values of keys, each tagged with the key's fmt.Sprint form
+func contextKeysExtractor(keys []interface{}) ContextExtractor {
+	names := make([]string, len(keys))
+	for i, k := range keys {
+		names[i] = fmt.Sprint(k)
+	}
+
+	return func(ctx context.Context) map[string]interface{} {
+		var tags map[string]interface{}
+		for i, k := range keys {
+			if v := ctx.Value(k); v != nil {
+				if tags == nil {
+					tags = make(map[string]interface{}, len(keys))
+				}
+				tags[names[i]] = v
+			}
+		}
+		return tags
+	}
+}
+
+// chainExtractors returns an extractor combining first and second, with
+// second winning on conflicts. Either may be nil.
+func chainExtractors(first, second ContextExtractor) ContextExtractor {
+	if first == nil {
+		return second
+	}
+	if second == nil {
+		return first
+	}
+	return func(ctx context.Context) map[string]interface{} {
+		a, b := first(ctx), second(ctx)
+		if len(a) == 0 {
+			return b
+		}
+		if len(b) == 0 {
+			return a
+		}
+		return addExtractedTags(b, a)
+	}
+}
@@ -0,0 +1,56 @@
+package logdot
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// defaultLogger is the logger used by the package-level logging functions
+var defaultLogger atomic.Pointer[Logger]
+
+// SetDefault makes logger the default logger used by the package-level
+// Log, Debug, Info, Warn and Error functions. Passing nil unsets it. It is
+// safe to call concurrently with logging.
+//
+// Example:
+//
+//	logdot.SetDefault(logdot.NewLogger(apiKey, "my-script"))
+//	logdot.Info(ctx, "Started", nil)
+func SetDefault(logger *Logger) {
+	defaultLogger.Store(logger)
+}
+
+// Default returns the default logger, or nil if none is set
+func Default() *Logger {
+	return defaultLogger.Load()
+}
+
+// Log logs at the given level with the default logger. It does nothing
+// and returns nil when no default logger is set.
+func Log(ctx context.Context, level LogLevel, message string, tags map[string]interface{}) error {
+	logger := defaultLogger.Load()
+	if logger == nil {
+		return nil
+	}
+	return logger.Log(ctx, level, message, tags)
+}
+
+// Debug logs a debug message with the default logger, if one is set
+func Debug(ctx context.Context, message string, tags map[string]interface{}) error {
+	return Log(ctx, LevelDebug, message, tags)
+}
+
+// Info logs an info message with the default logger, if one is set
+func Info(ctx context.Context, message string, tags map[string]interface{}) error {
+	return Log(ctx, LevelInfo, message, tags)
+}
+
+// Warn logs a warning message with the default logger, if one is set
+func Warn(ctx context.Context, message string, tags map[string]interface{}) error {
+	return Log(ctx, LevelWarn, message, tags)
+}
+
+// Error logs an error message with the default logger, if one is set
+func Error(ctx context.Context, message string, tags map[string]interface{}) error {
+	return Log(ctx, LevelError, message, tags)
+}
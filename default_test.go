@@ -0,0 +1,44 @@
+package logdot
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDefaultLogger(t *testing.T) {
+	defer SetDefault(nil)
+	ctx := context.Background()
+
+	SetDefault(nil)
+	if Default() != nil {
+		t.Fatal("Expected no default logger")
+	}
+	if err := Info(ctx, "dropped", nil); err != nil {
+		t.Errorf("Expected no-op without a default logger, got %v", err)
+	}
+
+	logger := NewLogger("test_api_key", "test-service")
+	logger.BeginBatch()
+	SetDefault(logger)
+	if Default() != logger {
+		t.Fatal("Expected Default to return the logger that was set")
+	}
+
+	Debug(ctx, "debug", nil)
+	Info(ctx, "info", map[string]interface{}{"step": 1})
+	Warn(ctx, "warn", nil)
+	Error(ctx, "error", nil)
+
+	want := []LogLevel{LevelDebug, LevelInfo, LevelWarn, LevelError}
+	if logger.BatchSize() != len(want) {
+		t.Fatalf("Expected %d entries, got %d", len(want), logger.BatchSize())
+	}
+	for i, level := range want {
+		if logger.batchQueue[i].Level != level {
+			t.Errorf("Expected entry %d at %s, got %s", i, level, logger.batchQueue[i].Level)
+		}
+	}
+	if logger.batchQueue[1].Tags["step"] != 1 {
+		t.Errorf("Expected tags forwarded, got %v", logger.batchQueue[1].Tags)
+	}
+}
@@ -0,0 +1,94 @@
+package logdot
+
+import "os"
+
+// EnvDetector names one group of tags attached by WithLoggerAutoEnv
+type EnvDetector string
+
+const (
+	// EnvDetectorEnv sets env from ENV, APP_ENV, ENVIRONMENT, GO_ENV or
+	// DEPLOY_ENV (the first one set)
+	EnvDetectorEnv EnvDetector = "env"
+
+	// EnvDetectorKubernetes sets pod from POD_NAME and namespace from
+	// POD_NAMESPACE or NAMESPACE, as exposed through the downward API
+	EnvDetectorKubernetes EnvDetector = "kubernetes"
+
+	// EnvDetectorCloud sets cloud_provider and cloud_region from the
+	// variables AWS, Google Cloud, Azure App Service and Fly.io set. The
+	// metadata endpoints are not queried.
+	EnvDetectorCloud EnvDetector = "cloud"
+)
+
+// envDetectors lists the detectors in the order they run
+var envDetectors = []struct {
+	name   EnvDetector
+	detect func(tags map[string]interface{})
+}{
+	{EnvDetectorEnv, detectEnvName},
+	{EnvDetectorKubernetes, detectKubernetes},
+	{EnvDetectorCloud, detectCloud},
+}
+
+// detectEnvTags runs every detector not in skip and returns the tags found
+func detectEnvTags(skip []EnvDetector) map[string]interface{} {
+	tags := make(map[string]interface{})
+	for _, d := range envDetectors {
+		if !containsDetector(skip, d.name) {
+			d.detect(tags)
+		}
+	}
+	return tags
+}
+
+func containsDetector(list []EnvDetector, name EnvDetector) bool {
+	for _, d := range list {
+		if d == name {
+			return true
+		}
+	}
+	return false
+}
+
+// firstEnv returns the value of the first set, non-empty variable
+func firstEnv(keys ...string) string {
+	for _, key := range keys {
+		if v := os.Getenv(key); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// setEnvTag sets tags[key] to the first non-empty variable, if any
+func setEnvTag(tags map[string]interface{}, key string, vars ...string) {
+	if v := firstEnv(vars...); v != "" {
+		tags[key] = v
+	}
+}
+
+func detectEnvName(tags map[string]interface{}) {
+	setEnvTag(tags, "env", "ENV", "APP_ENV", "ENVIRONMENT", "GO_ENV", "DEPLOY_ENV")
+}
+
+func detectKubernetes(tags map[string]interface{}) {
+	setEnvTag(tags, "pod", "POD_NAME")
+	setEnvTag(tags, "namespace", "POD_NAMESPACE", "NAMESPACE")
+}
+
+func detectCloud(tags map[string]interface{}) {
+	switch {
+	case firstEnv("AWS_REGION", "AWS_DEFAULT_REGION", "AWS_EXECUTION_ENV") != "":
+		tags["cloud_provider"] = "aws"
+		setEnvTag(tags, "cloud_region", "AWS_REGION", "AWS_DEFAULT_REGION")
+	case firstEnv("GOOGLE_CLOUD_PROJECT", "GCP_PROJECT", "K_SERVICE") != "":
+		tags["cloud_provider"] = "gcp"
+		setEnvTag(tags, "cloud_region", "GOOGLE_CLOUD_REGION", "FUNCTION_REGION")
+	case firstEnv("WEBSITE_SITE_NAME") != "":
+		tags["cloud_provider"] = "azure"
+		setEnvTag(tags, "cloud_region", "REGION_NAME")
+	case firstEnv("FLY_APP_NAME") != "":
+		tags["cloud_provider"] = "fly"
+		setEnvTag(tags, "cloud_region", "FLY_REGION")
+	}
+}
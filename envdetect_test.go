@@ -0,0 +1,69 @@
+package logdot
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// clearEnvDetection unsets every variable the detectors read
+func clearEnvDetection(t *testing.T) {
+	for _, key := range []string{
+		"ENV", "APP_ENV", "ENVIRONMENT", "GO_ENV", "DEPLOY_ENV",
+		"POD_NAME", "POD_NAMESPACE", "NAMESPACE",
+		"AWS_REGION", "AWS_DEFAULT_REGION", "AWS_EXECUTION_ENV",
+		"GOOGLE_CLOUD_PROJECT", "GCP_PROJECT", "K_SERVICE", "GOOGLE_CLOUD_REGION", "FUNCTION_REGION",
+		"WEBSITE_SITE_NAME", "REGION_NAME", "FLY_APP_NAME", "FLY_REGION",
+	} {
+		t.Setenv(key, "")
+	}
+}
+
+func TestDetectEnvTags(t *testing.T) {
+	clearEnvDetection(t)
+	t.Setenv("APP_ENV", "staging")
+	t.Setenv("POD_NAME", "api-7f9c")
+	t.Setenv("NAMESPACE", "payments")
+	t.Setenv("AWS_REGION", "eu-west-1")
+
+	want := map[string]interface{}{
+		"env":            "staging",
+		"pod":            "api-7f9c",
+		"namespace":      "payments",
+		"cloud_provider": "aws",
+		"cloud_region":   "eu-west-1",
+	}
+	if got := detectEnvTags(nil); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	got := detectEnvTags([]EnvDetector{EnvDetectorKubernetes, EnvDetectorCloud})
+	if !reflect.DeepEqual(got, map[string]interface{}{"env": "staging"}) {
+		t.Errorf("Expected only env with detectors skipped, got %v", got)
+	}
+}
+
+func TestLoggerAutoEnv(t *testing.T) {
+	clearEnvDetection(t)
+	t.Setenv("ENV", "production")
+
+	off := NewLogger("test_api_key", "test-service")
+	if len(off.GetContext()) != 0 {
+		t.Errorf("Expected no tags without AutoEnv, got %v", off.GetContext())
+	}
+
+	logger := NewLogger("test_api_key", "test-service",
+		WithLoggerAutoEnv(true),
+		WithLoggerAutoEnvSkip(EnvDetectorCloud))
+	logger.BeginBatch()
+	logger.Info(context.Background(), "started", nil)
+
+	if got := logger.batchQueue[0].Tags["env"]; got != "production" {
+		t.Errorf("Expected env tag production, got %v", got)
+	}
+
+	derived := logger.WithContext(map[string]interface{}{"env": "canary"})
+	if got := derived.GetContext()["env"]; got != "canary" {
+		t.Errorf("Expected WithContext to override env, got %v", got)
+	}
+}
@@ -0,0 +1,40 @@
+package logdot
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrorClassifier picks the level for an error logged with LogErrWithLevel.
+// It returns ok=false for errors it doesn't recognize.
+type ErrorClassifier func(err error) (level LogLevel, ok bool)
+
+// classifyError returns the level of the first classifier that recognizes
+// err. Unrecognized errors are logged at error level, except context
+// cancellation, which is info.
+func classifyError(classifiers []ErrorClassifier, err error) LogLevel {
+	for _, classify := range classifiers {
+		if level, ok := classify(err); ok {
+			return level
+		}
+	}
+	if errors.Is(err, context.Canceled) {
+		return LevelInfo
+	}
+	return LevelError
+}
+
+// LogErrWithLevel logs err.Error() at the level chosen by the classifiers
+// registered with WithLoggerErrorClassifier. A nil err logs nothing.
+//
+// Example:
+//
+//	if err := svc.Checkout(ctx, cart); err != nil {
+//		logger.LogErrWithLevel(ctx, err, map[string]interface{}{"cart_id": cart.ID})
+//	}
+func (l *Logger) LogErrWithLevel(ctx context.Context, err error, tags map[string]interface{}) error {
+	if err == nil {
+		return nil
+	}
+	return l.Log(ctx, classifyError(l.errorClassifiers, err), err.Error(), tags)
+}
@@ -0,0 +1,53 @@
+package logdot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+type validationError struct{ field string }
+
+func (e *validationError) Error() string { return "invalid " + e.field }
+
+func TestLogErrWithLevel(t *testing.T) {
+	logger := NewLogger("test_api_key", "test-service",
+		WithLoggerErrorClassifier(func(err error) (LogLevel, bool) {
+			var verr *validationError
+			if errors.As(err, &verr) {
+				return LevelWarn, true
+			}
+			return "", false
+		}),
+	)
+	logger.BeginBatch()
+
+	ctx := context.Background()
+	logger.LogErrWithLevel(ctx, fmt.Errorf("checkout: %w", &validationError{field: "email"}), map[string]interface{}{"cart_id": "c1"})
+	logger.LogErrWithLevel(ctx, fmt.Errorf("checkout: %w", context.Canceled), nil)
+	logger.LogErrWithLevel(ctx, errors.New("db down"), nil)
+	logger.LogErrWithLevel(ctx, nil, nil)
+
+	if logger.BatchSize() != 3 {
+		t.Fatalf("Expected 3 entries, got %d", logger.BatchSize())
+	}
+	want := []LogLevel{LevelWarn, LevelInfo, LevelError}
+	for i, entry := range logger.batchQueue {
+		if entry.Level != want[i] {
+			t.Errorf("Entry %d: expected level %s, got %s", i, want[i], entry.Level)
+		}
+	}
+	if logger.batchQueue[0].Message != "checkout: invalid email" || logger.batchQueue[0].Tags["cart_id"] != "c1" {
+		t.Errorf("Expected error message and tags, got %+v", logger.batchQueue[0])
+	}
+}
+
+func TestClassifyErrorOrder(t *testing.T) {
+	first := func(error) (LogLevel, bool) { return LevelDebug, true }
+	second := func(error) (LogLevel, bool) { return LevelWarn, true }
+
+	if got := classifyError([]ErrorClassifier{first, second}, errors.New("x")); got != LevelDebug {
+		t.Errorf("Expected first classifier to win, got %s", got)
+	}
+}
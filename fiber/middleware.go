@@ -0,0 +1,111 @@
+// Package logdotfiber adapts LogDot's HTTP auto-instrumentation to the
+// Fiber web framework, which uses fasthttp instead of net/http.
+//
+// It lives in its own module so the core SDK stays free of third-party
+// dependencies.
+package logdotfiber
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	logdot "github.com/logdot-io/logdot-go"
+)
+
+// Middleware returns a Fiber handler that logs requests and sends duration
+// metrics to LogDot, like logdot.Middleware does for net/http.
+//
+// The http_path tag uses the matched route pattern (e.g. "/users/:id")
+// rather than the raw path, keeping metric cardinality low. IgnorePaths is
+// matched against the raw path. Request-wrapping options such as
+// CaptureBodyOnError are not supported.
+//
+// Example:
+//
+//	cfg := logdot.DefaultMiddlewareConfig()
+//	cfg.Logger = logger
+//	cfg.Metrics = metrics
+//
+//	app := fiber.New()
+//	app.Use(logdotfiber.Middleware(cfg))
+func Middleware(config logdot.MiddlewareConfig) fiber.Handler {
+	observer := logdot.NewRequestObserver(config)
+
+	return func(c *fiber.Ctx) error {
+		req, err := convertRequest(c)
+		if err != nil {
+			return c.Next()
+		}
+		if observer.Ignored(req) {
+			return c.Next()
+		}
+
+		start := time.Now()
+		err = c.Next()
+		duration := time.Since(start)
+
+		if path := c.Route().Path; path != "" {
+			req.URL.Path = path
+		}
+		observer.Observe(req, statusFor(c, err), duration)
+
+		return err
+	}
+}
+
+// convertRequest builds the net/http view of the request that the
+// observer needs. Unlike fasthttpadaptor.ConvertRequest it never touches
+// the body, which the adapter does not capture, and copies every string,
+// since fasthttp reuses its buffers once the handler returns while the
+// logged tags may still be queued.
+func convertRequest(c *fiber.Ctx) (*http.Request, error) {
+	ctx := c.Context()
+	requestURI := string(ctx.RequestURI())
+	u, err := url.ParseRequestURI(requestURI)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &http.Request{
+		Method:        string(ctx.Method()),
+		URL:           u,
+		Proto:         string(ctx.Request.Header.Protocol()),
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        make(http.Header),
+		Body:          http.NoBody,
+		ContentLength: int64(ctx.Request.Header.ContentLength()),
+		Host:          string(ctx.Host()),
+		RemoteAddr:    ctx.RemoteAddr().String(),
+		RequestURI:    requestURI,
+		TLS:           ctx.TLSConnectionState(),
+	}
+	if req.Proto == "HTTP/2" {
+		req.ProtoMajor = 2
+	}
+	if req.ContentLength < 0 {
+		req.ContentLength = 0
+	}
+	ctx.Request.Header.VisitAll(func(k, v []byte) {
+		req.Header.Add(string(k), string(v))
+	})
+	return req, nil
+}
+
+// statusFor returns the status the client will receive. When a handler
+// returns an error, Fiber's error handler sets the status after the
+// middleware chain unwinds, so it is derived from the error instead.
+func statusFor(c *fiber.Ctx, err error) int {
+	if err == nil {
+		return c.Response().StatusCode()
+	}
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		return fiberErr.Code
+	}
+	return fiber.StatusInternalServerError
+}
@@ -0,0 +1,118 @@
+package logdotfiber
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	logdot "github.com/logdot-io/logdot-go"
+	"github.com/logdot-io/logdot-go/logdottest"
+)
+
+func newTestApp(t *testing.T, overrides ...func(*logdot.MiddlewareConfig)) (*fiber.App, *logdottest.Recorder) {
+	t.Helper()
+	rec := logdottest.NewRecorder()
+
+	cfg := logdot.DefaultMiddlewareConfig()
+	cfg.Logger = rec.NewLogger("test-service")
+	for _, fn := range overrides {
+		fn(&cfg)
+	}
+
+	app := fiber.New()
+	app.Use(Middleware(cfg))
+	app.Get("/users/:id", func(c *fiber.Ctx) error {
+		return c.SendString("ok")
+	})
+	app.Get("/missing", func(c *fiber.Ctx) error {
+		return fiber.ErrNotFound
+	})
+	app.Get("/health", func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+	return app, rec
+}
+
+func TestMiddlewareLogsRequest(t *testing.T) {
+	app, rec := newTestApp(t)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/users/42", nil))
+	if err != nil || resp.StatusCode != 200 {
+		t.Fatalf("unexpected response: %v %v", resp, err)
+	}
+
+	logs := rec.Logs()
+	if len(logs) != 1 {
+		t.Fatalf("expected 1 log entry, got %d", len(logs))
+	}
+	entry := logs[0]
+	if entry.Level != logdot.LevelInfo {
+		t.Errorf("expected level info, got %s", entry.Level)
+	}
+	if entry.Tags["http_method"] != "GET" {
+		t.Errorf("expected http_method GET, got %v", entry.Tags["http_method"])
+	}
+	if entry.Tags["http_path"] != "/users/:id" {
+		t.Errorf("expected route pattern as http_path, got %v", entry.Tags["http_path"])
+	}
+	if entry.Tags["http_status"] != float64(200) {
+		t.Errorf("expected http_status 200, got %v", entry.Tags["http_status"])
+	}
+}
+
+func TestMiddlewareUsesErrorStatus(t *testing.T) {
+	app, rec := newTestApp(t)
+
+	resp, _ := app.Test(httptest.NewRequest("GET", "/missing", nil))
+	if resp.StatusCode != 404 {
+		t.Fatalf("expected 404, got %d", resp.StatusCode)
+	}
+
+	logs := rec.Logs()
+	if len(logs) != 1 || logs[0].Level != logdot.LevelWarn {
+		t.Fatalf("expected one warn log, got %+v", logs)
+	}
+	if logs[0].Tags["http_status"] != float64(404) {
+		t.Errorf("expected http_status 404, got %v", logs[0].Tags["http_status"])
+	}
+}
+
+func TestMiddlewareSkipsIgnoredPaths(t *testing.T) {
+	app, rec := newTestApp(t, func(cfg *logdot.MiddlewareConfig) {
+		cfg.IgnorePaths = []string{"/health"}
+	})
+
+	app.Test(httptest.NewRequest("GET", "/health", nil))
+
+	if len(rec.Logs()) != 0 {
+		t.Errorf("expected no logs for ignored path, got %d", len(rec.Logs()))
+	}
+}
+
+func TestMiddlewareLeavesBodyToHandler(t *testing.T) {
+	app, rec := newTestApp(t)
+	app.Post("/users", func(c *fiber.Ctx) error {
+		return c.Send(c.Body())
+	})
+
+	req := httptest.NewRequest("POST", "/users?debug=1", strings.NewReader(`{"name":"ada"}`))
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != `{"name":"ada"}` {
+		t.Errorf("expected the handler to read the body, got %q", body)
+	}
+
+	logs := rec.Logs()
+	if len(logs) != 1 {
+		t.Fatalf("expected 1 log entry, got %d", len(logs))
+	}
+	if logs[0].Tags["http_method"] != "POST" || logs[0].Tags["http_path"] != "/users" || logs[0].Tags["request_bytes"] != float64(14) {
+		t.Errorf("unexpected tags: %v", logs[0].Tags)
+	}
+}
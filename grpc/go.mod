@@ -0,0 +1,19 @@
+module github.com/logdot-io/logdot-go/grpc
+
+go 1.21
+
+require (
+	github.com/logdot-io/logdot-go v1.0.0
+	google.golang.org/grpc v1.62.1
+)
+
+require (
+	github.com/golang/protobuf v1.5.3 // indirect
+	golang.org/x/net v0.20.0 // indirect
+	golang.org/x/sys v0.16.0 // indirect
+	golang.org/x/text v0.14.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20240123012728-ef4313101c80 // indirect
+	google.golang.org/protobuf v1.32.0 // indirect
+)
+
+replace github.com/logdot-io/logdot-go => ..
@@ -0,0 +1,243 @@
+// Package logdotgrpc instruments gRPC calls with LogDot logs and metrics.
+//
+// It lives in its own module so the core SDK stays free of third-party
+// dependencies.
+package logdotgrpc
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	logdot "github.com/logdot-io/logdot-go"
+)
+
+// InterceptorConfig configures the gRPC interceptors
+type InterceptorConfig struct {
+	// Logger receives one log entry per call when LogCalls is set
+	Logger *logdot.Logger
+
+	// Metrics is optional. When set together with LogMetrics, call
+	// duration metrics are sent to LogDot.
+	Metrics *logdot.Metrics
+
+	// EntityName is used for lazy entity resolution. Defaults to
+	// Logger.Hostname() when empty.
+	EntityName string
+
+	// LogCalls enables per-call log entries
+	LogCalls bool
+
+	// LogMetrics enables sending grpc.client.duration metrics
+	LogMetrics bool
+}
+
+// DefaultInterceptorConfig returns an InterceptorConfig with call logging
+// and metrics enabled. Logger and Metrics still need to be set by the
+// caller.
+func DefaultInterceptorConfig() InterceptorConfig {
+	return InterceptorConfig{
+		LogCalls:   true,
+		LogMetrics: true,
+	}
+}
+
+// UnaryClientInterceptor returns an interceptor that logs the method,
+// status code and duration of each outbound unary call, and sends a
+// grpc.client.duration metric tagged with method and code. Logging never
+// affects the call or its result: calls are handed to a background worker,
+// and the metrics entity is resolved in the background, so the RPC path
+// never waits on LogDot.
+//
+// Example:
+//
+//	cfg := logdotgrpc.DefaultInterceptorConfig()
+//	cfg.Logger = logger
+//	cfg.Metrics = metrics
+//
+//	conn, err := grpc.Dial(addr,
+//		grpc.WithUnaryInterceptor(logdotgrpc.UnaryClientInterceptor(cfg)),
+//	)
+func UnaryClientInterceptor(config InterceptorConfig) grpc.UnaryClientInterceptor {
+	return newInterceptorState(config).intercept
+}
+
+// callQueueSize bounds the calls waiting to be logged. When the queue is
+// full, further calls are dropped rather than slowing down the RPC path.
+const callQueueSize = 1024
+
+// Backoff between failed entity resolutions, doubling up to the cap
+const (
+	entityRetryBaseDelay = time.Second
+	entityRetryMaxDelay  = time.Minute
+)
+
+// call is a completed call waiting to be logged
+type call struct {
+	method   string
+	code     codes.Code
+	duration time.Duration
+}
+
+// interceptorState holds the shared state for an interceptor
+type interceptorState struct {
+	config     InterceptorConfig
+	entityName string
+
+	// calls feeds the worker that sends logs and metrics; pending counts
+	// calls queued but not yet sent.
+	calls   chan call
+	pending sync.WaitGroup
+
+	// boundMetrics is the resolved entity's client, nil until
+	// resolveEntity succeeds, which also closes entityReady.
+	// entityResolving guards the single resolution in flight;
+	// entityRetryAt and entityBackoff (under entityMu) space out attempts
+	// after failures.
+	boundMetrics    atomic.Pointer[logdot.BoundMetrics]
+	entityReady     chan struct{}
+	entityResolving atomic.Bool
+	entityMu        sync.Mutex
+	entityRetryAt   time.Time
+	entityBackoff   time.Duration
+}
+
+func newInterceptorState(config InterceptorConfig) *interceptorState {
+	entityName := config.EntityName
+	if entityName == "" && config.Logger != nil {
+		entityName = config.Logger.Hostname()
+	}
+	s := &interceptorState{
+		config:      config,
+		entityName:  entityName,
+		calls:       make(chan call, callQueueSize),
+		entityReady: make(chan struct{}),
+	}
+	if config.LogMetrics && config.Metrics != nil {
+		s.ensureEntity()
+	}
+	go s.run()
+	return s
+}
+
+// intercept is the UnaryClientInterceptor
+func (s *interceptorState) intercept(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	start := time.Now()
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	s.enqueue(call{method: method, code: status.Code(err), duration: time.Since(start)})
+	return err
+}
+
+// enqueue hands a completed call to the worker without blocking. The call
+// is dropped when the queue is full.
+func (s *interceptorState) enqueue(c call) {
+	if !s.config.LogCalls && !s.config.LogMetrics {
+		return
+	}
+	s.pending.Add(1)
+	select {
+	case s.calls <- c:
+	default:
+		s.pending.Done()
+	}
+}
+
+// run sends queued calls one at a time for the lifetime of the interceptor
+func (s *interceptorState) run() {
+	for c := range s.calls {
+		s.observe(c.method, c.code, c.duration)
+		s.pending.Done()
+	}
+}
+
+// observe records a completed call. It never panics.
+func (s *interceptorState) observe(method string, code codes.Code, duration time.Duration) {
+	defer func() { recover() }() //nolint:errcheck // never crash
+
+	durationMs := float64(duration.Microseconds()) / 1000.0
+
+	if s.config.LogCalls && s.config.Logger != nil {
+		message := fmt.Sprintf("gRPC %s %s (%.0fms)", method, code, durationMs)
+		s.config.Logger.Log(context.Background(), levelForCode(code), message, map[string]interface{}{ //nolint:errcheck // best effort
+			"grpc_method": method,
+			"grpc_code":   code.String(),
+			"duration_ms": durationMs,
+			"source":      "grpc_client",
+		})
+	}
+
+	if s.config.LogMetrics && s.config.Metrics != nil {
+		bound := s.boundMetrics.Load()
+		if bound == nil {
+			s.ensureEntity()
+			return
+		}
+		bound.Send(context.Background(), "grpc.client.duration", durationMs, "ms", map[string]interface{}{ //nolint:errcheck // best effort
+			"method": method,
+			"code":   code.String(),
+		})
+	}
+}
+
+// ensureEntity starts resolving the metrics entity in the background
+// unless a resolution is already running or a failed one is backing off.
+// Calls made before the entity is resolved send no metric.
+func (s *interceptorState) ensureEntity() {
+	s.entityMu.Lock()
+	wait := time.Now().Before(s.entityRetryAt)
+	s.entityMu.Unlock()
+	if wait || !s.entityResolving.CompareAndSwap(false, true) {
+		return
+	}
+
+	go func() {
+		defer s.entityResolving.Store(false)
+		defer func() { recover() }() //nolint:errcheck // never crash
+		s.resolveEntity()
+	}()
+}
+
+// resolveEntity gets or creates the metrics entity. On failure the next
+// attempt is delayed with exponential backoff.
+func (s *interceptorState) resolveEntity() {
+	if s.boundMetrics.Load() != nil {
+		return
+	}
+
+	entity, err := s.config.Metrics.GetOrCreateEntity(context.Background(), logdot.CreateEntityOptions{
+		Name:        s.entityName,
+		Description: fmt.Sprintf("gRPC service: %s", s.entityName),
+	})
+	if err != nil || entity == nil {
+		s.entityMu.Lock()
+		s.entityBackoff = min(max(2*s.entityBackoff, entityRetryBaseDelay), entityRetryMaxDelay)
+		s.entityRetryAt = time.Now().Add(s.entityBackoff)
+		s.entityMu.Unlock()
+		return
+	}
+
+	s.boundMetrics.Store(s.config.Metrics.ForEntity(entity.ID))
+	close(s.entityReady)
+}
+
+// levelForCode maps a status code to a log level: OK is info, codes
+// caused by the request or caller are warn, and server-side failures are
+// error
+func levelForCode(code codes.Code) logdot.LogLevel {
+	switch code {
+	case codes.OK:
+		return logdot.LevelInfo
+	case codes.Canceled, codes.InvalidArgument, codes.NotFound, codes.AlreadyExists,
+		codes.PermissionDenied, codes.Unauthenticated, codes.FailedPrecondition,
+		codes.OutOfRange, codes.ResourceExhausted:
+		return logdot.LevelWarn
+	default:
+		return logdot.LevelError
+	}
+}
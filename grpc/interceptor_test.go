@@ -0,0 +1,189 @@
+package logdotgrpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	logdot "github.com/logdot-io/logdot-go"
+	"github.com/logdot-io/logdot-go/logdottest"
+)
+
+const testMethod = "/users.v1.Users/GetUser"
+
+// newTestInterceptor returns an interceptor whose metrics entity is
+// already resolved, and a wait func that blocks until every call made so
+// far has been sent.
+func newTestInterceptor(t *testing.T, overrides ...func(*InterceptorConfig)) (grpc.UnaryClientInterceptor, *logdottest.Recorder, func()) {
+	t.Helper()
+	rec := logdottest.NewRecorder()
+
+	cfg := DefaultInterceptorConfig()
+	cfg.Logger = rec.NewLogger("test-service")
+	cfg.Metrics = rec.NewMetrics()
+	for _, fn := range overrides {
+		fn(&cfg)
+	}
+	state := newInterceptorState(cfg)
+	if cfg.LogMetrics {
+		select {
+		case <-state.entityReady:
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected the metrics entity to resolve")
+		}
+	}
+	return state.intercept, rec, state.pending.Wait
+}
+
+func invokerReturning(err error) grpc.UnaryInvoker {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return err
+	}
+}
+
+func TestUnaryClientInterceptorLogsCall(t *testing.T) {
+	interceptor, rec, wait := newTestInterceptor(t)
+
+	if err := interceptor(context.Background(), testMethod, nil, nil, nil, invokerReturning(nil)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	wait()
+	logs := rec.Logs()
+	if len(logs) != 1 {
+		t.Fatalf("expected 1 log entry, got %d", len(logs))
+	}
+	entry := logs[0]
+	if entry.Level != logdot.LevelInfo {
+		t.Errorf("expected level info, got %s", entry.Level)
+	}
+	if entry.Tags["grpc_method"] != testMethod {
+		t.Errorf("expected grpc_method %s, got %v", testMethod, entry.Tags["grpc_method"])
+	}
+	if entry.Tags["grpc_code"] != "OK" {
+		t.Errorf("expected grpc_code OK, got %v", entry.Tags["grpc_code"])
+	}
+	if _, ok := entry.Tags["duration_ms"]; !ok {
+		t.Error("expected duration_ms tag")
+	}
+	if entry.Tags["source"] != "grpc_client" {
+		t.Errorf("expected source grpc_client, got %v", entry.Tags["source"])
+	}
+}
+
+func TestUnaryClientInterceptorSendsMetric(t *testing.T) {
+	interceptor, rec, wait := newTestInterceptor(t)
+
+	interceptor(context.Background(), testMethod, nil, nil, nil, invokerReturning(status.Error(codes.NotFound, "no such user"))) //nolint:errcheck
+
+	wait()
+	metrics := rec.Metrics()
+	if len(metrics) != 1 {
+		t.Fatalf("expected 1 metric, got %d", len(metrics))
+	}
+	metric := metrics[0]
+	if metric.Name != "grpc.client.duration" || metric.Unit != "ms" {
+		t.Errorf("unexpected metric: %+v", metric)
+	}
+	want := map[string]bool{"method:" + testMethod: true, "code:NotFound": true}
+	for _, tag := range metric.Tags {
+		delete(want, tag)
+	}
+	if len(want) != 0 {
+		t.Errorf("expected method and code tags, got %v", metric.Tags)
+	}
+}
+
+func TestUnaryClientInterceptorLevels(t *testing.T) {
+	tests := []struct {
+		code codes.Code
+		want logdot.LogLevel
+	}{
+		{codes.NotFound, logdot.LevelWarn},
+		{codes.Unauthenticated, logdot.LevelWarn},
+		{codes.Unavailable, logdot.LevelError},
+		{codes.Internal, logdot.LevelError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.code.String(), func(t *testing.T) {
+			interceptor, rec, wait := newTestInterceptor(t)
+			interceptor(context.Background(), testMethod, nil, nil, nil, invokerReturning(status.Error(tt.code, "failed"))) //nolint:errcheck
+
+			wait()
+			logs := rec.Logs()
+			if len(logs) != 1 {
+				t.Fatalf("expected 1 log entry, got %d", len(logs))
+			}
+			if logs[0].Level != tt.want {
+				t.Errorf("expected level %s, got %s", tt.want, logs[0].Level)
+			}
+		})
+	}
+}
+
+func TestUnaryClientInterceptorReturnsInvokerError(t *testing.T) {
+	interceptor, _, _ := newTestInterceptor(t)
+	want := errors.New("dial failed")
+
+	if err := interceptor(context.Background(), testMethod, nil, nil, nil, invokerReturning(want)); err != want {
+		t.Errorf("expected invoker error to be returned unchanged, got %v", err)
+	}
+}
+
+func TestUnaryClientInterceptorDisabled(t *testing.T) {
+	interceptor, rec, wait := newTestInterceptor(t, func(c *InterceptorConfig) {
+		c.LogCalls = false
+		c.LogMetrics = false
+	})
+
+	interceptor(context.Background(), testMethod, nil, nil, nil, invokerReturning(nil)) //nolint:errcheck
+	wait()
+
+	if len(rec.Logs()) != 0 || len(rec.Metrics()) != 0 {
+		t.Errorf("expected nothing recorded, got %d logs and %d metrics", len(rec.Logs()), len(rec.Metrics()))
+	}
+}
+
+// blockingTransport holds every request until release is closed
+type blockingTransport struct {
+	release chan struct{}
+}
+
+func (b blockingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	<-b.release
+	return nil, errors.New("unavailable")
+}
+
+func TestUnaryClientInterceptorDoesNotWaitOnEntity(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	cfg := DefaultInterceptorConfig()
+	cfg.LogCalls = false
+	cfg.EntityName = "test-service"
+	cfg.Metrics = logdot.NewMetrics("test_api_key",
+		logdot.WithMetricsHTTPClient(&http.Client{Transport: blockingTransport{release: release}}),
+		logdot.WithMetricsRetry(1, time.Millisecond, time.Millisecond),
+	)
+	interceptor := UnaryClientInterceptor(cfg)
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 3; i++ {
+			interceptor(context.Background(), testMethod, nil, nil, nil, invokerReturning(nil)) //nolint:errcheck
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected calls to return while the entity is still resolving")
+	}
+}
@@ -0,0 +1,78 @@
+package logdot
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// heartbeat periodically sends a liveness log for a logger. It is started
+// by NewLogger when WithLoggerHeartbeat is set and stopped by Close.
+type heartbeat struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+	once   sync.Once
+}
+
+func startHeartbeat(l *Logger, interval time.Duration, metrics *BoundMetrics) *heartbeat {
+	ctx, cancel := context.WithCancel(context.Background())
+	h := &heartbeat{cancel: cancel, done: make(chan struct{})}
+	go h.run(ctx, l, interval, metrics)
+	return h
+}
+
+// stop halts the heartbeat and waits for an in-progress send to finish.
+// It is safe to call more than once.
+func (h *heartbeat) stop() {
+	h.once.Do(h.cancel)
+	<-h.done
+}
+
+func (h *heartbeat) run(ctx context.Context, l *Logger, interval time.Duration, metrics *BoundMetrics) {
+	defer close(h.done)
+
+	start := l.http.clock.Now()
+	l.sendHeartbeat(ctx, 0, metrics)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-l.http.clock.After(interval):
+			l.sendHeartbeat(ctx, l.http.clock.Now().Sub(start), metrics)
+		}
+	}
+}
+
+// sendHeartbeat sends one heartbeat log, bypassing batch mode and the
+// minimum level, and the heartbeat metric if metrics is set
+func (l *Logger) sendHeartbeat(ctx context.Context, uptime time.Duration, metrics *BoundMetrics) {
+	defer func() { recover() }() //nolint:errcheck // never crash
+
+	seconds := int(math.Round(uptime.Seconds()))
+	tags, _ := l.mergeTags(map[string]interface{}{
+		"uptime_seconds": seconds,
+		"source":         "heartbeat",
+	})
+	entry, _, err := l.prepare(ctx, LogEntry{Message: "Heartbeat", Level: LevelInfo, Tags: tags}, false)
+	if err == nil {
+		l.sendLog(ctx, entry) //nolint:errcheck // best effort
+	}
+
+	if metrics != nil {
+		metrics.Send(ctx, "heartbeat.uptime", float64(seconds), "s", map[string]interface{}{ //nolint:errcheck // best effort
+			"hostname": l.hostname,
+		})
+	}
+}
+
+// Close stops the logger's background work, i.e. the heartbeat, and waits
+// for it to finish. It does not send queued batch entries; call SendBatch
+// or FlushAll first. Loggers derived with WithContext share the heartbeat,
+// so closing any of them stops it. Close is safe to call more than once.
+func (l *Logger) Close() {
+	if l.heartbeat != nil {
+		l.heartbeat.stop()
+	}
+}
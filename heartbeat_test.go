@@ -0,0 +1,113 @@
+package logdot
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLoggerHeartbeat(t *testing.T) {
+	var mu sync.Mutex
+	var beats []LogEntry
+	var uptimeMetrics int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/logs"):
+			var entry LogEntry
+			json.NewDecoder(r.Body).Decode(&entry)
+			beats = append(beats, entry)
+		case strings.HasSuffix(r.URL.Path, "/metrics"):
+			var metric MetricEntry
+			json.NewDecoder(r.Body).Decode(&metric)
+			if metric.Name == "heartbeat.uptime" && metric.Unit == "s" {
+				uptimeMetrics++
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	target, _ := url.Parse(server.URL)
+	client := &http.Client{Transport: redirectTransport{target: target}}
+	metrics := NewMetrics("test_api_key", WithMetricsHTTPClient(client))
+
+	logger := NewLogger("test_api_key", "worker-1",
+		WithLoggerHTTPClient(client),
+		WithLoggerMinLevel(LevelError),
+		WithLoggerName("payments.worker"),
+		WithLoggerHeartbeat(20*time.Millisecond),
+		WithLoggerHeartbeatMetrics(metrics.ForEntity("entity-1")),
+	)
+	logger.BeginBatch()
+
+	time.Sleep(70 * time.Millisecond)
+	logger.WithContext(nil).Close()
+	logger.Close()
+
+	mu.Lock()
+	sent := len(beats)
+	mu.Unlock()
+	if sent < 2 {
+		t.Fatalf("Expected a startup heartbeat and at least one more, got %d", sent)
+	}
+	first := beats[0]
+	if first.Message != "Heartbeat" || first.Level != LevelInfo || first.Hostname != "worker-1" {
+		t.Errorf("Unexpected heartbeat entry: %+v", first)
+	}
+	if first.Tags["uptime_seconds"] != float64(0) || first.Tags["logger"] != "payments.worker" {
+		t.Errorf("Expected uptime and logger context tags, got %v", first.Tags)
+	}
+	if uptimeMetrics != sent {
+		t.Errorf("Expected one uptime metric per heartbeat, got %d for %d beats", uptimeMetrics, sent)
+	}
+	if logger.BatchSize() != 0 {
+		t.Errorf("Expected heartbeats to bypass batch mode, got %d queued", logger.BatchSize())
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if len(beats) != sent {
+		t.Errorf("Expected no heartbeats after Close, got %d more", len(beats)-sent)
+	}
+}
+
+func TestLoggerCloseWithoutHeartbeat(t *testing.T) {
+	logger := NewLogger("test_api_key", "test-service")
+	logger.Close()
+	logger.Close()
+}
+
+func TestLoggerHeartbeatUsesClock(t *testing.T) {
+	beats := make(chan struct{}, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		beats <- struct{}{}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	target, _ := url.Parse(server.URL)
+	clock := tickClock{ticks: make(chan time.Time)}
+	logger := NewLogger("test_api_key", "worker-1",
+		WithLoggerHTTPClient(&http.Client{Transport: redirectTransport{target: target}}),
+		WithLoggerClock(clock),
+		WithLoggerHeartbeat(time.Hour),
+	)
+	defer logger.Close()
+
+	for i, trigger := range []func(){func() {}, func() { clock.ticks <- time.Time{} }} {
+		trigger()
+		select {
+		case <-beats:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Expected heartbeat %d when the clock fires", i+1)
+		}
+	}
+}
@@ -8,7 +8,11 @@ import (
 	"io"
 	"math"
 	"math/rand"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -24,24 +28,190 @@ type RetryConfig struct {
 	MaxDelay    time.Duration
 }
 
+// Serializer encodes request payloads. The default is JSONSerializer.
+type Serializer interface {
+	Marshal(v interface{}) ([]byte, error)
+	ContentType() string
+}
+
+// JSONSerializer encodes payloads with encoding/json
+type JSONSerializer struct{}
+
+// Marshal encodes v as JSON
+func (JSONSerializer) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// ContentType returns "application/json"
+func (JSONSerializer) ContentType() string {
+	return "application/json"
+}
+
+// RetryDecider reports whether a request should be retried given its
+// response and error. resp is nil when err is a transport error.
+type RetryDecider func(resp *http.Response, err error) bool
+
+// DefaultRetryDecider retries transport errors only
+func DefaultRetryDecider(resp *http.Response, err error) bool {
+	return err != nil
+}
+
 // HTTPClient handles HTTP communication with retry logic
 type HTTPClient struct {
-	client    *http.Client
-	apiKey    string
-	timeout   time.Duration
-	retry     RetryConfig
-	debug     bool
+	client     *http.Client
+	apiKey     string
+	timeout    time.Duration
+	debug      bool
+	clock      Clock
+	decider    RetryDecider
+	serializer Serializer
+
+	// authHeader and authScheme carry the API key: "<authHeader>:
+	// <authScheme> <key>", or just the key when authScheme is empty
+	authHeader string
+	authScheme string
+
+	// successCodes are the statuses that count as an accepted send; nil
+	// means 200 and 201
+	successCodes []int
+
+	// debugLimit caps debug output per second; nil means unlimited
+	debugLimit *debugLimiter
+
+	retryMu sync.RWMutex
+	retry   RetryConfig
+
+	rateLimitMu sync.Mutex
+	rateLimit   RateLimitStatus
+}
+
+// defaultMaxIdleConnsPerHost keeps enough idle connections for concurrent
+// sends to be reused; net/http's default is 2
+const defaultMaxIdleConnsPerHost = 16
+
+// newTransport returns the transport used unless a custom one is given:
+// a clone of http.DefaultTransport (keep-alive, HTTP/2 over TLS) with more
+// idle connections per host
+func newTransport() http.RoundTripper {
+	base, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		return http.DefaultTransport
+	}
+	transport := base.Clone()
+	transport.ForceAttemptHTTP2 = true
+	transport.MaxIdleConnsPerHost = defaultMaxIdleConnsPerHost
+	return transport
+}
+
+// defaultKeepAlive matches the keep-alive of http.DefaultTransport's dialer
+const defaultKeepAlive = 30 * time.Second
+
+// withTimeouts returns a copy of rt with the given dial and response
+// header timeouts (zero leaves a setting unchanged). Transports that are
+// not an *http.Transport are returned as-is.
+func withTimeouts(rt http.RoundTripper, dial, responseHeader time.Duration) http.RoundTripper {
+	base, ok := rt.(*http.Transport)
+	if !ok {
+		return rt
+	}
+	transport := base.Clone()
+	if dial > 0 {
+		transport.DialContext = (&net.Dialer{Timeout: dial, KeepAlive: defaultKeepAlive}).DialContext
+	}
+	if responseHeader > 0 {
+		transport.ResponseHeaderTimeout = responseHeader
+	}
+	return transport
+}
+
+// minUnixReset separates X-RateLimit-Reset values that are Unix
+// timestamps from ones that are seconds until the reset
+const minUnixReset = 1_000_000_000
+
+// recordRateLimit stores the X-RateLimit-* headers of a response, if it
+// has any. Reset is accepted as a Unix timestamp or as seconds from now.
+func (h *HTTPClient) recordRateLimit(header http.Header) {
+	limit, hasLimit := headerInt(header, "X-RateLimit-Limit")
+	remaining, hasRemaining := headerInt(header, "X-RateLimit-Remaining")
+	reset, hasReset := headerInt(header, "X-RateLimit-Reset")
+	if !hasLimit && !hasRemaining && !hasReset {
+		return
+	}
+
+	now := h.clock.Now()
+	status := RateLimitStatus{Limit: limit, Remaining: remaining, UpdatedAt: now}
+	if hasReset {
+		if reset >= minUnixReset {
+			status.Reset = time.Unix(int64(reset), 0)
+		} else {
+			status.Reset = now.Add(time.Duration(reset) * time.Second)
+		}
+	}
+
+	h.rateLimitMu.Lock()
+	h.rateLimit = status
+	h.rateLimitMu.Unlock()
+}
+
+// rateLimitStatus returns the last recorded rate limit status
+func (h *HTTPClient) rateLimitStatus() RateLimitStatus {
+	h.rateLimitMu.Lock()
+	defer h.rateLimitMu.Unlock()
+	return h.rateLimit
+}
+
+// headerInt parses an integer header, returning -1 and false when it is
+// missing or malformed
+func headerInt(header http.Header, key string) (int, bool) {
+	n, err := strconv.Atoi(strings.TrimSpace(header.Get(key)))
+	if err != nil {
+		return -1, false
+	}
+	return n, true
 }
 
 // NewHTTPClient creates a new HTTP client
 func NewHTTPClient(apiKey string, timeout time.Duration, retry RetryConfig, debug bool) *HTTPClient {
 	return &HTTPClient{
-		client:  &http.Client{Timeout: timeout},
-		apiKey:  apiKey,
-		timeout: timeout,
-		retry:   retry,
-		debug:   debug,
+		client:     &http.Client{Timeout: timeout, Transport: newTransport()},
+		apiKey:     apiKey,
+		timeout:    timeout,
+		retry:      retry,
+		debug:      debug,
+		clock:      realClock{},
+		decider:    DefaultRetryDecider,
+		serializer: JSONSerializer{},
+		authHeader: defaultAuthHeader,
+		authScheme: defaultAuthScheme,
+	}
+}
+
+// Default API key header, as expected by the LogDot API
+const (
+	defaultAuthHeader = "Authorization"
+	defaultAuthScheme = "Bearer"
+)
+
+// accepted reports whether status means a log or metric send was
+// accepted
+func (h *HTTPClient) accepted(status int) bool {
+	if h.successCodes == nil {
+		return status == http.StatusOK || status == http.StatusCreated
 	}
+	for _, code := range h.successCodes {
+		if status == code {
+			return true
+		}
+	}
+	return false
+}
+
+// authValue returns the value of the auth header
+func (h *HTTPClient) authValue() string {
+	if h.authScheme == "" {
+		return h.apiKey
+	}
+	return h.authScheme + " " + h.apiKey
 }
 
 // Post performs a POST request with retry
@@ -55,42 +225,61 @@ func (h *HTTPClient) Get(ctx context.Context, url string) (*http.Response, []byt
 }
 
 func (h *HTTPClient) doWithRetry(ctx context.Context, method, url string, body interface{}) (*http.Response, []byte, error) {
-	var lastErr error
-
-	for attempt := 0; attempt < h.retry.MaxAttempts; attempt++ {
+	retry := h.retryConfig()
+	for attempt := 0; attempt < retry.MaxAttempts; attempt++ {
 		resp, respBody, err := h.doRequest(ctx, method, url, body)
-		if err == nil {
-			return resp, respBody, nil
+		if !h.decider(resp, err) || attempt == retry.MaxAttempts-1 {
+			return resp, respBody, err
 		}
 
-		lastErr = err
-
-		if attempt < h.retry.MaxAttempts-1 {
-			delay := h.calculateBackoff(attempt)
-			h.log("Retry %d/%d after %v - Error: %v", attempt+1, h.retry.MaxAttempts, delay, err)
+		delay := calculateBackoff(retry, attempt)
+		if err != nil {
+			h.log("Retry %d/%d after %v - Error: %v", attempt+1, retry.MaxAttempts, delay, err)
+		} else {
+			h.log("Retry %d/%d after %v - Status: %d", attempt+1, retry.MaxAttempts, delay, resp.StatusCode)
+		}
 
-			select {
-			case <-ctx.Done():
-				return nil, nil, ctx.Err()
-			case <-time.After(delay):
-			}
+		select {
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		case <-h.clock.After(delay):
 		}
 	}
 
-	return nil, nil, lastErr
+	return nil, nil, fmt.Errorf("no request attempts configured")
 }
 
+// streamBody is a request body that is encoded while it is being sent
+// instead of being marshaled up front. It is called once per attempt and
+// must write the complete body to w.
+type streamBody func(w io.Writer) error
+
 func (h *HTTPClient) doRequest(ctx context.Context, method, url string, body interface{}) (*http.Response, []byte, error) {
 	var bodyReader io.Reader
 
-	if body != nil {
-		jsonBody, err := json.Marshal(body)
+	if stream, ok := body.(streamBody); ok {
+		pr, pw := io.Pipe()
+		go func() {
+			pw.CloseWithError(stream(pw))
+		}()
+		// The transport closes the body when it is done with it; this
+		// also covers the early returns, unblocking the writer
+		defer pr.Close()
+		bodyReader = pr
+		h.log("%s %s", method, url)
+		h.log("Payload: streamed")
+	} else if body != nil {
+		encoded, err := h.serializer.Marshal(body)
 		if err != nil {
 			return nil, nil, fmt.Errorf("failed to marshal body: %w", err)
 		}
-		bodyReader = bytes.NewReader(jsonBody)
+		bodyReader = bytes.NewReader(encoded)
 		h.log("%s %s", method, url)
-		h.log("Payload: %s", string(jsonBody))
+		if _, isJSON := h.serializer.(JSONSerializer); isJSON {
+			h.log("Payload: %s", string(encoded))
+		} else {
+			h.log("Payload: %d bytes (%s)", len(encoded), h.serializer.ContentType())
+		}
 	} else {
 		h.log("%s %s", method, url)
 	}
@@ -100,14 +289,16 @@ func (h *HTTPClient) doRequest(ctx context.Context, method, url string, body int
 		return nil, nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+h.apiKey)
+	req.Header.Set("Content-Type", h.serializer.ContentType())
+	req.Header.Set(h.authHeader, h.authValue())
 
 	resp, err := h.client.Do(req)
 	if err != nil {
 		return nil, nil, fmt.Errorf("request failed: %w", err)
 	}
 
+	h.recordRateLimit(resp.Header)
+
 	respBody, err := io.ReadAll(resp.Body)
 	resp.Body.Close()
 	if err != nil {
@@ -122,19 +313,85 @@ func (h *HTTPClient) doRequest(ctx context.Context, method, url string, body int
 	return resp, respBody, nil
 }
 
-func (h *HTTPClient) calculateBackoff(attempt int) time.Duration {
-	delay := float64(h.retry.BaseDelay) * math.Pow(2, float64(attempt))
+// retryConfig returns the current retry configuration
+func (h *HTTPClient) retryConfig() RetryConfig {
+	h.retryMu.RLock()
+	defer h.retryMu.RUnlock()
+	return h.retry
+}
+
+// setRetryConfig replaces the retry configuration. Requests already in
+// their retry loop keep the configuration they started with.
+func (h *HTTPClient) setRetryConfig(retry RetryConfig) {
+	h.retryMu.Lock()
+	defer h.retryMu.Unlock()
+	h.retry = retry
+}
+
+func calculateBackoff(retry RetryConfig, attempt int) time.Duration {
+	delay := float64(retry.BaseDelay) * math.Pow(2, float64(attempt))
 	jitter := rand.Float64() * 0.3 * delay
 	total := time.Duration(delay + jitter)
 
-	if total > h.retry.MaxDelay {
-		return h.retry.MaxDelay
+	if total > retry.MaxDelay {
+		return retry.MaxDelay
 	}
 	return total
 }
 
+// maskAPIKey hides all but the first and last four characters of a key
+func maskAPIKey(key string) string {
+	if len(key) <= 8 {
+		return strings.Repeat("*", len(key))
+	}
+	return key[:4] + strings.Repeat("*", len(key)-8) + key[len(key)-4:]
+}
+
 func (h *HTTPClient) log(format string, args ...interface{}) {
-	if h.debug {
-		fmt.Printf("[LogDot] "+format+"\n", args...)
+	if !h.debug {
+		return
+	}
+	if h.debugLimit != nil {
+		ok, suppressed := h.debugLimit.allow(h.clock.Now())
+		if suppressed > 0 {
+			fmt.Printf("[LogDot] %d debug messages suppressed\n", suppressed)
+		}
+		if !ok {
+			return
+		}
+	}
+	fmt.Printf("[LogDot] "+format+"\n", args...)
+}
+
+// debugLimiter allows up to perSecond debug lines per one-second window
+// and counts the ones it drops, so a retry storm prints a summary line
+// instead of a wall of identical messages
+type debugLimiter struct {
+	mu          sync.Mutex
+	perSecond   int
+	windowStart time.Time
+	count       int
+	suppressed  int
+}
+
+// allow reports whether a line may be printed at now. When a new window
+// starts it also returns how many lines the previous window suppressed.
+func (d *debugLimiter) allow(now time.Time) (bool, int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var suppressed int
+	if now.Sub(d.windowStart) >= time.Second {
+		suppressed = d.suppressed
+		d.windowStart = now
+		d.count = 0
+		d.suppressed = 0
+	}
+
+	if d.count >= d.perSecond {
+		d.suppressed++
+		return false, suppressed
 	}
+	d.count++
+	return true, suppressed
 }
@@ -0,0 +1,206 @@
+// Package logdottest provides an in-memory LogDot backend for tests.
+//
+// A Recorder hands out real *logdot.Logger and *logdot.Metrics clients
+// whose requests never leave the process. Everything they send is decoded
+// and kept in memory so tests can assert on it.
+//
+// Example:
+//
+//	rec := logdottest.NewRecorder()
+//	logger := rec.NewLogger("my-service")
+//
+//	logger.Info(ctx, "hello", nil)
+//
+//	if len(rec.Logs()) != 1 {
+//		t.Fatal("expected one log")
+//	}
+package logdottest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	logdot "github.com/logdot-io/logdot-go"
+)
+
+// Recorder is an in-memory LogDot backend. It is safe for concurrent use.
+type Recorder struct {
+	mu       sync.Mutex
+	logs     []logdot.LogEntry
+	metrics  []logdot.MetricEntry
+	entities map[string]string // name -> ID
+}
+
+// NewRecorder creates an empty Recorder
+func NewRecorder() *Recorder {
+	return &Recorder{
+		entities: make(map[string]string),
+	}
+}
+
+// NewLogger creates a Logger whose output is captured by the recorder
+func (r *Recorder) NewLogger(hostname string, opts ...logdot.LoggerOption) *logdot.Logger {
+	opts = append(opts, logdot.WithLoggerHTTPClient(r.httpClient()))
+	return logdot.NewLogger("test_api_key", hostname, opts...)
+}
+
+// NewMetrics creates a Metrics client whose output is captured by the
+// recorder. Entities are created in memory on first use.
+func (r *Recorder) NewMetrics(opts ...logdot.MetricsOption) *logdot.Metrics {
+	opts = append(opts, logdot.WithMetricsHTTPClient(r.httpClient()))
+	return logdot.NewMetrics("test_api_key", opts...)
+}
+
+// Logs returns a copy of all recorded log entries, in the order received.
+// Entries from batches carry the batch hostname.
+func (r *Recorder) Logs() []logdot.LogEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	result := make([]logdot.LogEntry, len(r.logs))
+	copy(result, r.logs)
+	return result
+}
+
+// Metrics returns a copy of all recorded metric entries, in the order
+// received. Entries from batches carry the batch entity ID and name.
+func (r *Recorder) Metrics() []logdot.MetricEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	result := make([]logdot.MetricEntry, len(r.metrics))
+	copy(result, r.metrics)
+	return result
+}
+
+// Reset discards all recorded logs and metrics. Entities are kept.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.logs = nil
+	r.metrics = nil
+}
+
+func (r *Recorder) httpClient() *http.Client {
+	return &http.Client{Transport: roundTripFunc(r.roundTrip)}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func (r *Recorder) roundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	path := req.URL.Path
+	switch {
+	case req.Method == http.MethodPost && strings.HasSuffix(path, "/logs"):
+		var entry logdot.LogEntry
+		if err := json.Unmarshal(body, &entry); err != nil {
+			return respond(req, http.StatusBadRequest, nil), nil
+		}
+		r.logs = append(r.logs, entry)
+
+	case req.Method == http.MethodPost && strings.HasSuffix(path, "/logs/batch"):
+		var payload logdot.BatchLogsPayload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return respond(req, http.StatusBadRequest, nil), nil
+		}
+		for _, entry := range payload.Logs {
+			if entry.Hostname == "" {
+				entry.Hostname = payload.Hostname
+			}
+			r.logs = append(r.logs, entry)
+		}
+
+	case req.Method == http.MethodPost && strings.HasSuffix(path, "/metrics"):
+		var entry logdot.MetricEntry
+		if err := json.Unmarshal(body, &entry); err != nil {
+			return respond(req, http.StatusBadRequest, nil), nil
+		}
+		r.metrics = append(r.metrics, entry)
+
+	case req.Method == http.MethodPost && strings.HasSuffix(path, "/metrics/batch"):
+		var payload logdot.BatchMetricsPayload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return respond(req, http.StatusBadRequest, nil), nil
+		}
+		for _, m := range payload.Metrics {
+			name := m.Name
+			if name == "" {
+				name = payload.Name
+			}
+			r.metrics = append(r.metrics, logdot.MetricEntry{
+				EntityID: payload.EntityID,
+				Name:     name,
+				Value:    m.Value,
+				Unit:     m.Unit,
+				Tags:     m.Tags,
+			})
+		}
+
+	case req.Method == http.MethodPost && strings.HasSuffix(path, "/entities"):
+		var payload logdot.EntityPayload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return respond(req, http.StatusBadRequest, nil), nil
+		}
+		id, ok := r.entities[payload.Name]
+		if !ok {
+			id = fmt.Sprintf("entity-%d", len(r.entities)+1)
+			r.entities[payload.Name] = id
+		}
+		return respond(req, http.StatusCreated, entityResponse(id, payload.Name)), nil
+
+	case req.Method == http.MethodGet && strings.Contains(path, "/entities/by-name/"):
+		escaped := req.URL.EscapedPath()
+		name, _ := url.PathUnescape(escaped[strings.LastIndex(escaped, "/")+1:])
+		id, ok := r.entities[name]
+		if !ok {
+			return respond(req, http.StatusNotFound, nil), nil
+		}
+		return respond(req, http.StatusOK, entityResponse(id, name)), nil
+
+	default:
+		return respond(req, http.StatusNotFound, nil), nil
+	}
+
+	return respond(req, http.StatusOK, map[string]interface{}{"status": "ok"}), nil
+}
+
+func entityResponse(id, name string) map[string]interface{} {
+	return map[string]interface{}{
+		"status": "ok",
+		"data":   map[string]interface{}{"id": id, "name": name},
+	}
+}
+
+func respond(req *http.Request, status int, body interface{}) *http.Response {
+	var data []byte
+	if body != nil {
+		data, _ = json.Marshal(body)
+	}
+	return &http.Response{
+		StatusCode: status,
+		Status:     http.StatusText(status),
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(data)),
+		Request:    req,
+	}
+}
@@ -0,0 +1,77 @@
+package logdottest
+
+import (
+	"context"
+	"testing"
+
+	logdot "github.com/logdot-io/logdot-go"
+)
+
+func TestRecorderCapturesLogs(t *testing.T) {
+	rec := NewRecorder()
+	logger := rec.NewLogger("test-service")
+	ctx := context.Background()
+
+	if err := logger.Info(ctx, "single", map[string]interface{}{"key": "value"}); err != nil {
+		t.Fatalf("Info failed: %v", err)
+	}
+
+	logger.BeginBatch()
+	logger.Warn(ctx, "batched 1", nil)
+	logger.Error(ctx, "batched 2", nil)
+	if err := logger.SendBatch(ctx); err != nil {
+		t.Fatalf("SendBatch failed: %v", err)
+	}
+
+	logs := rec.Logs()
+	if len(logs) != 3 {
+		t.Fatalf("Expected 3 logs, got %d", len(logs))
+	}
+	if logs[0].Message != "single" || logs[0].Tags["key"] != "value" {
+		t.Errorf("Unexpected first log: %+v", logs[0])
+	}
+	if logs[2].Level != logdot.LevelError || logs[2].Hostname != "test-service" {
+		t.Errorf("Unexpected batched log: %+v", logs[2])
+	}
+}
+
+func TestRecorderCapturesMetrics(t *testing.T) {
+	rec := NewRecorder()
+	metrics := rec.NewMetrics()
+	ctx := context.Background()
+
+	entity, err := metrics.GetOrCreateEntity(ctx, logdot.CreateEntityOptions{Name: "my service"})
+	if err != nil {
+		t.Fatalf("GetOrCreateEntity failed: %v", err)
+	}
+	again, err := metrics.GetOrCreateEntity(ctx, logdot.CreateEntityOptions{Name: "my service"})
+	if err != nil || again.ID != entity.ID {
+		t.Fatalf("Expected existing entity %s, got %v (%v)", entity.ID, again, err)
+	}
+
+	client := metrics.ForEntity(entity.ID)
+	client.Send(ctx, "cpu", 42, "percent", nil)
+
+	client.BeginBatch("temperature", "celsius")
+	client.Add(20, nil)
+	client.Add(21, nil)
+	if err := client.SendBatch(ctx); err != nil {
+		t.Fatalf("SendBatch failed: %v", err)
+	}
+
+	recorded := rec.Metrics()
+	if len(recorded) != 3 {
+		t.Fatalf("Expected 3 metrics, got %d", len(recorded))
+	}
+	if recorded[0].Name != "cpu" || recorded[0].Value != 42 {
+		t.Errorf("Unexpected first metric: %+v", recorded[0])
+	}
+	if recorded[2].Name != "temperature" || recorded[2].EntityID != entity.ID {
+		t.Errorf("Unexpected batched metric: %+v", recorded[2])
+	}
+
+	rec.Reset()
+	if len(rec.Metrics()) != 0 {
+		t.Error("Expected no metrics after Reset")
+	}
+}
@@ -1,22 +1,88 @@
 package logdot
 
 import (
+	"bufio"
 	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
 
+// loggerNameTag is the tag that carries LoggerConfig.Name
+const loggerNameTag = "logger"
+
 // Logger handles log transmission to LogDot
 type Logger struct {
 	http     *HTTPClient
 	hostname string
 	debug    bool
+	batchID  bool
+	minLevel LogLevel
 	logCtx   map[string]interface{}
 
-	mu         sync.Mutex
-	batchMode  bool
-	batchQueue []LogEntry
+	// sendSem bounds concurrent sends; nil means unbounded. Shared with
+	// derived loggers.
+	sendSem chan struct{}
+
+	// config is the resolved configuration, reported by Config
+	config LoggerConfig
+
+	onSuccess func(entries []LogEntry, resp *APIResponse)
+
+	// fieldNames renames wire keys; nil means the default names
+	fieldNames *FieldNameMap
+
+	contextExtractor ContextExtractor
+
+	// onceKeys records LogOnce keys. Shared with derived loggers.
+	onceKeys *sync.Map
+
+	emptyMessage string
+
+	compactBatches bool
+
+	errorClassifiers []ErrorClassifier
+
+	stripANSI bool
+
+	tagSchema *TagSchema
+
+	// batches tracks loggers with queued entries for FlushAll. Shared
+	// with derived loggers. flushable is set by BeginBatch; only such
+	// loggers are tracked, and only while their queue is non-empty.
+	batches   *batchRegistry
+	flushable bool
+
+	// heartbeat is the running heartbeat, stopped by Close; nil when
+	// disabled. Shared with derived loggers.
+	heartbeat *heartbeat
+
+	mu           sync.Mutex
+	batchMode    bool
+	batchQueue   []LogEntry
+	maxQueueSize int
+
+	// queueWarnAt is the queue size of the next QueueWarnThreshold
+	// warning; 0 means the threshold itself
+	queueWarnAt int
+
+	// Consecutive-duplicate tracking for the last queued entry
+	dedupWindow  time.Duration
+	dedupCount   int
+	dedupTags    map[string]interface{}
+	lastQueuedAt time.Time
 }
 
 // DefaultLoggerConfig returns a LoggerConfig with default values
@@ -27,10 +93,13 @@ func DefaultLoggerConfig() LoggerConfig {
 		RetryBaseDelay: 1 * time.Second,
 		RetryMaxDelay:  30 * time.Second,
 		Debug:          false,
+		MinLevel:       LevelDebug,
+		EmptyMessage:   "(no message)",
 	}
 }
 
-// NewLogger creates a new Logger instance
+// NewLogger creates a new Logger instance. An empty hostname defaults to
+// the OS hostname, or "unknown" if it cannot be determined.
 //
 // Example:
 //
@@ -45,28 +114,106 @@ func NewLogger(apiKey, hostname string, opts ...LoggerOption) *Logger {
 		opt(&config)
 	}
 
-	return &Logger{
-		http: NewHTTPClient(
-			config.APIKey,
-			config.Timeout,
-			RetryConfig{
-				MaxAttempts: config.RetryAttempts,
-				BaseDelay:   config.RetryBaseDelay,
-				MaxDelay:    config.RetryMaxDelay,
-			},
-			config.Debug,
-		),
-		hostname:   config.Hostname,
-		debug:      config.Debug,
-		logCtx:     make(map[string]interface{}),
-		batchQueue: make([]LogEntry, 0),
+	if config.Hostname == "" {
+		config.Hostname = defaultHostname()
+	}
+
+	httpClient := NewHTTPClient(
+		config.APIKey,
+		config.Timeout,
+		RetryConfig{
+			MaxAttempts: config.RetryAttempts,
+			BaseDelay:   config.RetryBaseDelay,
+			MaxDelay:    config.RetryMaxDelay,
+		},
+		config.Debug,
+	)
+	if config.Clock != nil {
+		httpClient.clock = config.Clock
+	}
+	if config.HTTPClient != nil {
+		httpClient.client = config.HTTPClient
+	} else {
+		if config.Transport != nil {
+			httpClient.client.Transport = config.Transport
+		}
+		if config.DialTimeout > 0 || config.ResponseHeaderTimeout > 0 {
+			httpClient.client.Transport = withTimeouts(httpClient.client.Transport, config.DialTimeout, config.ResponseHeaderTimeout)
+		}
+		if config.NoClientTimeout {
+			httpClient.client.Timeout = 0
+		}
+	}
+	if config.Serializer != nil {
+		httpClient.serializer = config.Serializer
+	}
+	if len(config.SuccessCodes) > 0 {
+		httpClient.successCodes = append([]int(nil), config.SuccessCodes...)
+	}
+	if config.AuthHeader != "" {
+		httpClient.authHeader = config.AuthHeader
+		httpClient.authScheme = config.AuthScheme
+	}
+	if config.DebugRate > 0 {
+		httpClient.debugLimit = &debugLimiter{perSecond: config.DebugRate}
+	}
+	if config.RetryDecider != nil {
+		httpClient.decider = config.RetryDecider
+	}
+
+	extractor := config.ContextExtractor
+	if len(config.ContextKeys) > 0 {
+		extractor = chainExtractors(contextKeysExtractor(config.ContextKeys), extractor)
+	}
+
+	logCtx := make(map[string]interface{})
+	if config.AutoEnv {
+		logCtx = detectEnvTags(config.AutoEnvSkip)
+	}
+	if config.Name != "" {
+		logCtx[loggerNameTag] = config.Name
+	}
+
+	var sendSem chan struct{}
+	if config.MaxConcurrentSends > 0 {
+		sendSem = make(chan struct{}, config.MaxConcurrentSends)
 	}
+
+	l := &Logger{
+		http:             httpClient,
+		hostname:         config.Hostname,
+		debug:            config.Debug,
+		batchID:          config.BatchID,
+		minLevel:         config.MinLevel,
+		logCtx:           logCtx,
+		sendSem:          sendSem,
+		config:           config,
+		batchQueue:       make([]LogEntry, 0),
+		dedupWindow:      config.DedupWindow,
+		maxQueueSize:     config.MaxQueueSize,
+		onSuccess:        config.OnSuccess,
+		fieldNames:       resolveFieldNames(config.FieldNames),
+		batches:          &batchRegistry{loggers: make(map[*Logger]struct{})},
+		contextExtractor: extractor,
+		onceKeys:         &sync.Map{},
+		emptyMessage:     config.EmptyMessage,
+		compactBatches:   config.CompactBatches,
+		errorClassifiers: config.ErrorClassifiers,
+		stripANSI:        config.StripANSI,
+		tagSchema:        config.TagSchema,
+	}
+	if config.HeartbeatInterval > 0 {
+		l.heartbeat = startHeartbeat(l, config.HeartbeatInterval, config.HeartbeatMetrics)
+	}
+	return l
 }
 
 // LoggerOption is a function that configures a LoggerConfig
 type LoggerOption func(*LoggerConfig)
 
-// WithLoggerTimeout sets the HTTP timeout
+// WithLoggerTimeout sets the HTTP timeout. Each attempt ends at whichever
+// comes first: this timeout or the deadline of the context passed to the
+// log call.
 func WithLoggerTimeout(timeout time.Duration) LoggerOption {
 	return func(c *LoggerConfig) {
 		c.Timeout = timeout
@@ -89,185 +236,1736 @@ func WithLoggerDebug(enabled bool) LoggerOption {
 	}
 }
 
-// WithContext creates a new Logger with additional context that will be merged with all log tags.
-// The returned logger shares the same HTTP client but has its own context.
+// WithLoggerDebugRate limits debug output from the HTTP client to
+// perSecond lines per second. Dropped lines are summarized with a count
+// once the next second starts.
+func WithLoggerDebugRate(perSecond int) LoggerOption {
+	return func(c *LoggerConfig) {
+		c.DebugRate = perSecond
+	}
+}
+
+// WithLoggerBatchID tags every entry sent by SendBatch with a batch_id
+// shared by all entries in the same flush
+func WithLoggerBatchID(enabled bool) LoggerOption {
+	return func(c *LoggerConfig) {
+		c.BatchID = enabled
+	}
+}
+
+// WithLoggerNoClientTimeout removes the client-wide timeout so each call's
+// context deadline is the only limit. Without a deadline on the context a
+// request may wait indefinitely. Ignored when WithLoggerHTTPClient is used.
 //
 // Example:
 //
-//	logger := logdot.NewLogger("apiKey", "my-service")
-//	userLogger := logger.WithContext(map[string]interface{}{"user_id": 123})
-//	userLogger.Info(ctx, "User action", nil) // Will include user_id
-func (l *Logger) WithContext(ctx map[string]interface{}) *Logger {
-	// Merge existing context with new context
-	mergedCtx := make(map[string]interface{})
-	for k, v := range l.logCtx {
-		mergedCtx[k] = v
+//	logger := logdot.NewLogger(apiKey, "my-service", logdot.WithLoggerNoClientTimeout())
+//	ctx, cancel := context.WithTimeout(ctx, 30*time.Second) // large batch upload
+//	defer cancel()
+//	logger.SendBatch(ctx)
+func WithLoggerNoClientTimeout() LoggerOption {
+	return func(c *LoggerConfig) {
+		c.NoClientTimeout = true
 	}
-	for k, v := range ctx {
-		mergedCtx[k] = v
+}
+
+// WithLoggerRetryDecider sets the function that decides whether a failed
+// request is retried. Defaults to DefaultRetryDecider.
+//
+// Example:
+//
+//	logdot.WithLoggerRetryDecider(func(resp *http.Response, err error) bool {
+//		return err != nil || resp.StatusCode == 429 || resp.StatusCode >= 500
+//	})
+func WithLoggerRetryDecider(decider RetryDecider) LoggerOption {
+	return func(c *LoggerConfig) {
+		c.RetryDecider = decider
 	}
+}
 
-	return &Logger{
-		http:       l.http,
-		hostname:   l.hostname,
-		debug:      l.debug,
-		logCtx:     mergedCtx,
-		batchMode:  false,
-		batchQueue: make([]LogEntry, 0),
+// WithLoggerHTTPClient sets the underlying *http.Client, for custom transports
+// or proxies. The client's own Timeout applies instead of the configured one.
+func WithLoggerHTTPClient(client *http.Client) LoggerOption {
+	return func(c *LoggerConfig) {
+		c.HTTPClient = client
 	}
 }
 
-// GetContext returns a copy of the current context
-func (l *Logger) GetContext() map[string]interface{} {
-	l.mu.Lock()
-	defer l.mu.Unlock()
-	result := make(map[string]interface{})
-	for k, v := range l.logCtx {
-		result[k] = v
+// WithLoggerTransport sets the transport of the default HTTP client, e.g.
+// to tune MaxIdleConnsPerHost for high-volume senders. The default
+// transport already keeps connections alive and negotiates HTTP/2; set
+// ForceAttemptHTTP2 on a custom transport that has its own TLS config to
+// keep HTTP/2. Ignored when WithLoggerHTTPClient is used.
+//
+// Example:
+//
+//	transport := http.DefaultTransport.(*http.Transport).Clone()
+//	transport.MaxIdleConnsPerHost = 64
+//	logger := logdot.NewLogger(apiKey, "my-service", logdot.WithLoggerTransport(transport))
+func WithLoggerTransport(transport *http.Transport) LoggerOption {
+	return func(c *LoggerConfig) {
+		c.Transport = transport
 	}
-	return result
 }
 
-// mergeTags merges context with provided tags (tags take precedence)
-func (l *Logger) mergeTags(tags map[string]interface{}) map[string]interface{} {
-	if len(l.logCtx) == 0 && len(tags) == 0 {
-		return nil
+// WithLoggerDialTimeout limits how long establishing a connection
+// (including DNS resolution) may take, independently of the overall
+// timeout, so an unreachable endpoint fails fast while large uploads keep
+// the full timeout. Applied to a copy of the transport; ignored when
+// WithLoggerHTTPClient is used.
+//
+// Example:
+//
+//	logger := logdot.NewLogger(apiKey, "my-service",
+//		logdot.WithLoggerTimeout(60*time.Second),
+//		logdot.WithLoggerDialTimeout(2*time.Second),
+//	)
+func WithLoggerDialTimeout(d time.Duration) LoggerOption {
+	return func(c *LoggerConfig) {
+		c.DialTimeout = d
 	}
-	merged := make(map[string]interface{})
-	for k, v := range l.logCtx {
-		merged[k] = v
+}
+
+// WithLoggerResponseHeaderTimeout limits how long to wait for the response
+// headers once the request has been written. Applied to a copy of the
+// transport; ignored when WithLoggerHTTPClient is used.
+func WithLoggerResponseHeaderTimeout(d time.Duration) LoggerOption {
+	return func(c *LoggerConfig) {
+		c.ResponseHeaderTimeout = d
 	}
-	for k, v := range tags {
-		merged[k] = v
+}
+
+// WithLoggerMaxConcurrentSends limits how many HTTP requests the logger
+// (and loggers derived from it) may have in flight at once. Excess sends
+// wait for a free slot or until their context is done.
+func WithLoggerMaxConcurrentSends(n int) LoggerOption {
+	return func(c *LoggerConfig) {
+		c.MaxConcurrentSends = n
 	}
-	return merged
 }
 
-// Debug logs a debug message
-func (l *Logger) Debug(ctx context.Context, message string, tags map[string]interface{}) error {
-	return l.Log(ctx, LevelDebug, message, tags)
+// WithLoggerSerializer sets the encoding used for log payloads, for
+// ingestion pipelines that accept formats other than JSON
+func WithLoggerSerializer(serializer Serializer) LoggerOption {
+	return func(c *LoggerConfig) {
+		c.Serializer = serializer
+	}
 }
 
-// Info logs an info message
-func (l *Logger) Info(ctx context.Context, message string, tags map[string]interface{}) error {
-	return l.Log(ctx, LevelInfo, message, tags)
+// WithLoggerAuthHeader sends the API key in header name with the given
+// scheme instead of "Authorization: Bearer <key>", for gateways in front
+// of LogDot that expect other conventions. An empty scheme sends the key
+// alone.
+//
+// Example:
+//
+//	logger := logdot.NewLogger(apiKey, "my-service", logdot.WithLoggerAuthHeader("X-API-Key", ""))
+func WithLoggerAuthHeader(name, scheme string) LoggerOption {
+	return func(c *LoggerConfig) {
+		c.AuthHeader = name
+		c.AuthScheme = scheme
+	}
 }
 
-// Warn logs a warning message
-func (l *Logger) Warn(ctx context.Context, message string, tags map[string]interface{}) error {
-	return l.Log(ctx, LevelWarn, message, tags)
+// WithLoggerAutoEnv tags every log with environment details read from
+// well-known variables: env (ENV, APP_ENV, ...), pod and namespace on
+// Kubernetes, and cloud_provider and cloud_region. Detection runs once, in
+// NewLogger; tags added with WithContext take precedence. Skip individual
+// detectors with WithLoggerAutoEnvSkip.
+//
+// Example:
+//
+//	logger := logdot.NewLogger(apiKey, "my-service", logdot.WithLoggerAutoEnv(true))
+func WithLoggerAutoEnv(enabled bool) LoggerOption {
+	return func(c *LoggerConfig) {
+		c.AutoEnv = enabled
+	}
 }
 
-// Error logs an error message
-func (l *Logger) Error(ctx context.Context, message string, tags map[string]interface{}) error {
-	return l.Log(ctx, LevelError, message, tags)
+// WithLoggerAutoEnvSkip disables the given WithLoggerAutoEnv detectors
+//
+// Example:
+//
+//	logdot.WithLoggerAutoEnvSkip(logdot.EnvDetectorCloud)
+func WithLoggerAutoEnvSkip(detectors ...EnvDetector) LoggerOption {
+	return func(c *LoggerConfig) {
+		c.AutoEnvSkip = append(c.AutoEnvSkip, detectors...)
+	}
 }
 
-// Log sends a log entry at the specified level
-func (l *Logger) Log(ctx context.Context, level LogLevel, message string, tags map[string]interface{}) error {
-	mergedTags := l.mergeTags(tags)
-	entry := LogEntry{
-		Message: message,
-		Level:   level,
-		Tags:    mergedTags,
+// WithLoggerValidatePayload checks every outgoing log payload, in debug
+// mode only, against the shape the logs API expects (hostname present,
+// non-empty messages, known severities, RFC 3339 timestamps) and prints
+// violations in debug output. Payloads are sent regardless. Use it in
+// development and integration tests to catch serialization regressions.
+func WithLoggerValidatePayload(enabled bool) LoggerOption {
+	return func(c *LoggerConfig) {
+		c.ValidatePayload = enabled
 	}
+}
 
-	l.mu.Lock()
-	if l.batchMode {
-		l.batchQueue = append(l.batchQueue, entry)
-		l.mu.Unlock()
-		return nil
+// WithLoggerSuccessCodes sets the HTTP statuses that count as a
+// successful send, replacing the default of 200 and 201, e.g. for
+// gateways that answer 202 Accepted for asynchronous ingestion
+//
+// Example:
+//
+//	logdot.WithLoggerSuccessCodes(http.StatusOK, http.StatusCreated, http.StatusAccepted)
+func WithLoggerSuccessCodes(codes ...int) LoggerOption {
+	return func(c *LoggerConfig) {
+		c.SuccessCodes = codes
 	}
-	l.mu.Unlock()
+}
 
-	return l.sendLog(ctx, entry)
+// WithLoggerDedup collapses consecutive identical entries (same message,
+// level and tags) queued in batch mode within window of each other into a
+// single entry with a count tag, like syslog's "last message repeated N
+// times"
+func WithLoggerDedup(window time.Duration) LoggerOption {
+	return func(c *LoggerConfig) {
+		c.DedupWindow = window
+	}
 }
 
-// BeginBatch starts batch mode
-func (l *Logger) BeginBatch() {
-	l.mu.Lock()
-	defer l.mu.Unlock()
-	l.batchMode = true
-	l.batchQueue = make([]LogEntry, 0)
+// WithLoggerMaxQueueSize caps the number of entries queued in batch mode.
+// When the queue is full, the oldest entry of the lowest severity is
+// dropped to make room, so debug entries go before errors. An incoming
+// entry less severe than everything queued is dropped instead.
+func WithLoggerMaxQueueSize(n int) LoggerOption {
+	return func(c *LoggerConfig) {
+		c.MaxQueueSize = n
+	}
 }
 
-// SendBatch sends all queued logs
-func (l *Logger) SendBatch(ctx context.Context) error {
-	l.mu.Lock()
-	if !l.batchMode || len(l.batchQueue) == 0 {
-		l.mu.Unlock()
-		return nil
+// WithLoggerOnSuccess registers a callback invoked after logs are
+// acknowledged with a success status, for single sends and batches alike.
+// resp holds the parsed response body, or is nil if it could not be
+// parsed. The callback runs on the sending goroutine after the request
+// completes, outside any logger lock; keep it fast. Panics in it are
+// recovered.
+//
+// Example:
+//
+//	logdot.WithLoggerOnSuccess(func(entries []logdot.LogEntry, resp *logdot.APIResponse) {
+//		auditTrail.RecordAcknowledged(entries)
+//	})
+func WithLoggerOnSuccess(fn func(entries []LogEntry, resp *APIResponse)) LoggerOption {
+	return func(c *LoggerConfig) {
+		c.OnSuccess = fn
 	}
+}
 
-	logs := make([]LogEntry, len(l.batchQueue))
-	copy(logs, l.batchQueue)
-	l.mu.Unlock()
+// WithLoggerFieldNames renames the JSON keys of sent log entries, for
+// backends behind a proxy that expect different names. Empty fields keep
+// the defaults.
+//
+// Example:
+//
+//	logdot.WithLoggerFieldNames(logdot.FieldNameMap{
+//		Message:  "msg",
+//		Severity: "level",
+//		Hostname: "host",
+//		Tags:     "labels",
+//	})
+func WithLoggerFieldNames(names FieldNameMap) LoggerOption {
+	return func(c *LoggerConfig) {
+		c.FieldNames = names
+	}
+}
 
-	payload := BatchLogsPayload{
-		Hostname: l.hostname,
-		Logs:     logs,
+// WithLoggerContextExtractor adds the tags returned by extractor for each
+// log call's context. Tags set on the logger or passed to the call take
+// precedence over extracted ones.
+//
+// Example:
+//
+//	logdot.WithLoggerContextExtractor(func(ctx context.Context) map[string]interface{} {
+//		if tenant, ok := ctx.Value(tenantKey{}).(string); ok {
+//			return map[string]interface{}{"tenant": tenant}
+//		}
+//		return nil
+//	})
+func WithLoggerContextExtractor(extractor ContextExtractor) LoggerOption {
+	return func(c *LoggerConfig) {
+		c.ContextExtractor = extractor
 	}
+}
 
-	url := baseLogsURL + "/logs/batch"
-	resp, _, err := l.http.Post(ctx, url, payload)
-	if err != nil {
-		return err
+// WithLoggerErrorClassifier registers a classifier that picks the level
+// for LogErrWithLevel. Classifiers are tried in registration order; errors
+// none of them recognize are logged at error level (info for
+// context.Canceled).
+//
+// Example:
+//
+//	logdot.WithLoggerErrorClassifier(func(err error) (logdot.LogLevel, bool) {
+//		var verr *ValidationError
+//		if errors.As(err, &verr) {
+//			return logdot.LevelWarn, true
+//		}
+//		return "", false
+//	})
+func WithLoggerErrorClassifier(classifier ErrorClassifier) LoggerOption {
+	return func(c *LoggerConfig) {
+		c.ErrorClassifiers = append(c.ErrorClassifiers, classifier)
 	}
+}
 
-	if resp.StatusCode != 200 && resp.StatusCode != 201 {
-		return fmt.Errorf("batch send failed with status %d", resp.StatusCode)
+// WithLoggerContextKeys adds the values stored under keys in each log
+// call's context as tags, skipping nil values. Tags are named by the key's
+// fmt.Sprint form, so use string-based key types or give keys a String
+// method. Combines with WithLoggerContextExtractor, which wins on
+// conflicts.
+//
+// Example:
+//
+//	type ctxKey string
+//	const requestIDKey ctxKey = "request_id"
+//
+//	logger := logdot.NewLogger(apiKey, "my-service", logdot.WithLoggerContextKeys(requestIDKey))
+//	ctx = context.WithValue(ctx, requestIDKey, "abc-123")
+//	logger.Info(ctx, "handled", nil) // tagged request_id=abc-123
+func WithLoggerContextKeys(keys ...interface{}) LoggerOption {
+	return func(c *LoggerConfig) {
+		c.ContextKeys = append(c.ContextKeys, keys...)
 	}
+}
 
-	l.ClearBatch()
-	return nil
+// WithLoggerEmptyMessage sets the placeholder sent instead of an empty or
+// whitespace-only message. Defaults to "(no message)"; a source tag, if
+// present, is appended.
+func WithLoggerEmptyMessage(placeholder string) LoggerOption {
+	return func(c *LoggerConfig) {
+		c.EmptyMessage = placeholder
+	}
 }
 
-// EndBatch exits batch mode
-func (l *Logger) EndBatch() {
-	l.mu.Lock()
-	defer l.mu.Unlock()
-	l.batchMode = false
-	l.batchQueue = make([]LogEntry, 0)
+// WithLoggerCompactBatches factors tags shared by every entry of a batch
+// out into the payload's common_tags section instead of repeating them per
+// entry. The backend applies common tags to each entry of the batch.
+func WithLoggerCompactBatches(enabled bool) LoggerOption {
+	return func(c *LoggerConfig) {
+		c.CompactBatches = enabled
+	}
 }
 
-// ClearBatch clears the batch queue
-func (l *Logger) ClearBatch() {
-	l.mu.Lock()
-	defer l.mu.Unlock()
-	l.batchQueue = make([]LogEntry, 0)
+// WithLoggerTagSchema enforces schema on the tags of every log entry, to
+// keep a shared tag taxonomy consistent. See TagSchema for how strict and
+// lenient mode treat violations.
+//
+// Example:
+//
+//	logdot.WithLoggerTagSchema(logdot.TagSchema{
+//		Required: []string{"team"},
+//		Types:    map[string]logdot.TagType{"user_id": logdot.TagNumber},
+//		Strict:   true,
+//	})
+func WithLoggerTagSchema(schema TagSchema) LoggerOption {
+	return func(c *LoggerConfig) {
+		c.TagSchema = &schema
+	}
 }
 
-// BatchSize returns the number of queued logs
-func (l *Logger) BatchSize() int {
-	l.mu.Lock()
-	defer l.mu.Unlock()
-	return len(l.batchQueue)
+// WithLoggerStripANSI removes ANSI escape sequences (colors, cursor
+// movement) from log messages, for output forwarded from CLI tools. It
+// applies to every log call, including those made through SlogHandler and
+// NewWriter.
+func WithLoggerStripANSI(enabled bool) LoggerOption {
+	return func(c *LoggerConfig) {
+		c.StripANSI = enabled
+	}
 }
 
-// Hostname returns the configured hostname
-func (l *Logger) Hostname() string {
-	return l.hostname
+// WithLoggerName sets a logical logger name, sent as the "logger" tag on
+// every entry. Unlike the hostname it identifies a subsystem rather than a
+// machine, and it is inherited by loggers derived with WithContext.
+//
+// Example:
+//
+//	logger := logdot.NewLogger(apiKey, "web-01", logdot.WithLoggerName("payments.worker"))
+func WithLoggerName(name string) LoggerOption {
+	return func(c *LoggerConfig) {
+		c.Name = name
+	}
 }
 
-// SetDebug enables or disables debug output
-func (l *Logger) SetDebug(enabled bool) {
-	l.debug = enabled
+// WithLoggerStreamBatches streams batches of at least minEntries entries:
+// the JSON payload is encoded while the request is being sent instead of
+// being marshaled in full first, which keeps memory flat when flushing a
+// large backlog. Applies to SendBatch, SendEntries and the other batch
+// sends; ignored with a custom Serializer.
+func WithLoggerStreamBatches(minEntries int) LoggerOption {
+	return func(c *LoggerConfig) {
+		c.StreamBatches = minEntries
+	}
 }
 
-func (l *Logger) sendLog(ctx context.Context, entry LogEntry) error {
-	entry.Hostname = l.hostname
+// WithLoggerHeartbeat sends an info-level "Heartbeat" log with an
+// uptime_seconds tag when the logger is created and then every interval,
+// so silently dead processes can be detected. Heartbeats are sent
+// immediately, even in batch mode or below the minimum level. Stop them
+// with Close.
+func WithLoggerHeartbeat(interval time.Duration) LoggerOption {
+	return func(c *LoggerConfig) {
+		c.HeartbeatInterval = interval
+	}
+}
 
-	url := baseLogsURL + "/logs"
-	resp, _, err := l.http.Post(ctx, url, entry)
-	if err != nil {
-		return err
+// WithLoggerHeartbeatMetrics also sends each heartbeat as a heartbeat.uptime
+// metric (seconds) through metrics. Requires WithLoggerHeartbeat.
+func WithLoggerHeartbeatMetrics(metrics *BoundMetrics) LoggerOption {
+	return func(c *LoggerConfig) {
+		c.HeartbeatMetrics = metrics
 	}
+}
 
-	if resp.StatusCode != 200 && resp.StatusCode != 201 {
-		return fmt.Errorf("log send failed with status %d", resp.StatusCode)
+// WithLoggerQueueWarnThreshold warns when the batch queue reaches n
+// unsent entries, and again each time it doubles, to catch a BeginBatch
+// whose SendBatch was forgotten. Warnings go to stderr, even without
+// debug mode, unless WithLoggerOnQueueWarning is set.
+func WithLoggerQueueWarnThreshold(n int) LoggerOption {
+	return func(c *LoggerConfig) {
+		c.QueueWarnThreshold = n
 	}
+}
 
-	return nil
+// WithLoggerOnQueueWarning receives the queue size of each
+// WithLoggerQueueWarnThreshold warning instead of stderr
+//
+// Example:
+//
+//	logdot.WithLoggerOnQueueWarning(func(size int) {
+//		queueGauge.Set(float64(size))
+//	})
+func WithLoggerOnQueueWarning(fn func(size int)) LoggerOption {
+	return func(c *LoggerConfig) {
+		c.OnQueueWarning = fn
+	}
+}
+
+// WithLoggerClock sets the time source used for retry backoff
+func WithLoggerClock(clock Clock) LoggerOption {
+	return func(c *LoggerConfig) {
+		c.Clock = clock
+	}
+}
+
+// WithLoggerMinLevel drops logs below the given level. A level set on the
+// context with WithLevel takes precedence.
+func WithLoggerMinLevel(level LogLevel) LoggerOption {
+	return func(c *LoggerConfig) {
+		c.MinLevel = level
+	}
+}
+
+// WithContext creates a new Logger with additional context that will be merged with all log tags.
+// The returned logger shares the same HTTP client but has its own context.
+//
+// Example:
+//
+//	logger := logdot.NewLogger("apiKey", "my-service")
+//	userLogger := logger.WithContext(map[string]interface{}{"user_id": 123})
+//	userLogger.Info(ctx, "User action", nil) // Will include user_id
+func (l *Logger) WithContext(ctx map[string]interface{}) *Logger {
+	// Merge existing context with new context. The result is normalized
+	// once here and never mutated afterwards, so mergeTags can share it.
+	mergedCtx := make(map[string]interface{}, len(l.logCtx)+len(ctx))
+	for k, v := range l.logCtx {
+		mergedCtx[k] = v
+	}
+	for k, v := range ctx {
+		mergedCtx[k], _ = normalizeNumber(v)
+	}
+
+	return &Logger{
+		http:             l.http,
+		hostname:         l.hostname,
+		debug:            l.debug,
+		batchID:          l.batchID,
+		minLevel:         l.minLevel,
+		sendSem:          l.sendSem,
+		config:           l.config,
+		logCtx:           mergedCtx,
+		batchMode:        false,
+		batchQueue:       make([]LogEntry, 0),
+		dedupWindow:      l.dedupWindow,
+		maxQueueSize:     l.maxQueueSize,
+		onSuccess:        l.onSuccess,
+		fieldNames:       l.fieldNames,
+		batches:          l.batches,
+		contextExtractor: l.contextExtractor,
+		onceKeys:         l.onceKeys,
+		emptyMessage:     l.emptyMessage,
+		compactBatches:   l.compactBatches,
+		errorClassifiers: l.errorClassifiers,
+		stripANSI:        l.stripANSI,
+		tagSchema:        l.tagSchema,
+		heartbeat:        l.heartbeat,
+	}
+}
+
+// MergeContext creates a new Logger whose context is the union of this
+// logger's context and other's, with other taking precedence. The result
+// keeps this logger's configuration and HTTP client.
+//
+// Example:
+//
+//	combined := serviceLogger.MergeContext(requestLogger)
+func (l *Logger) MergeContext(other *Logger) *Logger {
+	if other == nil {
+		return l.WithContext(nil)
+	}
+	return l.WithContext(other.GetContext())
+}
+
+// GetContext returns a copy of the current context
+func (l *Logger) GetContext() map[string]interface{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	result := make(map[string]interface{})
+	for k, v := range l.logCtx {
+		result[k] = v
+	}
+	return result
+}
+
+// mergeTags merges context with provided tags (tags take precedence).
+// Numeric values are normalized with normalizeNumber.
+//
+// To avoid allocating on every call, the logger's context map is returned
+// as-is when there are no tags, and tags is returned as-is when there is
+// no context and nothing to normalize; borrowed reports the latter case,
+// where the result is the caller's map. Returned maps must not be mutated.
+// NoTags is passed through so prepare can skip context extraction.
+func (l *Logger) mergeTags(tags map[string]interface{}) (merged map[string]interface{}, borrowed bool) {
+	if isNoTags(tags) {
+		return noTags, false
+	}
+	if len(tags) == 0 {
+		if len(l.logCtx) == 0 {
+			return nil, false
+		}
+		return l.logCtx, false
+	}
+	if len(l.logCtx) == 0 && !needsNormalizing(tags) {
+		return tags, true
+	}
+
+	merged = make(map[string]interface{}, len(l.logCtx)+len(tags))
+	for k, v := range l.logCtx {
+		merged[k] = v
+	}
+	for k, v := range tags {
+		merged[k], _ = normalizeNumber(v)
+	}
+	return merged, false
+}
+
+// needsNormalizing reports whether any tag value would be changed by
+// normalizeNumber
+func needsNormalizing(tags map[string]interface{}) bool {
+	for _, v := range tags {
+		if _, changed := normalizeNumber(v); changed {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeNumber gives numeric tag values a consistent type so the same
+// field is not sometimes an integer and sometimes a float depending on the
+// path it took (e.g. slog reports int64, decoded JSON reports float64).
+// Integer types and integer-valued floats become int; other floats become
+// float64. Values that don't fit in an int, and non-numeric values, are
+// returned unchanged. changed reports whether the value was converted.
+func normalizeNumber(v interface{}) (result interface{}, changed bool) {
+	switch n := v.(type) {
+	case int8:
+		return int(n), true
+	case int16:
+		return int(n), true
+	case int32:
+		return int(n), true
+	case int64:
+		if n >= math.MinInt && n <= math.MaxInt {
+			return int(n), true
+		}
+	case uint:
+		if n <= math.MaxInt {
+			return int(n), true
+		}
+	case uint8:
+		return int(n), true
+	case uint16:
+		return int(n), true
+	case uint32:
+		if uint64(n) <= math.MaxInt {
+			return int(n), true
+		}
+	case uint64:
+		if n <= math.MaxInt {
+			return int(n), true
+		}
+	case float32:
+		if i, ok := floatToInt(float64(n)); ok {
+			return i, true
+		}
+		return float64(n), true
+	case float64:
+		if i, ok := floatToInt(n); ok {
+			return i, true
+		}
+	}
+	return v, false
+}
+
+// floatToInt converts whole-number floats that fit in an int
+func floatToInt(f float64) (int, bool) {
+	if f == math.Trunc(f) && f >= math.MinInt && f < math.MaxInt {
+		return int(f), true
+	}
+	return 0, false
+}
+
+// Debug logs a debug message
+func (l *Logger) Debug(ctx context.Context, message string, tags map[string]interface{}) error {
+	return l.Log(ctx, LevelDebug, message, tags)
+}
+
+// Info logs an info message
+func (l *Logger) Info(ctx context.Context, message string, tags map[string]interface{}) error {
+	return l.Log(ctx, LevelInfo, message, tags)
+}
+
+// Warn logs a warning message
+func (l *Logger) Warn(ctx context.Context, message string, tags map[string]interface{}) error {
+	return l.Log(ctx, LevelWarn, message, tags)
+}
+
+// Error logs an error message
+func (l *Logger) Error(ctx context.Context, message string, tags map[string]interface{}) error {
+	return l.Log(ctx, LevelError, message, tags)
+}
+
+// Debugf logs a formatted debug message
+func (l *Logger) Debugf(ctx context.Context, format string, args ...interface{}) error {
+	return l.LogTemplate(ctx, LevelDebug, format, args...)
+}
+
+// Infof logs a formatted info message
+func (l *Logger) Infof(ctx context.Context, format string, args ...interface{}) error {
+	return l.LogTemplate(ctx, LevelInfo, format, args...)
+}
+
+// Warnf logs a formatted warning message
+func (l *Logger) Warnf(ctx context.Context, format string, args ...interface{}) error {
+	return l.LogTemplate(ctx, LevelWarn, format, args...)
+}
+
+// Errorf logs a formatted error message
+func (l *Logger) Errorf(ctx context.Context, format string, args ...interface{}) error {
+	return l.LogTemplate(ctx, LevelError, format, args...)
+}
+
+// Enabled reports whether logs at the given level would be sent. Use it to
+// skip building expensive tags for logs that would be dropped.
+//
+// Example:
+//
+//	if logger.Enabled(logdot.LevelDebug) {
+//		logger.Debug(ctx, "State dump", expensiveTags())
+//	}
+func (l *Logger) Enabled(level LogLevel) bool {
+	return l.EnabledContext(context.Background(), level)
+}
+
+// EnabledContext is like Enabled but also honours a level override set on
+// ctx with WithLevel, and reports false for a ctx sampled out with
+// WithSampled.
+func (l *Logger) EnabledContext(ctx context.Context, level LogLevel) bool {
+	if sampled, ok := sampledFromContext(ctx); ok && !sampled {
+		return false
+	}
+	minLevel := l.minLevel
+	if override, ok := levelFromContext(ctx); ok {
+		minLevel = override
+	}
+	return minLevel == "" || levelRank(level) >= levelRank(minLevel)
+}
+
+// Log sends a log entry at the specified level
+func (l *Logger) Log(ctx context.Context, level LogLevel, message string, tags map[string]interface{}) error {
+	if !l.EnabledContext(ctx, level) {
+		return nil
+	}
+
+	mergedTags, borrowed := l.mergeTags(tags)
+	return l.dispatch(ctx, LogEntry{
+		Message: message,
+		Level:   level,
+		Tags:    mergedTags,
+	}, borrowed)
+}
+
+// LogTemplate logs fmt.Sprintf(template, args...) at level, formatting
+// only once the entry has passed the level and sampling checks. Entries
+// that are filtered out cost no formatting, which keeps heavily filtered
+// debug logging cheap on the hot path. Debugf, Infof, Warnf and Errorf
+// use it.
+//
+// Example:
+//
+//	logger.LogTemplate(ctx, logdot.LevelDebug, "cache state: %v", cache)
+func (l *Logger) LogTemplate(ctx context.Context, level LogLevel, template string, args ...interface{}) error {
+	if !l.EnabledContext(ctx, level) {
+		return nil
+	}
+
+	mergedTags, borrowed := l.mergeTags(nil)
+	return l.dispatch(ctx, LogEntry{
+		Message: fmt.Sprintf(template, args...),
+		Level:   level,
+		Tags:    mergedTags,
+	}, borrowed)
+}
+
+// LogAt sends a log entry stamped with the event time t instead of the time
+// it is received, e.g. when backfilling or replaying logs from another
+// source. The timestamp is sent in UTC.
+//
+// Example:
+//
+//	for _, rec := range archived {
+//		logger.LogAt(ctx, rec.Time, logdot.LevelInfo, rec.Message, rec.Tags)
+//	}
+func (l *Logger) LogAt(ctx context.Context, t time.Time, level LogLevel, message string, tags map[string]interface{}) error {
+	if !l.EnabledContext(ctx, level) {
+		return nil
+	}
+
+	ts := t.UTC()
+	mergedTags, borrowed := l.mergeTags(tags)
+	return l.dispatch(ctx, LogEntry{
+		Message:   message,
+		Level:     level,
+		Tags:      mergedTags,
+		Timestamp: &ts,
+	}, borrowed)
+}
+
+// LogAll logs each message at level with the same tags. In batch mode the
+// entries are queued; otherwise they are sent together in a single batch
+// request.
+//
+// Example:
+//
+//	logger.LogAll(ctx, logdot.LevelWarn, validationErrors, map[string]interface{}{
+//		"import_id": importID,
+//	})
+func (l *Logger) LogAll(ctx context.Context, level LogLevel, messages []string, tags map[string]interface{}) error {
+	if len(messages) == 0 || !l.EnabledContext(ctx, level) {
+		return nil
+	}
+
+	mergedTags, borrowed := l.mergeTags(tags)
+
+	l.mu.Lock()
+	batching := l.batchMode
+	l.mu.Unlock()
+
+	if batching {
+		for _, message := range messages {
+			if err := l.dispatch(ctx, LogEntry{Message: message, Level: level, Tags: mergedTags}, borrowed); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	logs := make([]LogEntry, len(messages))
+	for i, message := range messages {
+		entry, _, err := l.prepare(ctx, LogEntry{Message: message, Level: level, Tags: mergedTags}, borrowed)
+		if err != nil {
+			return err
+		}
+		logs[i] = entry
+	}
+	return l.postBatch(ctx, logs)
+}
+
+// LogIndexed sends a log entry whose indexed tags (low cardinality,
+// searchable, e.g. region or status) are kept apart from its fields (high
+// cardinality, stored only, e.g. user or request IDs). The logger's
+// context is merged into the indexed tags.
+//
+// Example:
+//
+//	logger.LogIndexed(ctx, logdot.LevelInfo, "checkout complete",
+//		map[string]interface{}{"region": "eu-west", "plan": "pro"},
+//		map[string]interface{}{"order_id": orderID, "user_id": userID},
+//	)
+func (l *Logger) LogIndexed(ctx context.Context, level LogLevel, message string, indexed, fields map[string]interface{}) error {
+	if !l.EnabledContext(ctx, level) {
+		return nil
+	}
+
+	mergedTags, borrowed := l.mergeTags(indexed)
+	entry := LogEntry{
+		Message: message,
+		Level:   level,
+		Tags:    mergedTags,
+	}
+	if len(fields) > 0 {
+		entry.Fields = make(map[string]interface{}, len(fields))
+		for k, v := range fields {
+			entry.Fields[k], _ = normalizeNumber(v)
+		}
+	}
+	return l.dispatch(ctx, entry, borrowed)
+}
+
+// Event records a discrete event such as a deploy or config change. It is
+// sent as an info log whose message is eventType, tagged with event_type
+// and the payload. Events are not subject to the minimum level.
+//
+// Example:
+//
+//	logger.Event(ctx, "deploy", map[string]interface{}{
+//		"version": "1.4.2",
+//		"commit":  "a1b2c3d",
+//	})
+func (l *Logger) Event(ctx context.Context, eventType string, payload map[string]interface{}) error {
+	merged, _ := l.mergeTags(payload)
+	tags := make(map[string]interface{}, len(merged)+1)
+	for k, v := range merged {
+		tags[k] = v
+	}
+	tags["event_type"] = eventType
+
+	return l.dispatch(ctx, LogEntry{
+		Message: eventType,
+		Level:   LevelInfo,
+		Tags:    tags,
+	}, false)
+}
+
+// placeholderMessage returns the message used in place of a blank one,
+// naming the tags' source when there is one
+func (l *Logger) placeholderMessage(tags map[string]interface{}) string {
+	if source, ok := tags["source"]; ok {
+		return fmt.Sprintf("%s [source: %v]", l.emptyMessage, source)
+	}
+	return l.emptyMessage
+}
+
+// LogOnce logs like Log, but only the first time it is called with key on
+// this logger or any logger derived from it. Later calls with the same key
+// are ignored, even if the first one was filtered out or failed to send.
+// Use it for warnings that should appear once per process, such as use of
+// a deprecated setting.
+//
+// Example:
+//
+//	logger.LogOnce(ctx, "deprecated-timeout", logdot.LevelWarn,
+//		"REQUEST_TIMEOUT is deprecated, use HTTP_TIMEOUT", nil)
+func (l *Logger) LogOnce(ctx context.Context, key string, level LogLevel, message string, tags map[string]interface{}) error {
+	if _, seen := l.onceKeys.LoadOrStore(key, struct{}{}); seen {
+		return nil
+	}
+	return l.Log(ctx, level, message, tags)
+}
+
+// dispatch queues entry in batch mode or sends it. borrowed reports
+// whether entry.Tags belongs to the caller.
+func (l *Logger) dispatch(ctx context.Context, entry LogEntry, borrowed bool) error {
+	entry, borrowed, err := l.prepare(ctx, entry, borrowed)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	if l.batchMode {
+		if borrowed {
+			// Queued entries outlive this call; don't keep the caller's map
+			entry.Tags = copyTags(entry.Tags)
+		}
+		l.enqueue(entry)
+		warnSize := l.queueWarning()
+		l.mu.Unlock()
+		if warnSize > 0 {
+			l.warnQueue(warnSize)
+		}
+		return nil
+	}
+	l.mu.Unlock()
+
+	return l.sendLog(ctx, entry)
+}
+
+// prepare strips ANSI codes if enabled, replaces an empty message, adds
+// tags from the context extractor and enforces the tag schema. The
+// returned flag is false once the tags are no longer the caller's map.
+func (l *Logger) prepare(ctx context.Context, entry LogEntry, borrowed bool) (LogEntry, bool, error) {
+	if l.stripANSI {
+		entry.Message = stripANSI(entry.Message)
+	}
+
+	if isNoTags(entry.Tags) {
+		if strings.TrimSpace(entry.Message) == "" {
+			entry.Message = l.placeholderMessage(nil)
+		}
+		return entry, false, nil
+	}
+
+	if strings.TrimSpace(entry.Message) == "" {
+		entry.Message = l.placeholderMessage(entry.Tags)
+		l.debugLog("Empty log message replaced with: " + entry.Message)
+	}
+
+	if l.contextExtractor != nil && ctx != nil {
+		if extracted := l.contextExtractor(ctx); len(extracted) > 0 {
+			entry.Tags = addExtractedTags(entry.Tags, extracted)
+			borrowed = false
+		}
+	}
+
+	if l.tagSchema != nil {
+		checked, dropped, problems := l.tagSchema.check(entry.Tags)
+		if len(problems) > 0 {
+			if l.tagSchema.Strict {
+				return entry, borrowed, fmt.Errorf("tag schema: %s", strings.Join(problems, "; "))
+			}
+			l.debugLog("Tag schema: " + strings.Join(problems, "; "))
+		}
+		if dropped {
+			entry.Tags = checked
+			borrowed = false
+		}
+	}
+	return entry, borrowed, nil
+}
+
+// enqueue appends entry to the batch queue, collapsing it into the last
+// entry when deduplication applies. Must be called with l.mu held.
+func (l *Logger) enqueue(entry LogEntry) {
+	if l.dedupWindow <= 0 {
+		if l.makeRoom(entry) {
+			l.batchQueue = append(l.batchQueue, entry)
+			if len(l.batchQueue) == 1 {
+				l.trackBatch()
+			}
+		}
+		return
+	}
+
+	now := l.http.clock.Now()
+	if n := len(l.batchQueue); l.dedupCount > 0 && n > 0 && now.Sub(l.lastQueuedAt) <= l.dedupWindow {
+		last := &l.batchQueue[n-1]
+		if last.Message == entry.Message && last.Level == entry.Level &&
+			reflect.DeepEqual(l.dedupTags, entry.Tags) && reflect.DeepEqual(last.Fields, entry.Fields) &&
+			reflect.DeepEqual(last.Timestamp, entry.Timestamp) {
+			l.dedupCount++
+			tags := copyTags(l.dedupTags)
+			tags["count"] = l.dedupCount
+			last.Tags = tags
+			l.lastQueuedAt = now
+			return
+		}
+	}
+
+	if !l.makeRoom(entry) {
+		return
+	}
+	l.batchQueue = append(l.batchQueue, entry)
+	if len(l.batchQueue) == 1 {
+		l.trackBatch()
+	}
+	l.dedupCount = 1
+	l.dedupTags = entry.Tags
+	l.lastQueuedAt = now
+}
+
+// queueWarning returns the queue size to warn about under
+// QueueWarnThreshold, or 0. After a warning the next one is due when the
+// queue has doubled; once the queue drops below the threshold the warning
+// re-arms. Must be called with l.mu held.
+func (l *Logger) queueWarning() int {
+	threshold := l.config.QueueWarnThreshold
+	if threshold <= 0 {
+		return 0
+	}
+	n := len(l.batchQueue)
+	if n < threshold {
+		l.queueWarnAt = 0
+		return 0
+	}
+	if n < l.queueWarnAt {
+		return 0
+	}
+	l.queueWarnAt = 2 * n
+	return n
+}
+
+// warnQueue reports a batch queue that keeps growing without being sent,
+// through OnQueueWarning or else on stderr, independently of debug mode
+func (l *Logger) warnQueue(size int) {
+	if l.config.OnQueueWarning == nil {
+		fmt.Fprintf(os.Stderr, "[LogDotLogger] WARNING: batch queue holds %d unsent entries; call SendBatch or FlushAll\n", size)
+		return
+	}
+	defer func() { recover() }() //nolint:errcheck // never crash
+	l.config.OnQueueWarning(size)
+}
+
+// makeRoom enforces MaxQueueSize before entry is queued. It evicts the
+// oldest entry of the lowest severity, or reports false when entry itself
+// is the least severe and should be dropped. Must be called with l.mu held.
+func (l *Logger) makeRoom(entry LogEntry) bool {
+	if l.maxQueueSize <= 0 || len(l.batchQueue) < l.maxQueueSize {
+		return true
+	}
+
+	victim := l.evictionVictim()
+	if levelRank(entry.Level) < levelRank(l.batchQueue[victim].Level) {
+		l.debugLog("Batch queue full, dropped " + string(entry.Level) + " entry")
+		return false
+	}
+
+	l.debugLog("Batch queue full, dropped queued " + string(l.batchQueue[victim].Level) + " entry")
+	l.batchQueue = append(l.batchQueue[:victim], l.batchQueue[victim+1:]...)
+	return true
+}
+
+// evictionVictim returns the index of the oldest queued entry of the
+// lowest severity. Must be called with l.mu held and a non-empty queue.
+func (l *Logger) evictionVictim() int {
+	victim := 0
+	for i, queued := range l.batchQueue {
+		if levelRank(queued.Level) < levelRank(l.batchQueue[victim].Level) {
+			victim = i
+		}
+	}
+	return victim
+}
+
+// resetQueue empties the batch queue. Must be called with l.mu held.
+func (l *Logger) resetQueue() {
+	l.batchQueue = make([]LogEntry, 0)
+	l.dedupCount = 0
+	l.dedupTags = nil
+	l.trackBatch()
+}
+
+// trackBatch registers l for FlushAll while it has queued entries and
+// releases it once the queue drains, so the registry does not keep
+// per-request loggers alive after their batch is sent. Must be called
+// with l.mu held.
+func (l *Logger) trackBatch() {
+	if l.flushable && len(l.batchQueue) > 0 {
+		l.batches.add(l)
+	} else {
+		l.batches.remove(l)
+	}
+}
+
+// BeginBatch starts batch mode
+func (l *Logger) BeginBatch() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.batchMode = true
+	l.flushable = true
+	l.resetQueue()
+}
+
+// SendBatch sends all queued logs. The entries are taken out of the queue
+// for the send, so entries logged meanwhile are kept for the next batch;
+// if the send fails, the taken entries are returned to the front of the
+// queue.
+func (l *Logger) SendBatch(ctx context.Context) error {
+	l.mu.Lock()
+	if !l.batchMode || len(l.batchQueue) == 0 {
+		l.mu.Unlock()
+		return nil
+	}
+
+	logs := l.batchQueue
+	l.resetQueue()
+	l.mu.Unlock()
+
+	sent := make([]LogEntry, len(logs))
+	copy(sent, logs)
+	if err := l.postBatch(ctx, sent); err != nil {
+		l.requeue(logs)
+		return err
+	}
+	return nil
+}
+
+// requeue puts entries taken out for a send that failed back at the front
+// of the queue, ahead of anything logged while the send was in flight. If
+// that exceeds MaxQueueSize, the lowest-severity entries are evicted as in
+// makeRoom.
+func (l *Logger) requeue(logs []LogEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.batchQueue = append(logs, l.batchQueue...)
+	for l.maxQueueSize > 0 && len(l.batchQueue) > l.maxQueueSize {
+		victim := l.evictionVictim()
+		if victim == len(l.batchQueue)-1 {
+			// The entry repeats would be collapsed into is leaving the queue
+			l.dedupCount = 0
+			l.dedupTags = nil
+		}
+		l.debugLog("Batch queue full, dropped queued " + string(l.batchQueue[victim].Level) + " entry")
+		l.batchQueue = append(l.batchQueue[:victim], l.batchQueue[victim+1:]...)
+	}
+	l.trackBatch()
+}
+
+// FlushLevel sends only the queued entries at or above minLevel and keeps
+// the rest queued, e.g. to get errors out first in a short shutdown
+// window. If the send fails, the entries are returned to the front of the
+// queue.
+//
+// Example:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+//	defer cancel()
+//	logger.FlushLevel(ctx, logdot.LevelWarn)
+func (l *Logger) FlushLevel(ctx context.Context, minLevel LogLevel) error {
+	l.mu.Lock()
+	if !l.batchMode || len(l.batchQueue) == 0 {
+		l.mu.Unlock()
+		return nil
+	}
+
+	var logs []LogEntry
+	kept := make([]LogEntry, 0, len(l.batchQueue))
+	for _, entry := range l.batchQueue {
+		if levelRank(entry.Level) >= levelRank(minLevel) {
+			logs = append(logs, entry)
+		} else {
+			kept = append(kept, entry)
+		}
+	}
+	if len(logs) == 0 {
+		l.mu.Unlock()
+		return nil
+	}
+	if levelRank(l.batchQueue[len(l.batchQueue)-1].Level) >= levelRank(minLevel) {
+		// The entry repeats would be collapsed into is leaving the queue
+		l.dedupCount = 0
+		l.dedupTags = nil
+	}
+	l.batchQueue = kept
+	l.trackBatch()
+	l.mu.Unlock()
+
+	sent := make([]LogEntry, len(logs))
+	copy(sent, logs)
+	if err := l.postBatch(ctx, sent); err != nil {
+		l.requeue(logs)
+		return err
+	}
+	return nil
+}
+
+// RetryBatch re-sends the queued entries up to maxAttempts times, waiting
+// with exponential backoff between attempts. This runs on top of the
+// per-request retries. Like SendBatch, it takes the queued entries out for
+// the send: entries logged during the retries stay queued for the next
+// batch, and if every attempt fails the taken entries are returned to the
+// front of the queue and the last error is returned.
+//
+// Example:
+//
+//	if err := logger.SendBatch(ctx); err != nil {
+//		err = logger.RetryBatch(ctx, 5)
+//	}
+func (l *Logger) RetryBatch(ctx context.Context, maxAttempts int) error {
+	if maxAttempts <= 0 {
+		return fmt.Errorf("no batch attempts configured")
+	}
+
+	l.mu.Lock()
+	if !l.batchMode || len(l.batchQueue) == 0 {
+		l.mu.Unlock()
+		return nil
+	}
+	logs := l.batchQueue
+	l.resetQueue()
+	l.mu.Unlock()
+
+	var err error
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if attempt > 0 {
+			delay := calculateBackoff(l.http.retryConfig(), attempt-1)
+			l.debugLog(fmt.Sprintf("Batch retry %d/%d after %v - Error: %v", attempt+1, maxAttempts, delay, err))
+
+			select {
+			case <-ctx.Done():
+				l.requeue(logs)
+				return ctx.Err()
+			case <-l.http.clock.After(delay):
+			}
+		}
+
+		sent := make([]LogEntry, len(logs))
+		copy(sent, logs)
+		if err = l.postBatch(ctx, sent); err == nil {
+			return nil
+		}
+	}
+	l.requeue(logs)
+	return err
+}
+
+// SendEntries sends pre-built entries in a single batch request, without
+// using batch mode. Each entry keeps its own Hostname; entries without
+// one are attributed to the logger's hostname. Entries are sent as-is:
+// the logger's context and minimum level are not applied.
+//
+// Example:
+//
+//	err := logger.SendEntries(ctx, []logdot.LogEntry{
+//		{Message: "disk full", Level: logdot.LevelError, Hostname: "db-1"},
+//		{Message: "started", Level: logdot.LevelInfo, Hostname: "web-2"},
+//	})
+func (l *Logger) SendEntries(ctx context.Context, entries []LogEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	logs := make([]LogEntry, len(entries))
+	copy(logs, entries)
+	return l.postBatch(ctx, logs)
+}
+
+// postBatch sends logs to the batch endpoint. The slice may be modified.
+func (l *Logger) postBatch(ctx context.Context, logs []LogEntry) error {
+	if l.batchID {
+		tagBatch(logs, newUUID())
+	}
+
+	var payload interface{}
+	if l.streams(len(logs)) {
+		payload = l.streamBatch(logs)
+		if l.validatePayloads() {
+			l.reportPayload(l.wireBatch(logs), true)
+		}
+	} else {
+		payload = l.wireBatch(logs)
+		if l.validatePayloads() {
+			l.reportPayload(payload, true)
+		}
+	}
+
+	if err := l.acquireSend(ctx); err != nil {
+		return err
+	}
+	defer l.releaseSend()
+
+	url := baseLogsURL + "/logs/batch"
+	resp, body, err := l.http.Post(ctx, url, payload)
+	if err != nil {
+		return err
+	}
+
+	if !l.http.accepted(resp.StatusCode) {
+		return fmt.Errorf("batch send failed with status %d", resp.StatusCode)
+	}
+
+	l.notifySuccess(logs, body)
+	return nil
+}
+
+// EndBatch exits batch mode
+func (l *Logger) EndBatch() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.batchMode = false
+	l.flushable = false
+	l.resetQueue()
+}
+
+// FlushAll sends the queued logs of every logger in batch mode that
+// shares this logger's root, i.e. the logger returned by NewLogger and
+// all loggers derived from it with WithContext. Loggers stay in batch
+// mode; a logger whose send fails keeps its queue. The errors of failed
+// sends are joined.
+//
+// Example:
+//
+//	logger.BeginBatch()
+//	reqLogger := logger.WithContext(map[string]interface{}{"request_id": id})
+//	reqLogger.BeginBatch()
+//	...
+//	err := logger.FlushAll(ctx) // sends both batches
+func (l *Logger) FlushAll(ctx context.Context) error {
+	var errs []error
+	for _, logger := range l.batches.snapshot() {
+		if err := logger.SendBatch(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// batchRegistry is the set of loggers with queued batch entries within
+// one logger tree
+type batchRegistry struct {
+	mu      sync.Mutex
+	loggers map[*Logger]struct{}
+}
+
+func (r *batchRegistry) add(l *Logger) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.loggers[l] = struct{}{}
+}
+
+func (r *batchRegistry) remove(l *Logger) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.loggers, l)
+}
+
+func (r *batchRegistry) snapshot() []*Logger {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	loggers := make([]*Logger, 0, len(r.loggers))
+	for l := range r.loggers {
+		loggers = append(loggers, l)
+	}
+	return loggers
+}
+
+// ClearBatch clears the batch queue
+func (l *Logger) ClearBatch() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.resetQueue()
+}
+
+// BatchSize returns the number of queued logs
+func (l *Logger) BatchSize() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.batchQueue)
+}
+
+// Query reads logs back from LogDot, newest first, filtered by opts
+//
+// Example:
+//
+//	logs, err := logger.Query(ctx, logdot.QueryOptions{
+//		Level:  logdot.LevelError,
+//		Since:  time.Now().Add(-time.Hour),
+//		Search: "timeout",
+//		Limit:  50,
+//	})
+func (l *Logger) Query(ctx context.Context, opts QueryOptions) ([]LogEntry, error) {
+	params := url.Values{}
+	if opts.Level != "" {
+		params.Set("severity", string(opts.Level))
+	}
+	if opts.Hostname != "" {
+		params.Set("hostname", opts.Hostname)
+	}
+	if !opts.Since.IsZero() {
+		params.Set("from", opts.Since.UTC().Format(time.RFC3339Nano))
+	}
+	if !opts.Until.IsZero() {
+		params.Set("to", opts.Until.UTC().Format(time.RFC3339Nano))
+	}
+	if opts.Search != "" {
+		params.Set("search", opts.Search)
+	}
+	if opts.Limit > 0 {
+		params.Set("limit", strconv.Itoa(opts.Limit))
+	}
+
+	reqURL := baseLogsURL + "/logs"
+	if len(params) > 0 {
+		reqURL += "?" + params.Encode()
+	}
+
+	resp, body, err := l.http.Get(ctx, reqURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("log query failed with status %d", resp.StatusCode)
+	}
+
+	var result queryLogsResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse query response: %w", err)
+	}
+
+	return result.Data, nil
+}
+
+// Hostname returns the configured hostname
+func (l *Logger) Hostname() string {
+	return l.hostname
+}
+
+// Config returns a copy of the logger's resolved configuration, with the
+// API key masked. Slices, maps and the tag schema are copied, so changing
+// them does not affect the logger; HTTPClient, Transport and
+// HeartbeatMetrics are the live objects the logger uses.
+func (l *Logger) Config() LoggerConfig {
+	config := l.config
+	config.APIKey = maskAPIKey(config.APIKey)
+	config.Debug = l.debug
+
+	config.ContextKeys = append([]interface{}(nil), config.ContextKeys...)
+	config.ErrorClassifiers = append([]ErrorClassifier(nil), config.ErrorClassifiers...)
+	config.AutoEnvSkip = append([]EnvDetector(nil), config.AutoEnvSkip...)
+	config.SuccessCodes = append([]int(nil), config.SuccessCodes...)
+	if config.TagSchema != nil {
+		schema := *config.TagSchema
+		schema.Required = append([]string(nil), schema.Required...)
+		if schema.Types != nil {
+			schema.Types = make(map[string]TagType, len(config.TagSchema.Types))
+			for k, v := range config.TagSchema.Types {
+				schema.Types[k] = v
+			}
+		}
+		config.TagSchema = &schema
+	}
+
+	retry := l.http.retryConfig()
+	config.RetryAttempts = retry.MaxAttempts
+	config.RetryBaseDelay = retry.BaseDelay
+	config.RetryMaxDelay = retry.MaxDelay
+	return config
+}
+
+// RateLimitStatus returns the rate limit reported by the most recent
+// response that carried rate limit headers, so callers can slow down
+// before requests start failing with 429. UpdatedAt is zero until such a
+// response has been seen. Shared with derived loggers.
+func (l *Logger) RateLimitStatus() RateLimitStatus {
+	return l.http.rateLimitStatus()
+}
+
+// SetRetryConfig replaces the retry configuration at runtime, for this
+// logger and all loggers sharing its HTTP client (those derived with
+// WithContext). Requests already being retried keep their configuration;
+// new requests use the updated one.
+//
+// Example:
+//
+//	logger.SetRetryConfig(logdot.RetryConfig{
+//		MaxAttempts: 5,
+//		BaseDelay:   500 * time.Millisecond,
+//		MaxDelay:    10 * time.Second,
+//	})
+func (l *Logger) SetRetryConfig(retry RetryConfig) {
+	l.http.setRetryConfig(retry)
+}
+
+// SetDebug enables or disables debug output
+func (l *Logger) SetDebug(enabled bool) {
+	l.debug = enabled
+}
+
+func (l *Logger) sendLog(ctx context.Context, entry LogEntry) error {
+	entry.Hostname = l.hostname
+
+	if err := l.acquireSend(ctx); err != nil {
+		return err
+	}
+	defer l.releaseSend()
+
+	payload := l.wireEntry(entry)
+	if l.validatePayloads() {
+		l.reportPayload(payload, false)
+	}
+
+	url := baseLogsURL + "/logs"
+	resp, body, err := l.http.Post(ctx, url, payload)
+	if err != nil {
+		return err
+	}
+
+	if !l.http.accepted(resp.StatusCode) {
+		return fmt.Errorf("log send failed with status %d", resp.StatusCode)
+	}
+
+	l.notifySuccess([]LogEntry{entry}, body)
+	return nil
+}
+
+// resolveFieldNames fills in default keys, returning nil when every key
+// is the default so the plain struct encoding is used
+func resolveFieldNames(names FieldNameMap) *FieldNameMap {
+	resolved := FieldNameMap{
+		Message:  "message",
+		Severity: "severity",
+		Hostname: "hostname",
+		Tags:     "tags",
+		Fields:   "fields",
+	}
+	if names.Message != "" {
+		resolved.Message = names.Message
+	}
+	if names.Severity != "" {
+		resolved.Severity = names.Severity
+	}
+	if names.Hostname != "" {
+		resolved.Hostname = names.Hostname
+	}
+	if names.Tags != "" {
+		resolved.Tags = names.Tags
+	}
+	if names.Fields != "" {
+		resolved.Fields = names.Fields
+	}
+	if resolved == (FieldNameMap{"message", "severity", "hostname", "tags", "fields"}) {
+		return nil
+	}
+	return &resolved
+}
+
+// wireEntry returns entry in the shape sent to the backend
+func (l *Logger) wireEntry(entry LogEntry) interface{} {
+	if l.fieldNames == nil {
+		return entry
+	}
+	m := map[string]interface{}{
+		l.fieldNames.Message:  entry.Message,
+		l.fieldNames.Severity: entry.Level,
+	}
+	if entry.Hostname != "" {
+		m[l.fieldNames.Hostname] = entry.Hostname
+	}
+	if len(entry.Tags) > 0 || isNoTags(entry.Tags) {
+		m[l.fieldNames.Tags] = entry.Tags
+	}
+	if len(entry.Fields) > 0 {
+		m[l.fieldNames.Fields] = entry.Fields
+	}
+	if entry.Timestamp != nil {
+		m["timestamp"] = entry.Timestamp
+	}
+	return m
+}
+
+// wireBatch returns the batch payload in the shape sent to the backend
+func (l *Logger) wireBatch(logs []LogEntry) interface{} {
+	var common map[string]interface{}
+	if l.compactBatches {
+		common, logs = compactTags(logs)
+	}
+	if l.fieldNames == nil {
+		return BatchLogsPayload{Hostname: l.hostname, CommonTags: common, Logs: logs}
+	}
+	entries := make([]interface{}, len(logs))
+	for i, entry := range logs {
+		entries[i] = l.wireEntry(entry)
+	}
+	payload := map[string]interface{}{
+		l.fieldNames.Hostname: l.hostname,
+		"logs":                entries,
+	}
+	if len(common) > 0 {
+		payload["common_tags"] = common
+	}
+	return payload
+}
+
+// streams reports whether a batch of n entries is sent with streamBatch.
+// Only the JSON serializer can be streamed.
+func (l *Logger) streams(n int) bool {
+	if l.config.StreamBatches <= 0 || n < l.config.StreamBatches {
+		return false
+	}
+	_, isJSON := l.http.serializer.(JSONSerializer)
+	return isJSON
+}
+
+// streamBatch is like wireBatch but returns a body that encodes the
+// payload entry by entry as it is sent, so the marshaled batch is never
+// held in memory as a whole
+func (l *Logger) streamBatch(logs []LogEntry) streamBody {
+	var common map[string]interface{}
+	if l.compactBatches {
+		common, logs = compactTags(logs)
+	}
+	hostnameKey := "hostname"
+	if l.fieldNames != nil {
+		hostnameKey = l.fieldNames.Hostname
+	}
+
+	return func(w io.Writer) error {
+		bw := bufio.NewWriter(w)
+		enc := json.NewEncoder(bw)
+
+		bw.WriteByte('{')
+		if err := enc.Encode(hostnameKey); err != nil {
+			return err
+		}
+		bw.WriteByte(':')
+		if err := enc.Encode(l.hostname); err != nil {
+			return err
+		}
+		if len(common) > 0 {
+			bw.WriteString(`,"common_tags":`)
+			if err := enc.Encode(common); err != nil {
+				return err
+			}
+		}
+		bw.WriteString(`,"logs":[`)
+		for i, entry := range logs {
+			if i > 0 {
+				bw.WriteByte(',')
+			}
+			var v interface{} = entry
+			if l.fieldNames != nil {
+				v = l.wireEntry(entry)
+			}
+			if err := enc.Encode(v); err != nil {
+				return err
+			}
+		}
+		bw.WriteString("]}")
+		return bw.Flush()
+	}
+}
+
+// compactTags returns the tags whose key and value are identical across
+// every entry, and a copy of logs with those tags removed. The input
+// entries and their tag maps are left untouched. Batches of fewer than two
+// entries are returned as is.
+func compactTags(logs []LogEntry) (map[string]interface{}, []LogEntry) {
+	if len(logs) < 2 {
+		return nil, logs
+	}
+	common := make(map[string]interface{}, len(logs[0].Tags))
+	for k, v := range logs[0].Tags {
+		common[k] = v
+	}
+	for _, entry := range logs[1:] {
+		for k, v := range common {
+			other, ok := entry.Tags[k]
+			if !ok || !reflect.DeepEqual(v, other) {
+				delete(common, k)
+			}
+		}
+		if len(common) == 0 {
+			return nil, logs
+		}
+	}
+
+	compacted := make([]LogEntry, len(logs))
+	for i, entry := range logs {
+		var tags map[string]interface{}
+		if len(entry.Tags) > len(common) {
+			tags = make(map[string]interface{}, len(entry.Tags)-len(common))
+			for k, v := range entry.Tags {
+				if _, shared := common[k]; !shared {
+					tags[k] = v
+				}
+			}
+		}
+		entry.Tags = tags
+		compacted[i] = entry
+	}
+	return common, compacted
+}
+
+// notifySuccess invokes the OnSuccess callback, if any
+func (l *Logger) notifySuccess(entries []LogEntry, body []byte) {
+	if l.onSuccess == nil {
+		return
+	}
+	defer func() { recover() }() //nolint:errcheck // never crash
+
+	var apiResp *APIResponse
+	if len(body) > 0 {
+		var parsed APIResponse
+		if json.Unmarshal(body, &parsed) == nil {
+			apiResp = &parsed
+		}
+	}
+	l.onSuccess(entries, apiResp)
+}
+
+// ansiPattern matches CSI sequences (e.g. colors), OSC sequences (e.g.
+// window titles) and two-byte escapes
+var ansiPattern = regexp.MustCompile(`\x1b(?:\[[0-?]*[ -/]*[@-~]|\][^\x07\x1b]*(?:\x07|\x1b\\)|[@-Z\\-_])`)
+
+// stripANSI removes ANSI escape sequences from s
+func stripANSI(s string) string {
+	if !strings.Contains(s, "\x1b") {
+		return s
+	}
+	return ansiPattern.ReplaceAllString(s, "")
+}
+
+// copyTags returns a shallow copy of tags
+func copyTags(tags map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(tags))
+	for k, v := range tags {
+		result[k] = v
+	}
+	return result
+}
+
+// acquireSend waits for a send slot when concurrency is bounded
+func (l *Logger) acquireSend(ctx context.Context) error {
+	if l.sendSem == nil {
+		return nil
+	}
+	select {
+	case l.sendSem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *Logger) releaseSend() {
+	if l.sendSem != nil {
+		<-l.sendSem
+	}
+}
+
+// defaultHostname returns the OS hostname, falling back to "unknown"
+func defaultHostname() string {
+	name, err := os.Hostname()
+	if err != nil || name == "" {
+		return "unknown"
+	}
+	return name
+}
+
+// tagBatch sets a batch_id tag on every entry. Tag maps are copied so the
+// queued entries are left untouched if the send fails.
+func tagBatch(logs []LogEntry, id string) {
+	for i := range logs {
+		tags := make(map[string]interface{}, len(logs[i].Tags)+1)
+		for k, v := range logs[i].Tags {
+			tags[k] = v
+		}
+		tags["batch_id"] = id
+		logs[i].Tags = tags
+	}
+}
+
+// newUUID returns a random RFC 4122 version 4 UUID
+func newUUID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return ""
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
 }
 
 func (l *Logger) debugLog(message string) {
@@ -3,11 +3,64 @@ package logdot
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"io"
+	"math"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
+// redirectTransport sends every request to a test server, keeping the
+// path the client built against the real LogDot URL.
+type redirectTransport struct {
+	target *url.URL
+}
+
+func (t redirectTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r.URL.Scheme = t.target.Scheme
+	r.URL.Host = t.target.Host
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+// redirectToServer points an HTTPClient at the given test server.
+func redirectToServer(h *HTTPClient, server *httptest.Server) {
+	target, _ := url.Parse(server.URL)
+	h.client.Transport = redirectTransport{target: target}
+}
+
+// fakeClock is a Clock whose After fires immediately and records the
+// requested delays.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	delays []time.Duration
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	c.delays = append(c.delays, d)
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	ch <- c.Now()
+	return ch
+}
+
 func TestNewLogger(t *testing.T) {
 	logger := NewLogger("test_api_key", "test-service")
 
@@ -20,6 +73,18 @@ func TestNewLogger(t *testing.T) {
 	}
 }
 
+func TestNewLoggerDefaultsHostname(t *testing.T) {
+	logger := NewLogger("test_api_key", "")
+
+	expected, err := os.Hostname()
+	if err != nil || expected == "" {
+		expected = "unknown"
+	}
+	if logger.Hostname() != expected {
+		t.Errorf("Expected hostname '%s', got '%s'", expected, logger.Hostname())
+	}
+}
+
 func TestLoggerEmptyContext(t *testing.T) {
 	logger := NewLogger("test_api_key", "test-service")
 
@@ -75,6 +140,36 @@ func TestWithContextOverwrite(t *testing.T) {
 	}
 }
 
+func TestMergeContext(t *testing.T) {
+	base := NewLogger("test_api_key", "test-service").WithContext(map[string]interface{}{
+		"service": "api",
+		"env":     "dev",
+	})
+	request := NewLogger("test_api_key", "other-service").WithContext(map[string]interface{}{
+		"request_id": "abc",
+		"env":        "prod",
+	})
+
+	merged := base.MergeContext(request)
+	ctx := merged.GetContext()
+	if ctx["service"] != "api" || ctx["request_id"] != "abc" {
+		t.Errorf("Expected union of contexts, got %v", ctx)
+	}
+	if ctx["env"] != "prod" {
+		t.Errorf("Expected other's env 'prod' to win, got %v", ctx["env"])
+	}
+	if merged.Hostname() != "test-service" {
+		t.Errorf("Expected base hostname, got '%s'", merged.Hostname())
+	}
+	if len(base.GetContext()) != 2 {
+		t.Errorf("Base context should be unchanged, got %v", base.GetContext())
+	}
+
+	if len(base.MergeContext(nil).GetContext()) != 2 {
+		t.Error("Expected MergeContext(nil) to keep base context")
+	}
+}
+
 func TestGetContextReturnsCopy(t *testing.T) {
 	logger := NewLogger("test_api_key", "test-service")
 	loggerWithContext := logger.WithContext(map[string]interface{}{"key": "value"})
@@ -187,3 +282,1954 @@ func TestMergeTagsWithContext(t *testing.T) {
 		t.Errorf("Expected env 'prod', got '%v'", ctx["env"])
 	}
 }
+
+func TestSendBatchWithBatchID(t *testing.T) {
+	var payload BatchLogsPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&payload)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	logger := NewLogger("test_api_key", "test-service", WithLoggerBatchID(true))
+	redirectToServer(logger.http, server)
+
+	ctx := context.Background()
+	logger.BeginBatch()
+	logger.Info(ctx, "message 1", nil)
+	logger.Info(ctx, "message 2", map[string]interface{}{"key": "value"})
+
+	if err := logger.SendBatch(ctx); err != nil {
+		t.Fatalf("SendBatch failed: %v", err)
+	}
+
+	if len(payload.Logs) != 2 {
+		t.Fatalf("Expected 2 logs, got %d", len(payload.Logs))
+	}
+	id, _ := payload.Logs[0].Tags["batch_id"].(string)
+	if !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(id) {
+		t.Errorf("Expected UUID batch_id, got '%v'", payload.Logs[0].Tags["batch_id"])
+	}
+	if payload.Logs[1].Tags["batch_id"] != id {
+		t.Errorf("Expected shared batch_id %s, got %v", id, payload.Logs[1].Tags["batch_id"])
+	}
+	if payload.Logs[1].Tags["key"] != "value" {
+		t.Errorf("Expected key 'value', got %v", payload.Logs[1].Tags["key"])
+	}
+}
+
+func TestSendBatchWithoutBatchID(t *testing.T) {
+	var payload BatchLogsPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&payload)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	logger := NewLogger("test_api_key", "test-service")
+	redirectToServer(logger.http, server)
+
+	ctx := context.Background()
+	logger.BeginBatch()
+	logger.Info(ctx, "message", nil)
+
+	if err := logger.SendBatch(ctx); err != nil {
+		t.Fatalf("SendBatch failed: %v", err)
+	}
+	if _, ok := payload.Logs[0].Tags["batch_id"]; ok {
+		t.Error("Expected no batch_id tag by default")
+	}
+}
+
+func TestSendBatchCompactsCommonTags(t *testing.T) {
+	var payload BatchLogsPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&payload)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	logger := NewLogger("test_api_key", "test-service", WithLoggerCompactBatches(true))
+	redirectToServer(logger.http, server)
+
+	ctx := context.Background()
+	logger.BeginBatch()
+	logger.Info(ctx, "message 1", map[string]interface{}{"region": "eu", "deploy_id": "d1", "user": "a"})
+	logger.Info(ctx, "message 2", map[string]interface{}{"region": "eu", "deploy_id": "d1", "user": "b"})
+	logger.Info(ctx, "message 3", map[string]interface{}{"region": "eu", "deploy_id": "d1"})
+	queued := logger.batchQueue[0].Tags
+
+	if err := logger.SendBatch(ctx); err != nil {
+		t.Fatalf("SendBatch failed: %v", err)
+	}
+
+	if payload.CommonTags["region"] != "eu" || payload.CommonTags["deploy_id"] != "d1" {
+		t.Errorf("Expected region and deploy_id in common tags, got %v", payload.CommonTags)
+	}
+	if _, ok := payload.CommonTags["user"]; ok {
+		t.Error("Expected user to stay on the entries")
+	}
+	if len(payload.Logs[0].Tags) != 1 || payload.Logs[0].Tags["user"] != "a" {
+		t.Errorf("Expected only user tag on entry, got %v", payload.Logs[0].Tags)
+	}
+	if payload.Logs[2].Tags != nil {
+		t.Errorf("Expected no tags on fully shared entry, got %v", payload.Logs[2].Tags)
+	}
+	if queued["region"] != "eu" {
+		t.Error("Expected caller's tag map to be left untouched")
+	}
+}
+
+func TestSendBatchCompactionDisabledByDefault(t *testing.T) {
+	var payload BatchLogsPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&payload)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	logger := NewLogger("test_api_key", "test-service")
+	redirectToServer(logger.http, server)
+
+	ctx := context.Background()
+	logger.BeginBatch()
+	logger.Info(ctx, "message 1", map[string]interface{}{"region": "eu"})
+	logger.Info(ctx, "message 2", map[string]interface{}{"region": "eu"})
+
+	if err := logger.SendBatch(ctx); err != nil {
+		t.Fatalf("SendBatch failed: %v", err)
+	}
+	if payload.CommonTags != nil {
+		t.Errorf("Expected no common tags, got %v", payload.CommonTags)
+	}
+	if payload.Logs[1].Tags["region"] != "eu" {
+		t.Errorf("Expected region on each entry, got %v", payload.Logs[1].Tags)
+	}
+}
+
+func TestLoggerClockDrivesRetryBackoff(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Close() // every request fails with a transport error
+
+	clock := &fakeClock{}
+	logger := NewLogger("test_api_key", "test-service",
+		WithLoggerClock(clock),
+		WithLoggerRetry(3, time.Hour, 2*time.Hour),
+	)
+	redirectToServer(logger.http, server)
+
+	if err := logger.Info(context.Background(), "message", nil); err == nil {
+		t.Fatal("Expected error from closed server")
+	}
+
+	if len(clock.delays) != 2 {
+		t.Fatalf("Expected 2 backoff waits, got %d", len(clock.delays))
+	}
+	if clock.delays[0] < time.Hour {
+		t.Errorf("Expected first delay of at least 1h, got %v", clock.delays[0])
+	}
+}
+
+func TestMinLevelDropsLowerLevels(t *testing.T) {
+	logger := NewLogger("test_api_key", "test-service", WithLoggerMinLevel(LevelWarn))
+	logger.BeginBatch()
+
+	ctx := context.Background()
+	logger.Debug(ctx, "debug", nil)
+	logger.Info(ctx, "info", nil)
+	logger.Warn(ctx, "warn", nil)
+	logger.Error(ctx, "error", nil)
+
+	if logger.BatchSize() != 2 {
+		t.Errorf("Expected 2 logs at warn and above, got %d", logger.BatchSize())
+	}
+}
+
+func TestEnabled(t *testing.T) {
+	logger := NewLogger("test_api_key", "test-service", WithLoggerMinLevel(LevelWarn))
+
+	if logger.Enabled(LevelInfo) {
+		t.Error("Expected info to be disabled")
+	}
+	if !logger.Enabled(LevelError) {
+		t.Error("Expected error to be enabled")
+	}
+	if !logger.EnabledContext(WithLevel(context.Background(), LevelDebug), LevelDebug) {
+		t.Error("Expected context override to enable debug")
+	}
+	if !NewLogger("test_api_key", "test-service").Enabled(LevelDebug) {
+		t.Error("Expected all levels enabled by default")
+	}
+}
+
+func TestContextLevelOverridesMinLevel(t *testing.T) {
+	logger := NewLogger("test_api_key", "test-service", WithLoggerMinLevel(LevelInfo))
+	logger.BeginBatch()
+
+	logger.Debug(context.Background(), "dropped", nil)
+	logger.Debug(WithLevel(context.Background(), LevelDebug), "verbose", nil)
+
+	if logger.BatchSize() != 1 {
+		t.Fatalf("Expected 1 log, got %d", logger.BatchSize())
+	}
+	if logger.batchQueue[0].Message != "verbose" {
+		t.Errorf("Expected 'verbose', got '%s'", logger.batchQueue[0].Message)
+	}
+
+	// The override can also raise the level for a context
+	logger.Info(WithLevel(context.Background(), LevelError), "quiet", nil)
+	if logger.BatchSize() != 1 {
+		t.Errorf("Expected info log to be dropped, got %d logs", logger.BatchSize())
+	}
+}
+
+func TestRetryDeciderRetriesStatus(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	logger := NewLogger("test_api_key", "test-service",
+		WithLoggerClock(&fakeClock{}),
+		WithLoggerRetryDecider(func(resp *http.Response, err error) bool {
+			return err != nil || resp.StatusCode >= 500
+		}),
+	)
+	redirectToServer(logger.http, server)
+
+	if err := logger.Info(context.Background(), "message", nil); err != nil {
+		t.Fatalf("Expected success after retries, got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("Expected 3 attempts, got %d", calls)
+	}
+}
+
+func TestDefaultRetryDeciderDoesNotRetryStatus(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	logger := NewLogger("test_api_key", "test-service", WithLoggerClock(&fakeClock{}))
+	redirectToServer(logger.http, server)
+
+	if err := logger.Info(context.Background(), "message", nil); err == nil {
+		t.Fatal("Expected error for 503 response")
+	}
+	if calls != 1 {
+		t.Errorf("Expected 1 attempt, got %d", calls)
+	}
+}
+
+func TestNormalizeNumber(t *testing.T) {
+	cases := []struct {
+		in   interface{}
+		want interface{}
+	}{
+		{int64(5), 5},
+		{uint16(7), 7},
+		{float64(3), 3},
+		{float32(2), 2},
+		{1.5, 1.5},
+		{uint64(math.MaxUint64), uint64(math.MaxUint64)},
+		{1e300, 1e300},
+		{"42", "42"},
+	}
+	for _, c := range cases {
+		if got, _ := normalizeNumber(c.in); got != c.want {
+			t.Errorf("normalizeNumber(%v %T) = %v (%T), want %v (%T)", c.in, c.in, got, got, c.want, c.want)
+		}
+	}
+}
+
+func TestFormattedLogMethods(t *testing.T) {
+	logger := NewLogger("test_api_key", "test-service")
+	logger.BeginBatch()
+
+	ctx := context.Background()
+	logger.Debugf(ctx, "debug %d", 1)
+	logger.Infof(ctx, "info %s", "two")
+	logger.Warnf(ctx, "warn %v", 3.5)
+	logger.Errorf(ctx, "error %q", "four")
+
+	expected := []struct {
+		level   LogLevel
+		message string
+	}{
+		{LevelDebug, "debug 1"},
+		{LevelInfo, "info two"},
+		{LevelWarn, "warn 3.5"},
+		{LevelError, `error "four"`},
+	}
+	if logger.BatchSize() != len(expected) {
+		t.Fatalf("Expected %d logs, got %d", len(expected), logger.BatchSize())
+	}
+	for i, e := range expected {
+		entry := logger.batchQueue[i]
+		if entry.Level != e.level || entry.Message != e.message {
+			t.Errorf("Expected %s '%s', got %s '%s'", e.level, e.message, entry.Level, entry.Message)
+		}
+	}
+}
+
+func TestSendEntries(t *testing.T) {
+	var payload BatchLogsPayload
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Path != "/api/v1/logs/batch" {
+			t.Errorf("Expected batch endpoint, got %s", r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	logger := NewLogger("test_api_key", "relay")
+	redirectToServer(logger.http, server)
+
+	err := logger.SendEntries(context.Background(), []LogEntry{
+		{Message: "from db", Level: LevelError, Hostname: "db-1"},
+		{Message: "from web", Level: LevelInfo, Hostname: "web-2"},
+	})
+	if err != nil {
+		t.Fatalf("SendEntries failed: %v", err)
+	}
+
+	if calls != 1 {
+		t.Fatalf("Expected 1 request, got %d", calls)
+	}
+	if payload.Hostname != "relay" {
+		t.Errorf("Expected payload hostname 'relay', got '%s'", payload.Hostname)
+	}
+	if len(payload.Logs) != 2 || payload.Logs[0].Hostname != "db-1" || payload.Logs[1].Hostname != "web-2" {
+		t.Errorf("Expected per-entry hostnames, got %+v", payload.Logs)
+	}
+	if logger.BatchSize() != 0 {
+		t.Errorf("Expected batch queue untouched, got %d", logger.BatchSize())
+	}
+}
+
+func TestMaxConcurrentSends(t *testing.T) {
+	var mu sync.Mutex
+	var inFlight, peak int
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		if inFlight > peak {
+			peak = inFlight
+		}
+		mu.Unlock()
+
+		<-release
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	logger := NewLogger("test_api_key", "test-service", WithLoggerMaxConcurrentSends(2))
+	redirectToServer(logger.http, server)
+	derived := logger.WithContext(map[string]interface{}{"k": "v"})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		l := logger
+		if i%2 == 1 {
+			l = derived
+		}
+		go func() {
+			defer wg.Done()
+			l.Info(context.Background(), "message", nil)
+		}()
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if peak > 2 {
+		t.Errorf("Expected at most 2 concurrent sends, got %d", peak)
+	}
+}
+
+func TestMaxConcurrentSendsRespectsContext(t *testing.T) {
+	logger := NewLogger("test_api_key", "test-service", WithLoggerMaxConcurrentSends(1))
+	logger.sendSem <- struct{}{} // occupy the only slot
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := logger.Info(ctx, "message", nil); err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+// customSerializer is a test Serializer with its own content type
+type customSerializer struct{}
+
+func (customSerializer) Marshal(v interface{}) ([]byte, error) {
+	return []byte("custom-payload"), nil
+}
+
+func (customSerializer) ContentType() string {
+	return "application/x-custom"
+}
+
+func TestLoggerSerializer(t *testing.T) {
+	var contentType, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	logger := NewLogger("test_api_key", "test-service", WithLoggerSerializer(customSerializer{}))
+	redirectToServer(logger.http, server)
+
+	if err := logger.Info(context.Background(), "message", nil); err != nil {
+		t.Fatalf("Info failed: %v", err)
+	}
+	if contentType != "application/x-custom" {
+		t.Errorf("Expected custom content type, got '%s'", contentType)
+	}
+	if body != "custom-payload" {
+		t.Errorf("Expected custom payload, got '%s'", body)
+	}
+}
+
+func TestMergeTagsReusesMaps(t *testing.T) {
+	logger := NewLogger("test_api_key", "test-service")
+	tags := map[string]interface{}{"key": "value", "count": 3}
+
+	merged, borrowed := logger.mergeTags(tags)
+	if !borrowed || len(merged) != 2 {
+		t.Errorf("Expected tags to be returned as-is without context, got %v (borrowed=%v)", merged, borrowed)
+	}
+
+	merged, borrowed = logger.mergeTags(map[string]interface{}{"count": int64(3)})
+	if borrowed || merged["count"] != 3 {
+		t.Errorf("Expected a normalized copy, got %v (borrowed=%v)", merged, borrowed)
+	}
+
+	contextLogger := logger.WithContext(map[string]interface{}{"service": "api"})
+	first, _ := contextLogger.mergeTags(nil)
+	second, _ := contextLogger.mergeTags(nil)
+	if first["service"] != "api" || len(first) != 1 {
+		t.Errorf("Expected context tags, got %v", first)
+	}
+	first["probe"] = true
+	if second["probe"] != true {
+		t.Error("Expected the context map to be reused when there are no tags")
+	}
+}
+
+func TestBatchCopiesCallerTags(t *testing.T) {
+	logger := NewLogger("test_api_key", "test-service")
+	logger.BeginBatch()
+
+	tags := map[string]interface{}{"key": "before"}
+	logger.Info(context.Background(), "message", tags)
+	tags["key"] = "after"
+
+	if logger.batchQueue[0].Tags["key"] != "before" {
+		t.Errorf("Expected queued tags to be isolated from caller, got %v", logger.batchQueue[0].Tags["key"])
+	}
+}
+
+// newSlowServer responds after delay. Call the returned func to release
+// pending handlers and shut the server down.
+func newSlowServer(delay time.Duration) (*httptest.Server, func()) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(delay):
+		case <-done:
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	return server, func() {
+		close(done)
+		server.Close()
+	}
+}
+
+func TestContextDeadlineShorterThanClientTimeout(t *testing.T) {
+	server, closeServer := newSlowServer(time.Second)
+	defer closeServer()
+
+	logger := NewLogger("test_api_key", "test-service",
+		WithLoggerTimeout(5*time.Second),
+		WithLoggerRetry(1, time.Millisecond, time.Millisecond),
+	)
+	redirectToServer(logger.http, server)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if err := logger.Info(ctx, "message", nil); err == nil {
+		t.Fatal("Expected deadline error")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected context deadline to apply, took %v", elapsed)
+	}
+}
+
+func TestClientTimeoutShorterThanContextDeadline(t *testing.T) {
+	server, closeServer := newSlowServer(time.Second)
+	defer closeServer()
+
+	logger := NewLogger("test_api_key", "test-service",
+		WithLoggerTimeout(50*time.Millisecond),
+		WithLoggerRetry(1, time.Millisecond, time.Millisecond),
+	)
+	redirectToServer(logger.http, server)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	if err := logger.Info(ctx, "message", nil); err == nil {
+		t.Fatal("Expected timeout error")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected client timeout to apply, took %v", elapsed)
+	}
+}
+
+func TestNoClientTimeout(t *testing.T) {
+	server, closeServer := newSlowServer(100 * time.Millisecond)
+	defer closeServer()
+
+	logger := NewLogger("test_api_key", "test-service",
+		WithLoggerTimeout(10*time.Millisecond),
+		WithLoggerNoClientTimeout(),
+		WithLoggerRetry(1, time.Millisecond, time.Millisecond),
+	)
+	redirectToServer(logger.http, server)
+
+	if logger.http.client.Timeout != 0 {
+		t.Fatalf("Expected no client timeout, got %v", logger.http.client.Timeout)
+	}
+	if err := logger.Info(context.Background(), "message", nil); err != nil {
+		t.Errorf("Expected slow request to succeed without client timeout, got %v", err)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	var query url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" || r.URL.Path != "/api/v1/logs" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		query = r.URL.Query()
+		w.Write([]byte(`{"status":"ok","data":[{"message":"boom","severity":"error","hostname":"web-1","tags":{"code":500}}]}`))
+	}))
+	defer server.Close()
+
+	logger := NewLogger("test_api_key", "test-service")
+	redirectToServer(logger.http, server)
+
+	since := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	logs, err := logger.Query(context.Background(), QueryOptions{
+		Level:  LevelError,
+		Since:  since,
+		Search: "boom",
+		Limit:  10,
+	})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+
+	if query.Get("severity") != "error" || query.Get("search") != "boom" || query.Get("limit") != "10" {
+		t.Errorf("Unexpected query parameters: %v", query)
+	}
+	if query.Get("from") != "2024-01-02T03:04:05Z" {
+		t.Errorf("Expected RFC 3339 from, got '%s'", query.Get("from"))
+	}
+	if _, ok := query["hostname"]; ok {
+		t.Error("Expected empty filters to be omitted")
+	}
+
+	if len(logs) != 1 || logs[0].Message != "boom" || logs[0].Level != LevelError || logs[0].Hostname != "web-1" {
+		t.Errorf("Unexpected logs: %+v", logs)
+	}
+}
+
+func TestQueryErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	logger := NewLogger("test_api_key", "test-service")
+	redirectToServer(logger.http, server)
+
+	if _, err := logger.Query(context.Background(), QueryOptions{}); err == nil {
+		t.Error("Expected error for 403 response")
+	}
+}
+
+func TestLoggerConfig(t *testing.T) {
+	logger := NewLogger("ilog_live_secret_key_1234", "test-service",
+		WithLoggerTimeout(7*time.Second),
+		WithLoggerRetry(5, time.Second, time.Minute),
+		WithLoggerMinLevel(LevelWarn),
+	)
+	logger.SetDebug(true)
+
+	config := logger.WithContext(map[string]interface{}{"k": "v"}).Config()
+	if config.APIKey != "ilog*****************1234" {
+		t.Errorf("Expected masked API key, got '%s'", config.APIKey)
+	}
+	if config.Hostname != "test-service" || config.Timeout != 7*time.Second || config.RetryAttempts != 5 {
+		t.Errorf("Unexpected config: %+v", config)
+	}
+	if config.MinLevel != LevelWarn {
+		t.Errorf("Expected min level warn, got '%s'", config.MinLevel)
+	}
+	if !logger.Config().Debug {
+		t.Error("Expected Debug to reflect SetDebug")
+	}
+}
+
+func TestLoggerConfigIsDeepCopy(t *testing.T) {
+	logger := NewLogger("test_api_key", "test-service",
+		WithLoggerContextKeys("tenant"),
+		WithLoggerSuccessCodes(200, 202),
+		WithLoggerTagSchema(TagSchema{Required: []string{"service"}, Types: map[string]TagType{"service": TagString}}),
+	)
+
+	config := logger.Config()
+	config.ContextKeys[0] = "changed"
+	config.SuccessCodes[0] = 500
+	config.TagSchema.Required[0] = "changed"
+	config.TagSchema.Types["extra"] = TagString
+
+	again := logger.Config()
+	if again.ContextKeys[0] != "tenant" || again.SuccessCodes[0] != 200 {
+		t.Errorf("Expected the logger's slices unchanged, got %v and %v", again.ContextKeys, again.SuccessCodes)
+	}
+	if again.TagSchema.Required[0] != "service" || len(again.TagSchema.Types) != 1 {
+		t.Errorf("Expected the logger's tag schema unchanged, got %+v", again.TagSchema)
+	}
+}
+
+func TestDedupCollapsesConsecutiveDuplicates(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	logger := NewLogger("test_api_key", "test-service",
+		WithLoggerDedup(time.Second),
+		WithLoggerClock(clock),
+	)
+	logger.BeginBatch()
+
+	ctx := context.Background()
+	tags := map[string]interface{}{"code": 500}
+	for i := 0; i < 3; i++ {
+		logger.Error(ctx, "db down", tags)
+	}
+	logger.Error(ctx, "db down", map[string]interface{}{"code": 503}) // different tags
+	logger.Warn(ctx, "db down", map[string]interface{}{"code": 503})  // different level
+
+	if logger.BatchSize() != 3 {
+		t.Fatalf("Expected 3 entries after dedup, got %d", logger.BatchSize())
+	}
+	first := logger.batchQueue[0]
+	if first.Tags["count"] != 3 || first.Tags["code"] != 500 {
+		t.Errorf("Expected collapsed entry with count 3, got %v", first.Tags)
+	}
+	if _, ok := logger.batchQueue[1].Tags["count"]; ok {
+		t.Error("Expected no count tag on a single entry")
+	}
+	if _, ok := tags["count"]; ok {
+		t.Error("Caller's tags must not be modified")
+	}
+}
+
+func TestDedupRespectsWindow(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	logger := NewLogger("test_api_key", "test-service",
+		WithLoggerDedup(time.Second),
+		WithLoggerClock(clock),
+	)
+	logger.BeginBatch()
+
+	ctx := context.Background()
+	logger.Info(ctx, "tick", nil)
+	clock.now = clock.now.Add(2 * time.Second)
+	logger.Info(ctx, "tick", nil)
+
+	if logger.BatchSize() != 2 {
+		t.Errorf("Expected entries outside the window to stay separate, got %d", logger.BatchSize())
+	}
+
+	logger.ClearBatch()
+	logger.Info(ctx, "tick", nil)
+	if logger.BatchSize() != 1 || logger.batchQueue[0].Tags != nil {
+		t.Errorf("Expected dedup state reset by ClearBatch, got %+v", logger.batchQueue)
+	}
+}
+
+func TestOnSuccessCallback(t *testing.T) {
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(`{"status":"ok","data":{"id":"ack-1"}}`))
+	}))
+	defer server.Close()
+
+	var acked [][]LogEntry
+	var lastResp *APIResponse
+	logger := NewLogger("test_api_key", "test-service",
+		WithLoggerOnSuccess(func(entries []LogEntry, resp *APIResponse) {
+			acked = append(acked, entries)
+			lastResp = resp
+		}),
+	)
+	redirectToServer(logger.http, server)
+
+	ctx := context.Background()
+	logger.Info(ctx, "single", nil)
+
+	logger.BeginBatch()
+	logger.Info(ctx, "batched 1", nil)
+	logger.Info(ctx, "batched 2", nil)
+	logger.SendBatch(ctx)
+	logger.EndBatch()
+
+	status = http.StatusInternalServerError
+	logger.Info(ctx, "rejected", nil)
+
+	if len(acked) != 2 {
+		t.Fatalf("Expected 2 callbacks, got %d", len(acked))
+	}
+	if len(acked[0]) != 1 || acked[0][0].Message != "single" || acked[0][0].Hostname != "test-service" {
+		t.Errorf("Unexpected single ack: %+v", acked[0])
+	}
+	if len(acked[1]) != 2 {
+		t.Errorf("Expected 2 batched entries, got %d", len(acked[1]))
+	}
+	if lastResp == nil || lastResp.Data.ID != "ack-1" {
+		t.Errorf("Expected parsed response, got %+v", lastResp)
+	}
+}
+
+func TestLoggerFieldNames(t *testing.T) {
+	var bodies []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		bodies = append(bodies, payload)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	logger := NewLogger("test_api_key", "test-service", WithLoggerFieldNames(FieldNameMap{
+		Message:  "msg",
+		Severity: "level",
+		Hostname: "host",
+		Tags:     "labels",
+	}))
+	redirectToServer(logger.http, server)
+
+	ctx := context.Background()
+	logger.Info(ctx, "single", map[string]interface{}{"k": "v"})
+
+	logger.BeginBatch()
+	logger.Warn(ctx, "batched", nil)
+	if err := logger.SendBatch(ctx); err != nil {
+		t.Fatalf("SendBatch failed: %v", err)
+	}
+
+	if len(bodies) != 2 {
+		t.Fatalf("Expected 2 requests, got %d", len(bodies))
+	}
+	single := bodies[0]
+	if single["msg"] != "single" || single["level"] != "info" || single["host"] != "test-service" {
+		t.Errorf("Expected renamed keys, got %v", single)
+	}
+	if _, ok := single["message"]; ok {
+		t.Errorf("Expected default message key to be absent, got %v", single)
+	}
+	if labels, ok := single["labels"].(map[string]interface{}); !ok || labels["k"] != "v" {
+		t.Errorf("Expected tags under labels, got %v", single["labels"])
+	}
+
+	batch := bodies[1]
+	logs, _ := batch["logs"].([]interface{})
+	if batch["host"] != "test-service" || len(logs) != 1 {
+		t.Fatalf("Expected renamed batch payload, got %v", batch)
+	}
+	if entry := logs[0].(map[string]interface{}); entry["msg"] != "batched" || entry["level"] != "warn" {
+		t.Errorf("Expected renamed batch entry, got %v", entry)
+	}
+}
+
+func TestResolveFieldNamesDefaults(t *testing.T) {
+	if resolveFieldNames(FieldNameMap{}) != nil {
+		t.Error("Expected nil for default field names")
+	}
+	if resolveFieldNames(FieldNameMap{Message: "message"}) != nil {
+		t.Error("Expected nil when names match the defaults")
+	}
+	resolved := resolveFieldNames(FieldNameMap{Message: "msg"})
+	if resolved == nil || resolved.Message != "msg" || resolved.Severity != "severity" {
+		t.Errorf("Expected partial override with defaults, got %+v", resolved)
+	}
+}
+
+func TestDebugLimiter(t *testing.T) {
+	limiter := &debugLimiter{perSecond: 2}
+	start := time.Unix(1700000000, 0)
+
+	var allowed int
+	for i := 0; i < 5; i++ {
+		if ok, _ := limiter.allow(start.Add(time.Duration(i) * time.Millisecond)); ok {
+			allowed++
+		}
+	}
+	if allowed != 2 {
+		t.Errorf("Expected 2 lines allowed in the first second, got %d", allowed)
+	}
+
+	ok, suppressed := limiter.allow(start.Add(time.Second))
+	if !ok || suppressed != 3 {
+		t.Errorf("Expected new window to allow and report 3 suppressed, got %v/%d", ok, suppressed)
+	}
+	if _, suppressed := limiter.allow(start.Add(time.Second)); suppressed != 0 {
+		t.Errorf("Expected suppressed count to be reported once, got %d", suppressed)
+	}
+}
+
+func TestWithLoggerDebugRate(t *testing.T) {
+	logger := NewLogger("test_api_key", "test-service", WithLoggerDebugRate(10))
+	if logger.http.debugLimit == nil || logger.http.debugLimit.perSecond != 10 {
+		t.Errorf("Expected debug limiter with 10/s, got %+v", logger.http.debugLimit)
+	}
+	if NewLogger("test_api_key", "test-service").http.debugLimit != nil {
+		t.Error("Expected no debug limiter by default")
+	}
+}
+
+func TestLoggerRetryBatch(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	logger := NewLogger("test_api_key", "test-service",
+		WithLoggerRetry(1, 100*time.Millisecond, time.Second),
+		WithLoggerClock(clock),
+	)
+	redirectToServer(logger.http, server)
+
+	ctx := context.Background()
+	logger.BeginBatch()
+	logger.Info(ctx, "queued", nil)
+
+	if err := logger.SendBatch(ctx); err == nil {
+		t.Fatal("Expected initial SendBatch to fail")
+	}
+	if logger.BatchSize() != 1 {
+		t.Fatalf("Expected entry to stay queued, got %d", logger.BatchSize())
+	}
+
+	if err := logger.RetryBatch(ctx, 3); err != nil {
+		t.Fatalf("RetryBatch failed: %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("Expected 3 requests, got %d", calls)
+	}
+	if len(clock.delays) != 1 {
+		t.Errorf("Expected 1 backoff wait, got %v", clock.delays)
+	}
+	if logger.BatchSize() != 0 {
+		t.Errorf("Expected queue cleared after success, got %d", logger.BatchSize())
+	}
+}
+
+// backoffClock is a fakeClock that runs wait before each backoff fires
+type backoffClock struct {
+	*fakeClock
+	wait func()
+}
+
+func (c backoffClock) After(d time.Duration) <-chan time.Time {
+	c.wait()
+	return c.fakeClock.After(d)
+}
+
+func TestLoggerRetryBatchKeepsEntriesLoggedDuringBackoff(t *testing.T) {
+	var calls int
+	var sent []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var payload BatchLogsPayload
+		json.NewDecoder(r.Body).Decode(&payload)
+		for _, entry := range payload.Logs {
+			sent = append(sent, entry.Message)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	var logger *Logger
+	clock := backoffClock{fakeClock: &fakeClock{}, wait: func() {
+		logger.Info(ctx, "during backoff", nil)
+	}}
+	logger = NewLogger("test_api_key", "test-service",
+		WithLoggerRetry(1, time.Millisecond, time.Millisecond),
+		WithLoggerClock(clock),
+	)
+	redirectToServer(logger.http, server)
+
+	logger.BeginBatch()
+	logger.Info(ctx, "queued", nil)
+
+	if err := logger.RetryBatch(ctx, 3); err != nil {
+		t.Fatalf("RetryBatch failed: %v", err)
+	}
+	if !reflect.DeepEqual(sent, []string{"queued"}) {
+		t.Errorf("Expected only the entry queued before the retries to be sent, got %v", sent)
+	}
+	if logger.BatchSize() != 1 {
+		t.Errorf("Expected the entry logged during the backoff to stay queued, got %d", logger.BatchSize())
+	}
+}
+
+func TestLoggerRetryBatchKeepsQueueOnFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	logger := NewLogger("test_api_key", "test-service",
+		WithLoggerRetry(1, time.Millisecond, time.Millisecond),
+		WithLoggerClock(&fakeClock{}),
+	)
+	redirectToServer(logger.http, server)
+
+	ctx := context.Background()
+	logger.BeginBatch()
+	logger.Info(ctx, "queued", nil)
+
+	if err := logger.RetryBatch(ctx, 2); err == nil {
+		t.Error("Expected RetryBatch to fail")
+	}
+	if logger.BatchSize() != 1 {
+		t.Errorf("Expected entry to stay queued, got %d", logger.BatchSize())
+	}
+}
+
+func TestLoggerFlushAll(t *testing.T) {
+	var mu sync.Mutex
+	var received int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload BatchLogsPayload
+		json.NewDecoder(r.Body).Decode(&payload)
+		mu.Lock()
+		received += len(payload.Logs)
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	logger := NewLogger("test_api_key", "test-service")
+	redirectToServer(logger.http, server)
+
+	ctx := context.Background()
+	logger.BeginBatch()
+	logger.Info(ctx, "parent", nil)
+
+	child := logger.WithContext(map[string]interface{}{"request_id": "abc"})
+	child.BeginBatch()
+	child.Info(ctx, "child 1", nil)
+	child.Info(ctx, "child 2", nil)
+
+	ended := logger.WithContext(map[string]interface{}{"request_id": "def"})
+	ended.BeginBatch()
+	ended.Info(ctx, "discarded", nil)
+	ended.EndBatch()
+
+	other := NewLogger("test_api_key", "other-service")
+	other.BeginBatch()
+	other.Info(ctx, "unrelated", nil)
+
+	if err := logger.FlushAll(ctx); err != nil {
+		t.Fatalf("FlushAll failed: %v", err)
+	}
+	if received != 3 {
+		t.Errorf("Expected 3 entries sent, got %d", received)
+	}
+	if logger.BatchSize() != 0 || child.BatchSize() != 0 {
+		t.Errorf("Expected queues flushed, got %d and %d", logger.BatchSize(), child.BatchSize())
+	}
+	if other.BatchSize() != 1 {
+		t.Errorf("Expected unrelated logger untouched, got %d", other.BatchSize())
+	}
+}
+
+func TestLoggerFlushAllReleasesDrainedLoggers(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	logger := NewLogger("test_api_key", "test-service")
+	redirectToServer(logger.http, server)
+
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		reqLogger := logger.WithContext(map[string]interface{}{"request_id": i})
+		reqLogger.BeginBatch()
+		reqLogger.Info(ctx, "handled", nil)
+		if err := reqLogger.SendBatch(ctx); err != nil {
+			t.Fatalf("SendBatch failed: %v", err)
+		}
+	}
+	if n := len(logger.batches.snapshot()); n != 0 {
+		t.Errorf("Expected drained loggers to be released without EndBatch, got %d tracked", n)
+	}
+
+	pending := logger.WithContext(nil)
+	pending.BeginBatch()
+	if n := len(logger.batches.snapshot()); n != 0 {
+		t.Errorf("Expected an empty batch not to be tracked, got %d", n)
+	}
+	pending.Info(ctx, "queued", nil)
+	if n := len(logger.batches.snapshot()); n != 1 {
+		t.Errorf("Expected the logger with a queued entry to be tracked, got %d", n)
+	}
+
+	scope := logger.Scope()
+	scope.Info(ctx, "buffered", nil)
+	if n := len(logger.batches.snapshot()); n != 1 {
+		t.Errorf("Expected scopes not to be tracked, got %d", n)
+	}
+}
+
+func TestSendBatchKeepsEntriesLoggedDuringSend(t *testing.T) {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var sent []string
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload BatchLogsPayload
+		json.NewDecoder(r.Body).Decode(&payload)
+		started <- struct{}{}
+		<-release
+		mu.Lock()
+		defer mu.Unlock()
+		if status == http.StatusOK {
+			for _, entry := range payload.Logs {
+				sent = append(sent, entry.Message)
+			}
+		}
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	logger := NewLogger("test_api_key", "test-service")
+	redirectToServer(logger.http, server)
+
+	ctx := context.Background()
+	logger.BeginBatch()
+	logger.Info(ctx, "first", nil)
+
+	done := make(chan error, 1)
+	go func() { done <- logger.SendBatch(ctx) }()
+	<-started
+	logger.Info(ctx, "during send", nil)
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("SendBatch failed: %v", err)
+	}
+	if !reflect.DeepEqual(sent, []string{"first"}) {
+		t.Errorf("Expected only the entry queued before the send, got %v", sent)
+	}
+	if logger.BatchSize() != 1 {
+		t.Fatalf("Expected the entry logged during the send to stay queued, got %d", logger.BatchSize())
+	}
+
+	mu.Lock()
+	status = http.StatusInternalServerError
+	mu.Unlock()
+	release = make(chan struct{})
+	go func() { done <- logger.SendBatch(ctx) }()
+	<-started
+	logger.Info(ctx, "during failed send", nil)
+	close(release)
+	if err := <-done; err == nil {
+		t.Fatal("Expected SendBatch to fail")
+	}
+
+	logger.mu.Lock()
+	var queued []string
+	for _, entry := range logger.batchQueue {
+		queued = append(queued, entry.Message)
+	}
+	logger.mu.Unlock()
+	if !reflect.DeepEqual(queued, []string{"during send", "during failed send"}) {
+		t.Errorf("Expected failed entries back at the front of the queue, got %v", queued)
+	}
+}
+
+type tenantKey struct{}
+
+func TestLoggerContextExtractor(t *testing.T) {
+	logger := NewLogger("test_api_key", "test-service", WithLoggerContextExtractor(func(ctx context.Context) map[string]interface{} {
+		if tenant, ok := ctx.Value(tenantKey{}).(string); ok {
+			return map[string]interface{}{"tenant": tenant, "source": "extractor"}
+		}
+		return nil
+	}))
+	logger.BeginBatch()
+
+	ctx := context.WithValue(context.Background(), tenantKey{}, "acme")
+	logger.Info(ctx, "with tenant", map[string]interface{}{"source": "call"})
+	logger.Info(context.Background(), "without tenant", nil)
+
+	if logger.BatchSize() != 2 {
+		t.Fatalf("Expected 2 entries, got %d", logger.BatchSize())
+	}
+	tags := logger.batchQueue[0].Tags
+	if tags["tenant"] != "acme" || tags["source"] != "call" {
+		t.Errorf("Expected extracted tenant and call tags to win, got %v", tags)
+	}
+	if logger.batchQueue[1].Tags != nil {
+		t.Errorf("Expected no tags without tenant, got %v", logger.batchQueue[1].Tags)
+	}
+
+	child := logger.WithContext(map[string]interface{}{"request_id": "abc"})
+	child.BeginBatch()
+	child.Info(ctx, "derived", nil)
+	if child.batchQueue[0].Tags["tenant"] != "acme" {
+		t.Errorf("Expected derived logger to keep the extractor, got %v", child.batchQueue[0].Tags)
+	}
+}
+
+func TestLoggerSetRetryConfig(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	logger := NewLogger("test_api_key", "test-service",
+		WithLoggerRetry(1, time.Millisecond, time.Millisecond),
+		WithLoggerClock(&fakeClock{}),
+		WithLoggerRetryDecider(func(resp *http.Response, err error) bool {
+			return err != nil || resp.StatusCode >= 500
+		}),
+	)
+	redirectToServer(logger.http, server)
+
+	child := logger.WithContext(map[string]interface{}{"k": "v"})
+	logger.SetRetryConfig(RetryConfig{MaxAttempts: 4, BaseDelay: time.Millisecond, MaxDelay: time.Second})
+
+	child.Info(context.Background(), "message", nil)
+	if calls != 4 {
+		t.Errorf("Expected 4 attempts after SetRetryConfig, got %d", calls)
+	}
+
+	cfg := logger.Config()
+	if cfg.RetryAttempts != 4 || cfg.RetryMaxDelay != time.Second {
+		t.Errorf("Expected Config to reflect the new retry settings, got %+v", cfg)
+	}
+}
+
+func TestLoggerLogOnce(t *testing.T) {
+	logger := NewLogger("test_api_key", "test-service")
+	logger.BeginBatch()
+	child := logger.WithContext(map[string]interface{}{"request_id": "abc"})
+	child.BeginBatch()
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		logger.LogOnce(ctx, "deprecated", LevelWarn, "deprecated setting used", nil)
+	}
+	child.LogOnce(ctx, "deprecated", LevelWarn, "deprecated setting used", nil)
+	child.LogOnce(ctx, "other", LevelWarn, "another warning", nil)
+
+	if logger.BatchSize() != 1 {
+		t.Errorf("Expected 1 entry on parent, got %d", logger.BatchSize())
+	}
+	if child.BatchSize() != 1 || child.batchQueue[0].Message != "another warning" {
+		t.Errorf("Expected only the new key on child, got %+v", child.batchQueue)
+	}
+}
+
+type testCtxKey string
+
+func TestLoggerContextKeys(t *testing.T) {
+	const requestIDKey testCtxKey = "request_id"
+	const userKey testCtxKey = "user"
+
+	logger := NewLogger("test_api_key", "test-service",
+		WithLoggerContextKeys(requestIDKey, userKey),
+		WithLoggerContextExtractor(func(ctx context.Context) map[string]interface{} {
+			return map[string]interface{}{"user": "from-extractor"}
+		}),
+	)
+	logger.BeginBatch()
+
+	ctx := context.WithValue(context.Background(), requestIDKey, "abc-123")
+	ctx = context.WithValue(ctx, userKey, "from-key")
+	logger.Info(ctx, "handled", nil)
+	logger.Info(context.Background(), "no values", nil)
+
+	tags := logger.batchQueue[0].Tags
+	if tags["request_id"] != "abc-123" || tags["user"] != "from-extractor" {
+		t.Errorf("Expected context key tags with extractor winning, got %v", tags)
+	}
+	if _, ok := logger.batchQueue[1].Tags["request_id"]; ok {
+		t.Errorf("Expected nil context values to be skipped, got %v", logger.batchQueue[1].Tags)
+	}
+}
+
+func TestLoggerEvent(t *testing.T) {
+	logger := NewLogger("test_api_key", "test-service", WithLoggerMinLevel(LevelError))
+	logger.BeginBatch()
+
+	payload := map[string]interface{}{"version": "1.4.2"}
+	logger.Event(context.Background(), "deploy", payload)
+
+	if logger.BatchSize() != 1 {
+		t.Fatalf("Expected event to bypass the minimum level, got %d entries", logger.BatchSize())
+	}
+	entry := logger.batchQueue[0]
+	if entry.Message != "deploy" || entry.Level != LevelInfo {
+		t.Errorf("Expected info entry named deploy, got %+v", entry)
+	}
+	if entry.Tags["event_type"] != "deploy" || entry.Tags["version"] != "1.4.2" {
+		t.Errorf("Expected event_type and payload tags, got %v", entry.Tags)
+	}
+	if _, ok := payload["event_type"]; ok {
+		t.Error("Expected payload map to be left unmodified")
+	}
+}
+
+func TestLoggerEmptyMessage(t *testing.T) {
+	logger := NewLogger("test_api_key", "test-service")
+	logger.BeginBatch()
+
+	ctx := context.Background()
+	logger.Info(ctx, "", nil)
+	logger.Info(ctx, "  ", map[string]interface{}{"source": "worker"})
+
+	if got := logger.batchQueue[0].Message; got != "(no message)" {
+		t.Errorf("Expected default placeholder, got %q", got)
+	}
+	if got := logger.batchQueue[1].Message; got != "(no message) [source: worker]" {
+		t.Errorf("Expected placeholder with source, got %q", got)
+	}
+
+	custom := NewLogger("test_api_key", "test-service", WithLoggerEmptyMessage("<empty>"))
+	custom.BeginBatch()
+	custom.Warn(ctx, "", nil)
+	if got := custom.batchQueue[0].Message; got != "<empty>" {
+		t.Errorf("Expected custom placeholder, got %q", got)
+	}
+}
+
+func TestLoggerLogIndexed(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	logger := NewLogger("test_api_key", "test-service").WithContext(map[string]interface{}{"service": "api"})
+	redirectToServer(logger.http, server)
+
+	err := logger.LogIndexed(context.Background(), LevelInfo, "checkout complete",
+		map[string]interface{}{"region": "eu-west"},
+		map[string]interface{}{"order_id": "A-1", "items": int64(3)},
+	)
+	if err != nil {
+		t.Fatalf("LogIndexed failed: %v", err)
+	}
+
+	tags, _ := body["tags"].(map[string]interface{})
+	fields, _ := body["fields"].(map[string]interface{})
+	if tags["region"] != "eu-west" || tags["service"] != "api" || len(tags) != 2 {
+		t.Errorf("Expected indexed tags with context, got %v", body["tags"])
+	}
+	if fields["order_id"] != "A-1" || fields["items"] != float64(3) {
+		t.Errorf("Expected stored fields, got %v", body["fields"])
+	}
+}
+
+func TestLoggerDedupComparesFields(t *testing.T) {
+	logger := NewLogger("test_api_key", "test-service", WithLoggerDedup(time.Minute))
+	logger.BeginBatch()
+
+	ctx := context.Background()
+	logger.LogIndexed(ctx, LevelInfo, "hit", nil, map[string]interface{}{"user": "a"})
+	logger.LogIndexed(ctx, LevelInfo, "hit", nil, map[string]interface{}{"user": "b"})
+	logger.LogIndexed(ctx, LevelInfo, "hit", nil, map[string]interface{}{"user": "b"})
+
+	if logger.BatchSize() != 2 {
+		t.Errorf("Expected entries with different fields to stay separate, got %d", logger.BatchSize())
+	}
+}
+
+func TestLoggerLogAt(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	logger := NewLogger("test_api_key", "test-service")
+	redirectToServer(logger.http, server)
+
+	at := time.Date(2024, 3, 1, 12, 30, 0, 0, time.FixedZone("CET", 3600))
+	if err := logger.LogAt(context.Background(), at, LevelInfo, "replayed", nil); err != nil {
+		t.Fatalf("LogAt failed: %v", err)
+	}
+
+	if body["timestamp"] != "2024-03-01T11:30:00Z" {
+		t.Errorf("Expected UTC timestamp, got %v", body["timestamp"])
+	}
+}
+
+func TestLoggerLogOmitsTimestamp(t *testing.T) {
+	logger := NewLogger("test_api_key", "test-service")
+	logger.BeginBatch()
+	logger.Info(context.Background(), "now", nil)
+
+	if logger.batchQueue[0].Timestamp != nil {
+		t.Errorf("Expected no timestamp, got %v", logger.batchQueue[0].Timestamp)
+	}
+}
+
+func TestLoggerDedupComparesTimestamps(t *testing.T) {
+	logger := NewLogger("test_api_key", "test-service", WithLoggerDedup(time.Minute))
+	logger.BeginBatch()
+
+	ctx := context.Background()
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	logger.LogAt(ctx, at, LevelInfo, "tick", nil)
+	logger.LogAt(ctx, at.Add(time.Second), LevelInfo, "tick", nil)
+
+	if logger.BatchSize() != 2 {
+		t.Errorf("Expected entries with different timestamps to stay separate, got %d", logger.BatchSize())
+	}
+}
+
+func TestLoggerLogAllSendsOneBatch(t *testing.T) {
+	var requests int
+	var payload BatchLogsPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/api/v1/logs/batch" {
+			t.Errorf("Expected batch endpoint, got %s", r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	logger := NewLogger("test_api_key", "test-service")
+	redirectToServer(logger.http, server)
+
+	err := logger.LogAll(context.Background(), LevelWarn, []string{"row 1 invalid", "row 7 invalid"},
+		map[string]interface{}{"import_id": "imp-1"})
+	if err != nil {
+		t.Fatalf("LogAll failed: %v", err)
+	}
+
+	if requests != 1 {
+		t.Fatalf("Expected 1 request, got %d", requests)
+	}
+	if len(payload.Logs) != 2 || payload.Logs[1].Message != "row 7 invalid" {
+		t.Fatalf("Expected both messages, got %+v", payload.Logs)
+	}
+	for _, entry := range payload.Logs {
+		if entry.Level != LevelWarn || entry.Tags["import_id"] != "imp-1" {
+			t.Errorf("Expected warn entry with shared tags, got %+v", entry)
+		}
+	}
+}
+
+func TestLoggerLogAllQueuesInBatchMode(t *testing.T) {
+	logger := NewLogger("test_api_key", "test-service", WithLoggerMinLevel(LevelInfo))
+	logger.BeginBatch()
+
+	ctx := context.Background()
+	logger.LogAll(ctx, LevelInfo, []string{"a", "b", "c"}, map[string]interface{}{"k": "v"})
+	logger.LogAll(ctx, LevelDebug, []string{"filtered"}, nil)
+
+	if logger.BatchSize() != 3 {
+		t.Fatalf("Expected 3 queued entries, got %d", logger.BatchSize())
+	}
+	if logger.batchQueue[2].Message != "c" || logger.batchQueue[2].Tags["k"] != "v" {
+		t.Errorf("Expected queued entry with tags, got %+v", logger.batchQueue[2])
+	}
+}
+
+func TestLoggerMaxQueueSizeDropsLowestSeverityFirst(t *testing.T) {
+	logger := NewLogger("test_api_key", "test-service", WithLoggerMaxQueueSize(3))
+	logger.BeginBatch()
+
+	ctx := context.Background()
+	logger.Error(ctx, "error 1", nil)
+	logger.Debug(ctx, "debug 1", nil)
+	logger.Info(ctx, "info 1", nil)
+	logger.Warn(ctx, "warn 1", nil)   // evicts debug 1
+	logger.Error(ctx, "error 2", nil) // evicts info 1
+	logger.Debug(ctx, "debug 2", nil) // less severe than everything queued
+
+	var got []string
+	for _, entry := range logger.batchQueue {
+		got = append(got, entry.Message)
+	}
+	if strings.Join(got, ",") != "error 1,warn 1,error 2" {
+		t.Errorf("Expected error 1,warn 1,error 2, got %v", got)
+	}
+}
+
+func TestLoggerMaxQueueSizeEvictsOldestOfSameLevel(t *testing.T) {
+	logger := NewLogger("test_api_key", "test-service", WithLoggerMaxQueueSize(2))
+	logger.BeginBatch()
+
+	ctx := context.Background()
+	logger.Info(ctx, "info 1", nil)
+	logger.Info(ctx, "info 2", nil)
+	logger.Info(ctx, "info 3", nil)
+
+	if logger.BatchSize() != 2 || logger.batchQueue[0].Message != "info 2" {
+		t.Errorf("Expected oldest info entry evicted, got %+v", logger.batchQueue)
+	}
+}
+
+func TestLoggerMaxQueueSizeAppliesToRequeuedEntries(t *testing.T) {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	logger := NewLogger("test_api_key", "test-service",
+		WithLoggerMaxQueueSize(3),
+		WithLoggerRetry(1, time.Millisecond, time.Millisecond),
+	)
+	redirectToServer(logger.http, server)
+	logger.BeginBatch()
+
+	ctx := context.Background()
+	logger.Debug(ctx, "debug 1", nil)
+	logger.Error(ctx, "error 1", nil)
+	logger.Info(ctx, "info 1", nil)
+
+	done := make(chan error, 1)
+	go func() { done <- logger.FlushLevel(ctx, LevelDebug) }()
+	<-started
+	logger.Warn(ctx, "warn 1", nil)
+	logger.Info(ctx, "info 2", nil)
+	close(release)
+	if err := <-done; err == nil {
+		t.Fatal("Expected FlushLevel to fail")
+	}
+
+	var got []string
+	for _, entry := range logger.batchQueue {
+		got = append(got, entry.Message)
+	}
+	if strings.Join(got, ",") != "error 1,warn 1,info 2" {
+		t.Errorf("Expected error 1,warn 1,info 2, got %v", got)
+	}
+}
+
+func TestLoggerDefaultTransportReusesConnections(t *testing.T) {
+	logger := NewLogger("test_api_key", "test-service")
+
+	transport, ok := logger.http.client.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("Expected *http.Transport, got %T", logger.http.client.Transport)
+	}
+	if transport == http.DefaultTransport {
+		t.Error("Expected a private transport, not http.DefaultTransport")
+	}
+	if !transport.ForceAttemptHTTP2 || transport.DisableKeepAlives {
+		t.Error("Expected HTTP/2 and keep-alive enabled")
+	}
+	if transport.MaxIdleConnsPerHost != defaultMaxIdleConnsPerHost {
+		t.Errorf("Expected %d idle conns per host, got %d", defaultMaxIdleConnsPerHost, transport.MaxIdleConnsPerHost)
+	}
+}
+
+func TestLoggerTransportOption(t *testing.T) {
+	transport := &http.Transport{MaxIdleConnsPerHost: 64}
+	logger := NewLogger("test_api_key", "test-service", WithLoggerTransport(transport))
+	if logger.http.client.Transport != transport {
+		t.Error("Expected custom transport to be used")
+	}
+
+	client := &http.Client{}
+	logger = NewLogger("test_api_key", "test-service", WithLoggerTransport(transport), WithLoggerHTTPClient(client))
+	if logger.http.client != client || client.Transport != nil {
+		t.Error("Expected WithLoggerHTTPClient to take precedence over WithLoggerTransport")
+	}
+}
+
+func TestLoggerDialAndResponseHeaderTimeouts(t *testing.T) {
+	logger := NewLogger("test_api_key", "test-service",
+		WithLoggerTimeout(time.Minute),
+		WithLoggerDialTimeout(2*time.Second),
+		WithLoggerResponseHeaderTimeout(10*time.Second),
+	)
+
+	transport, ok := logger.http.client.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("Expected *http.Transport, got %T", logger.http.client.Transport)
+	}
+	if transport.ResponseHeaderTimeout != 10*time.Second {
+		t.Errorf("Expected response header timeout 10s, got %v", transport.ResponseHeaderTimeout)
+	}
+	if transport.DialContext == nil || !transport.ForceAttemptHTTP2 {
+		t.Error("Expected dialer set on the default transport settings")
+	}
+	if logger.http.client.Timeout != time.Minute {
+		t.Errorf("Expected overall timeout unchanged, got %v", logger.http.client.Timeout)
+	}
+
+	custom := &http.Transport{MaxIdleConnsPerHost: 64}
+	logger = NewLogger("test_api_key", "test-service",
+		WithLoggerTransport(custom),
+		WithLoggerResponseHeaderTimeout(5*time.Second),
+	)
+	transport = logger.http.client.Transport.(*http.Transport)
+	if transport == custom || custom.ResponseHeaderTimeout != 0 {
+		t.Error("Expected the custom transport to be copied, not modified")
+	}
+	if transport.MaxIdleConnsPerHost != 64 || transport.ResponseHeaderTimeout != 5*time.Second {
+		t.Errorf("Expected custom settings plus timeout, got %d and %v", transport.MaxIdleConnsPerHost, transport.ResponseHeaderTimeout)
+	}
+}
+
+func TestLoggerRateLimitStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "1000")
+		w.Header().Set("X-RateLimit-Remaining", "42")
+		w.Header().Set("X-RateLimit-Reset", "30")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	logger := NewLogger("test_api_key", "test-service", WithLoggerClock(&fakeClock{now: now}))
+	redirectToServer(logger.http, server)
+
+	if status := logger.RateLimitStatus(); !status.UpdatedAt.IsZero() {
+		t.Errorf("Expected no status before the first response, got %+v", status)
+	}
+
+	logger.Info(context.Background(), "message", nil)
+
+	status := logger.RateLimitStatus()
+	if status.Limit != 1000 || status.Remaining != 42 {
+		t.Errorf("Expected limit 1000 and 42 remaining, got %+v", status)
+	}
+	if !status.Reset.Equal(now.Add(30 * time.Second)) {
+		t.Errorf("Expected reset in 30s, got %v", status.Reset)
+	}
+}
+
+func TestRecordRateLimitUnixReset(t *testing.T) {
+	h := NewHTTPClient("key", time.Second, RetryConfig{MaxAttempts: 1}, false)
+	header := http.Header{}
+	header.Set("X-RateLimit-Remaining", "0")
+	header.Set("X-RateLimit-Reset", "1700000000")
+	h.recordRateLimit(header)
+
+	status := h.rateLimitStatus()
+	if status.Remaining != 0 || status.Limit != -1 {
+		t.Errorf("Expected 0 remaining and unknown limit, got %+v", status)
+	}
+	if status.Reset.Unix() != 1700000000 {
+		t.Errorf("Expected Unix reset time, got %v", status.Reset)
+	}
+}
+
+func TestLoggerStripANSI(t *testing.T) {
+	logger := NewLogger("test_api_key", "test-service", WithLoggerStripANSI(true))
+	logger.BeginBatch()
+
+	ctx := context.Background()
+	logger.Info(ctx, "\x1b[1;32mPASS\x1b[0m ok \x1b]0;title\x07done", nil)
+	logger.Info(ctx, "\x1b[31m\x1b[0m", map[string]interface{}{"source": "cli"})
+	NewWriter(logger, LevelInfo).Write([]byte("\x1b[33mwarning\x1b[0m\n"))
+
+	if got := logger.batchQueue[0].Message; got != "PASS ok done" {
+		t.Errorf("Expected escapes removed, got %q", got)
+	}
+	if got := logger.batchQueue[1].Message; got != "(no message) [source: cli]" {
+		t.Errorf("Expected placeholder for escape-only message, got %q", got)
+	}
+	if got := logger.batchQueue[2].Message; got != "warning" {
+		t.Errorf("Expected writer output stripped, got %q", got)
+	}
+}
+
+func TestLoggerKeepsANSIByDefault(t *testing.T) {
+	logger := NewLogger("test_api_key", "test-service")
+	logger.BeginBatch()
+	logger.Info(context.Background(), "\x1b[32mok\x1b[0m", nil)
+
+	if got := logger.batchQueue[0].Message; got != "\x1b[32mok\x1b[0m" {
+		t.Errorf("Expected message unchanged, got %q", got)
+	}
+}
+
+func TestLoggerNoTags(t *testing.T) {
+	logger := NewLogger("test_api_key", "test-service",
+		WithLoggerContextExtractor(func(ctx context.Context) map[string]interface{} {
+			return map[string]interface{}{"trace_id": "t-1"}
+		}),
+	).WithContext(map[string]interface{}{"service": "api"})
+	logger.BeginBatch()
+
+	ctx := context.Background()
+	logger.Info(ctx, "heartbeat", NoTags())
+	logger.Info(ctx, "empty map", map[string]interface{}{})
+
+	if tags := logger.batchQueue[0].Tags; len(tags) != 0 {
+		t.Errorf("Expected no tags with NoTags, got %v", tags)
+	}
+	if tags := logger.batchQueue[1].Tags; tags["service"] != "api" || tags["trace_id"] != "t-1" {
+		t.Errorf("Expected inherited tags for an ordinary empty map, got %v", tags)
+	}
+}
+
+func TestLoggerNoTagsWirePayload(t *testing.T) {
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	logger := NewLogger("test_api_key", "test-service").WithContext(map[string]interface{}{"service": "api"})
+	redirectToServer(logger.http, server)
+	renamed := NewLogger("test_api_key", "test-service", WithLoggerFieldNames(FieldNameMap{Tags: "labels"}))
+	redirectToServer(renamed.http, server)
+
+	ctx := context.Background()
+	logger.Info(ctx, "heartbeat", NoTags())
+	logger.BeginBatch()
+	logger.Info(ctx, "batched", NoTags())
+	logger.SendBatch(ctx)
+	renamed.Info(ctx, "renamed", NoTags())
+
+	if len(bodies) != 3 {
+		t.Fatalf("Expected 3 requests, got %d", len(bodies))
+	}
+	for i, want := range []string{`"tags":{}`, `"tags":{}`, `"labels":{}`} {
+		if !strings.Contains(bodies[i], want) || strings.Contains(bodies[i], `"api"`) {
+			t.Errorf("Expected %s and no context tags, got %s", want, bodies[i])
+		}
+	}
+}
+
+func TestLoggerWithSampled(t *testing.T) {
+	logger := NewLogger("test_api_key", "test-service", WithLoggerMinLevel(LevelInfo))
+	logger.BeginBatch()
+
+	ctx := context.Background()
+	logger.Info(WithSampled(ctx, false), "dropped", nil)
+	logger.Info(WithSampled(ctx, true), "kept", nil)
+	logger.Debug(WithSampled(ctx, true), "below min level", nil)
+
+	if logger.BatchSize() != 1 || logger.batchQueue[0].Message != "kept" {
+		t.Errorf("Expected only the sampled-in entry, got %+v", logger.batchQueue)
+	}
+}
+
+func TestLoggerName(t *testing.T) {
+	logger := NewLogger("test_api_key", "web-01", WithLoggerName("payments.worker"))
+	logger.BeginBatch()
+	derived := logger.WithContext(map[string]interface{}{"job": "refund"})
+	derived.BeginBatch()
+
+	ctx := context.Background()
+	logger.Info(ctx, "started", nil)
+	derived.Info(ctx, "running", nil)
+
+	if got := logger.batchQueue[0].Tags["logger"]; got != "payments.worker" {
+		t.Errorf("Expected logger tag payments.worker, got %v", got)
+	}
+	if got := derived.batchQueue[0].Tags["logger"]; got != "payments.worker" {
+		t.Errorf("Expected derived logger to inherit name, got %v", got)
+	}
+	if logger.Hostname() != "web-01" {
+		t.Errorf("Expected hostname unchanged, got %s", logger.Hostname())
+	}
+}
+
+func TestLoggerQueueWarnThreshold(t *testing.T) {
+	var warnings []int
+	logger := NewLogger("test_api_key", "test-service",
+		WithLoggerQueueWarnThreshold(2),
+		WithLoggerOnQueueWarning(func(size int) { warnings = append(warnings, size) }))
+	logger.BeginBatch()
+
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		logger.Info(ctx, "queued", nil)
+	}
+	if !reflect.DeepEqual(warnings, []int{2, 4}) {
+		t.Errorf("Expected warnings at 2 and 4 entries, got %v", warnings)
+	}
+
+	logger.ClearBatch()
+	logger.Info(ctx, "queued", nil)
+	logger.Info(ctx, "queued", nil)
+	if !reflect.DeepEqual(warnings, []int{2, 4, 2}) {
+		t.Errorf("Expected warning to re-arm after the queue drained, got %v", warnings)
+	}
+}
+
+// countingStringer counts how often it is formatted
+type countingStringer struct{ calls *int }
+
+func (c countingStringer) String() string {
+	*c.calls++
+	return "state"
+}
+
+func TestLoggerLogTemplate(t *testing.T) {
+	logger := NewLogger("test_api_key", "test-service", WithLoggerMinLevel(LevelInfo))
+	logger.BeginBatch()
+
+	calls := 0
+	arg := countingStringer{calls: &calls}
+	ctx := context.Background()
+
+	logger.LogTemplate(ctx, LevelDebug, "cache %v", arg)
+	logger.Debugf(ctx, "cache %v", arg)
+	logger.LogTemplate(WithSampled(ctx, false), LevelError, "cache %v", arg)
+	if calls != 0 {
+		t.Errorf("Expected filtered entries not to be formatted, got %d calls", calls)
+	}
+
+	logger.LogTemplate(ctx, LevelWarn, "cache %v after %d tries", arg, 3)
+	if calls != 1 {
+		t.Errorf("Expected 1 format call, got %d", calls)
+	}
+	if logger.BatchSize() != 1 || logger.batchQueue[0].Message != "cache state after 3 tries" {
+		t.Errorf("Expected formatted message queued, got %+v", logger.batchQueue)
+	}
+}
+
+func TestLoggerAuthHeader(t *testing.T) {
+	var headers []http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = append(headers, r.Header.Clone())
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	logger := NewLogger("test_api_key", "test-service")
+	redirectToServer(logger.http, server)
+	logger.Info(ctx, "default", nil)
+
+	custom := NewLogger("test_api_key", "test-service", WithLoggerAuthHeader("X-API-Key", ""))
+	redirectToServer(custom.http, server)
+	custom.Info(ctx, "custom", nil)
+
+	if len(headers) != 2 {
+		t.Fatalf("Expected 2 requests, got %d", len(headers))
+	}
+	if got := headers[0].Get("Authorization"); got != "Bearer test_api_key" {
+		t.Errorf("Expected default Bearer auth, got %q", got)
+	}
+	if got := headers[1].Get("X-API-Key"); got != "test_api_key" {
+		t.Errorf("Expected X-API-Key header with bare key, got %q", got)
+	}
+	if got := headers[1].Get("Authorization"); got != "" {
+		t.Errorf("Expected no Authorization header, got %q", got)
+	}
+}
+
+func TestLoggerSuccessCodes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	logger := NewLogger("test_api_key", "test-service", WithLoggerRetry(1, time.Millisecond, time.Millisecond))
+	redirectToServer(logger.http, server)
+	if err := logger.Info(ctx, "queued", nil); err == nil {
+		t.Error("Expected 202 to fail with the default success codes")
+	}
+
+	accepting := NewLogger("test_api_key", "test-service",
+		WithLoggerSuccessCodes(http.StatusOK, http.StatusCreated, http.StatusAccepted))
+	redirectToServer(accepting.http, server)
+	if err := accepting.Info(ctx, "queued", nil); err != nil {
+		t.Errorf("Expected 202 to be accepted, got %v", err)
+	}
+
+	accepting.BeginBatch()
+	accepting.Info(ctx, "queued", nil)
+	if err := accepting.SendBatch(ctx); err != nil {
+		t.Errorf("Expected 202 batch to be accepted, got %v", err)
+	}
+	if accepting.BatchSize() != 0 {
+		t.Errorf("Expected batch cleared, got %d entries", accepting.BatchSize())
+	}
+}
+
+func TestLoggerFlushLevel(t *testing.T) {
+	var sent []LogEntry
+	fail := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var payload BatchLogsPayload
+		json.NewDecoder(r.Body).Decode(&payload)
+		sent = append(sent, payload.Logs...)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	logger := NewLogger("test_api_key", "test-service", WithLoggerRetry(1, time.Millisecond, time.Millisecond))
+	redirectToServer(logger.http, server)
+
+	ctx := context.Background()
+	logger.BeginBatch()
+	logger.Debug(ctx, "cache miss", nil)
+	logger.Error(ctx, "payment failed", nil)
+	logger.Info(ctx, "request done", nil)
+	logger.Warn(ctx, "slow query", nil)
+
+	fail = true
+	if err := logger.FlushLevel(ctx, LevelWarn); err == nil {
+		t.Fatal("Expected FlushLevel to fail")
+	}
+	if logger.BatchSize() != 4 {
+		t.Fatalf("Expected entries returned to the queue, got %d", logger.BatchSize())
+	}
+
+	fail = false
+	if err := logger.FlushLevel(ctx, LevelWarn); err != nil {
+		t.Fatalf("FlushLevel failed: %v", err)
+	}
+	if len(sent) != 2 || sent[0].Message != "payment failed" || sent[1].Message != "slow query" {
+		t.Errorf("Expected error and warn entries sent, got %+v", sent)
+	}
+	if logger.BatchSize() != 2 || logger.batchQueue[0].Message != "cache miss" || logger.batchQueue[1].Message != "request done" {
+		t.Errorf("Expected debug and info entries kept in order, got %+v", logger.batchQueue)
+	}
+
+	if err := logger.FlushLevel(ctx, LevelError); err != nil || len(sent) != 2 {
+		t.Errorf("Expected nothing to send, got %d entries (%v)", len(sent), err)
+	}
+}
+
+func TestLoggerStreamBatches(t *testing.T) {
+	var bodies []map[string]interface{}
+	var contentLengths []int64
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Expected valid JSON body: %v", err)
+		}
+		bodies = append(bodies, body)
+		contentLengths = append(contentLengths, r.ContentLength)
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	logger := NewLogger("test_api_key", "test-service",
+		WithLoggerStreamBatches(3),
+		WithLoggerCompactBatches(true),
+		WithLoggerRetry(2, time.Millisecond, time.Millisecond),
+		WithLoggerRetryDecider(func(resp *http.Response, err error) bool {
+			return err != nil || resp.StatusCode >= 500
+		}),
+	).WithContext(map[string]interface{}{"service": "api"})
+	redirectToServer(logger.http, server)
+
+	ctx := context.Background()
+	logger.BeginBatch()
+	for i := 0; i < 3; i++ {
+		logger.Info(ctx, fmt.Sprintf("entry %d <%d>", i, i), map[string]interface{}{"n": i})
+	}
+	want, _ := json.Marshal(logger.wireBatch(logger.batchQueue))
+
+	if err := logger.SendBatch(ctx); err != nil {
+		t.Fatalf("SendBatch failed: %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("Expected the streamed body to be re-sent on retry, got %d requests", calls)
+	}
+
+	var wantBody map[string]interface{}
+	json.Unmarshal(want, &wantBody)
+	for i, body := range bodies {
+		if !reflect.DeepEqual(body, wantBody) {
+			t.Errorf("Attempt %d: expected streamed payload %v, got %v", i+1, wantBody, body)
+		}
+		if contentLengths[i] != -1 {
+			t.Errorf("Attempt %d: expected chunked body, got Content-Length %d", i+1, contentLengths[i])
+		}
+	}
+
+	// Below the threshold the payload is marshaled as before
+	logger.Info(ctx, "small", nil)
+	if err := logger.SendBatch(ctx); err != nil {
+		t.Fatalf("SendBatch failed: %v", err)
+	}
+	if contentLengths[len(contentLengths)-1] <= 0 {
+		t.Error("Expected a small batch to be sent with a Content-Length")
+	}
+}
@@ -1,19 +1,38 @@
 package logdot
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math/rand"
+	"net/http"
 	"net/url"
 	"sync"
 	"time"
+	"unicode/utf8"
 )
 
+// ErrAmbiguousEntityName is returned by GetEntityByName and
+// GetOrCreateEntity when WithMetricsStrictEntityNames is set and more than
+// one entity has the requested name
+var ErrAmbiguousEntityName = errors.New("ambiguous entity name")
+
 // BoundMetrics is a metrics client bound to a specific entity
 type BoundMetrics struct {
-	http     *HTTPClient
-	entityID string
-	debug    bool
+	http         *HTTPClient
+	entityID     string
+	debug        bool
+	defaultUnit  string
+	maxTagLength int
+	defaultTags  map[string]interface{}
+	unitAliases  map[string]string
+	coalesce     CoalesceMode
+
+	// autoFlushSize is the queue size at which AddWithAutoFlush sends
+	// the batch (0 = never)
+	autoFlushSize int
 
 	mu              sync.Mutex
 	batchMode       bool
@@ -23,15 +42,45 @@ type BoundMetrics struct {
 	batchQueue      []MetricEntry
 	lastError       string
 	lastHTTPCode    int
+
+	// shards, when set, replace batchQueue so concurrent Add calls only
+	// lock one shard and never b.mu. The batch mode fields are changed
+	// with every shard locked, so Add can read them under its shard's lock.
+	shards []metricShard
+}
+
+// cacheLineSize pads shards apart so their locks don't share a cache line
+const cacheLineSize = 64
+
+// metricShard is one independently locked part of a sharded batch queue
+type metricShard struct {
+	mu    sync.Mutex
+	queue []MetricEntry
+	_     [cacheLineSize]byte
 }
 
 // Metrics handles entity management and metrics client creation
 type Metrics struct {
-	http  *HTTPClient
-	debug bool
+	http         *HTTPClient
+	debug        bool
+	defaultUnit  string
+	maxTagLength int
+	defaultTags  map[string]interface{}
+	unitAliases  map[string]string
+	coalesce     CoalesceMode
+	batchShards  int
+
+	// autoFlushSize is passed to every BoundMetrics
+	autoFlushSize int
+
+	// strictEntityNames makes GetEntityByName fail on shared names
+	strictEntityNames bool
 
 	lastError    string
 	lastHTTPCode int
+
+	// config is the resolved configuration, reported by Config
+	config MetricsConfig
 }
 
 // DefaultMetricsConfig returns a MetricsConfig with default values
@@ -61,19 +110,40 @@ func NewMetrics(apiKey string, opts ...MetricsOption) *Metrics {
 		opt(&config)
 	}
 
+	httpClient := NewHTTPClient(
+		config.APIKey,
+		config.Timeout,
+		RetryConfig{
+			MaxAttempts: config.RetryAttempts,
+			BaseDelay:   config.RetryBaseDelay,
+			MaxDelay:    config.RetryMaxDelay,
+		},
+		config.Debug,
+	)
+	if config.Clock != nil {
+		httpClient.clock = config.Clock
+	}
+	if config.HTTPClient != nil {
+		httpClient.client = config.HTTPClient
+	}
+	if len(config.SuccessCodes) > 0 {
+		httpClient.successCodes = append([]int(nil), config.SuccessCodes...)
+	}
+
 	return &Metrics{
-		http: NewHTTPClient(
-			config.APIKey,
-			config.Timeout,
-			RetryConfig{
-				MaxAttempts: config.RetryAttempts,
-				BaseDelay:   config.RetryBaseDelay,
-				MaxDelay:    config.RetryMaxDelay,
-			},
-			config.Debug,
-		),
+		http:         httpClient,
 		debug:        config.Debug,
+		defaultUnit:  config.DefaultUnit,
+		maxTagLength: config.MaxTagLength,
+		defaultTags:  copyTags(config.DefaultTags),
+		unitAliases:  copyUnitAliases(config.UnitAliases),
+		coalesce:     config.Coalesce,
+		batchShards:  config.BatchShards,
 		lastHTTPCode: -1,
+		config:       config,
+
+		autoFlushSize:     config.AutoFlushSize,
+		strictEntityNames: config.StrictEntityNames,
 	}
 }
 
@@ -96,6 +166,14 @@ func WithMetricsRetry(attempts int, baseDelay, maxDelay time.Duration) MetricsOp
 	}
 }
 
+// WithMetricsSuccessCodes sets the HTTP statuses that count as a
+// successful metric send, replacing the default of 200 and 201
+func WithMetricsSuccessCodes(codes ...int) MetricsOption {
+	return func(c *MetricsConfig) {
+		c.SuccessCodes = codes
+	}
+}
+
 // WithMetricsDebug enables debug output
 func WithMetricsDebug(enabled bool) MetricsOption {
 	return func(c *MetricsConfig) {
@@ -103,6 +181,111 @@ func WithMetricsDebug(enabled bool) MetricsOption {
 	}
 }
 
+// WithMetricsDefaultUnit sets the unit used when Send, BeginBatch or
+// AddMetric are given an empty unit
+func WithMetricsDefaultUnit(unit string) MetricsOption {
+	return func(c *MetricsConfig) {
+		c.DefaultUnit = unit
+	}
+}
+
+// WithMetricsMaxTagLength truncates each "key:value" tag string to at most
+// n bytes, ending in "...". Zero (the default) disables truncation.
+func WithMetricsMaxTagLength(n int) MetricsOption {
+	return func(c *MetricsConfig) {
+		c.MaxTagLength = n
+	}
+}
+
+// WithMetricsDefaultTags sets tags added to every metric sent by clients
+// from this Metrics instance. Tags passed to Send, Add or AddMetric take
+// precedence over defaults with the same key.
+//
+// Example:
+//
+//	metrics := logdot.NewMetrics(apiKey, logdot.WithMetricsDefaultTags(map[string]interface{}{
+//		"host":   hostname,
+//		"region": "eu-west",
+//	}))
+func WithMetricsDefaultTags(tags map[string]interface{}) MetricsOption {
+	return func(c *MetricsConfig) {
+		c.DefaultTags = tags
+	}
+}
+
+// WithMetricsBatchShards splits each bound client's batch queue into n
+// shards with their own locks, so Add and AddMetric calls from many
+// goroutines don't serialize on a single mutex. SendBatch merges the
+// shards, so entries added concurrently are not sent in call order.
+func WithMetricsBatchShards(n int) MetricsOption {
+	return func(c *MetricsConfig) {
+		c.BatchShards = n
+	}
+}
+
+// WithMetricsCoalesce pre-aggregates a multi-metric batch in SendBatch:
+// metrics with the same name, unit and tags are sent as one point, either
+// their sum (CoalesceSum) or the last value added (CoalesceLast). This
+// keeps collectors that append the same metric several times per tick
+// from sending duplicate points. Single-metric batches are not coalesced.
+func WithMetricsCoalesce(mode CoalesceMode) MetricsOption {
+	return func(c *MetricsConfig) {
+		c.Coalesce = mode
+	}
+}
+
+// WithMetricsUnitAliases canonicalizes metric units before they are sent:
+// a unit found as a key in aliases is replaced by its value. It applies to
+// every way of sending a metric. By default units are sent as given.
+//
+// Example:
+//
+//	logdot.WithMetricsUnitAliases(map[string]string{
+//		"milliseconds": "ms",
+//		"millisecond":  "ms",
+//		"seconds":      "s",
+//	})
+func WithMetricsUnitAliases(aliases map[string]string) MetricsOption {
+	return func(c *MetricsConfig) {
+		c.UnitAliases = aliases
+	}
+}
+
+// WithMetricsAutoFlushSize makes AddWithAutoFlush send the batch once n
+// values are queued, so collector loops don't need to check BatchSize
+// after every Add
+func WithMetricsAutoFlushSize(n int) MetricsOption {
+	return func(c *MetricsConfig) {
+		c.AutoFlushSize = n
+	}
+}
+
+// WithMetricsStrictEntityNames makes GetEntityByName and GetOrCreateEntity
+// return ErrAmbiguousEntityName when several entities share the requested
+// name, instead of using the first one (which is reported in debug output).
+// This keeps metrics from two services with the same name from silently
+// merging.
+func WithMetricsStrictEntityNames(enabled bool) MetricsOption {
+	return func(c *MetricsConfig) {
+		c.StrictEntityNames = enabled
+	}
+}
+
+// WithMetricsHTTPClient sets the underlying *http.Client, for custom transports
+// or proxies. The client's own Timeout applies instead of the configured one.
+func WithMetricsHTTPClient(client *http.Client) MetricsOption {
+	return func(c *MetricsConfig) {
+		c.HTTPClient = client
+	}
+}
+
+// WithMetricsClock sets the time source used for retry backoff
+func WithMetricsClock(clock Clock) MetricsOption {
+	return func(c *MetricsConfig) {
+		c.Clock = clock
+	}
+}
+
 // CreateEntity creates a new entity
 //
 // Example:
@@ -163,41 +346,114 @@ func (m *Metrics) CreateEntity(ctx context.Context, opts CreateEntityOptions) (*
 //		client := metrics.ForEntity(entity.ID)
 //	}
 func (m *Metrics) GetEntityByName(ctx context.Context, name string) (*Entity, error) {
+	entities, status, err := m.lookupEntities(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if status != 200 {
+		return nil, fmt.Errorf("entity not found (status %d)", status)
+	}
+	if len(entities) == 0 {
+		m.lastError = "no entity ID in response"
+		return nil, fmt.Errorf("no entity ID in response")
+	}
+
+	if len(entities) > 1 {
+		if m.strictEntityNames {
+			m.lastError = fmt.Sprintf("%d entities named %q", len(entities), name)
+			return nil, fmt.Errorf("%w: %d entities named %q", ErrAmbiguousEntityName, len(entities), name)
+		}
+		m.debugLog(fmt.Sprintf("WARNING: %d entities named %q, using %s", len(entities), name, entities[0].ID))
+	}
+
+	m.lastError = ""
+	m.debugLog(fmt.Sprintf("Entity found: %s", entities[0].ID))
+
+	return &entities[0], nil
+}
+
+// FindEntities returns every entity called name. It returns an empty
+// slice, not an error, when there is none.
+//
+// Example:
+//
+//	entities, err := metrics.FindEntities(ctx, "my-service")
+//	if err == nil && len(entities) > 1 {
+//		log.Printf("entity name my-service is shared by %d entities", len(entities))
+//	}
+func (m *Metrics) FindEntities(ctx context.Context, name string) ([]Entity, error) {
+	entities, status, err := m.lookupEntities(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if status == 404 {
+		m.lastError = ""
+		return []Entity{}, nil
+	}
+	if status != 200 {
+		return nil, fmt.Errorf("entity lookup failed with status %d", status)
+	}
+
+	m.lastError = ""
+	return entities, nil
+}
+
+// entityData is an entity as returned by the by-name endpoint
+type entityData struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// lookupEntities queries the by-name endpoint. The response data is a
+// single entity or, when the name is shared, an array of them; entries
+// without an ID are skipped. entities is nil unless status is 200.
+func (m *Metrics) lookupEntities(ctx context.Context, name string) (entities []Entity, status int, err error) {
 	encodedName := url.PathEscape(name)
 	reqURL := fmt.Sprintf("%s/entities/by-name/%s", baseMetricsURL, encodedName)
 
 	resp, body, err := m.http.Get(ctx, reqURL)
 	if err != nil {
 		m.lastError = err.Error()
-		return nil, err
+		return nil, 0, err
 	}
 
 	m.lastHTTPCode = resp.StatusCode
 
 	if resp.StatusCode != 200 {
 		m.lastError = fmt.Sprintf("HTTP %d", resp.StatusCode)
-		return nil, fmt.Errorf("entity not found (status %d)", resp.StatusCode)
+		return nil, resp.StatusCode, nil
 	}
 
-	var apiResp APIResponse
+	var apiResp struct {
+		Data json.RawMessage `json:"data"`
+	}
 	if err := json.Unmarshal(body, &apiResp); err != nil {
 		m.lastError = err.Error()
-		return nil, err
+		return nil, resp.StatusCode, err
 	}
 
-	if apiResp.Data.ID == "" {
-		m.lastError = "no entity ID in response"
-		return nil, fmt.Errorf("no entity ID in response")
+	data := bytes.TrimSpace(apiResp.Data)
+	var found []entityData
+	if len(data) > 0 && data[0] == '[' {
+		err = json.Unmarshal(data, &found)
+	} else if len(data) > 0 && !bytes.Equal(data, []byte("null")) {
+		found = make([]entityData, 1)
+		err = json.Unmarshal(data, &found[0])
+	}
+	if err != nil {
+		m.lastError = err.Error()
+		return nil, resp.StatusCode, err
 	}
 
-	m.lastError = ""
-	m.debugLog(fmt.Sprintf("Entity found: %s", apiResp.Data.ID))
-
-	return &Entity{
-		ID:          apiResp.Data.ID,
-		Name:        apiResp.Data.Name,
-		Description: apiResp.Data.Description,
-	}, nil
+	entities = make([]Entity, 0, len(found))
+	for _, e := range found {
+		if e.ID == "" {
+			continue
+		}
+		entities = append(entities, Entity{ID: e.ID, Name: e.Name, Description: e.Description})
+	}
+	return entities, resp.StatusCode, nil
 }
 
 // GetOrCreateEntity retrieves an existing entity or creates a new one
@@ -208,14 +464,56 @@ func (m *Metrics) GetEntityByName(ctx context.Context, name string) (*Entity, er
 //		Name: "my-service",
 //	})
 func (m *Metrics) GetOrCreateEntity(ctx context.Context, opts CreateEntityOptions) (*Entity, error) {
+	result, err := m.GetOrCreateEntityResult(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return result.Entity, nil
+}
+
+// GetOrCreateEntityResult is like GetOrCreateEntity but also reports
+// whether the entity was newly created
+//
+// Example:
+//
+//	result, err := metrics.GetOrCreateEntityResult(ctx, logdot.CreateEntityOptions{Name: "my-service"})
+//	if err == nil && result.Created {
+//		seedDashboards(result.Entity)
+//	}
+func (m *Metrics) GetOrCreateEntityResult(ctx context.Context, opts CreateEntityOptions) (*GetOrCreateEntityResult, error) {
 	// Try to find existing entity first
 	entity, err := m.GetEntityByName(ctx, opts.Name)
 	if err == nil && entity != nil {
-		return entity, nil
+		return &GetOrCreateEntityResult{Entity: entity}, nil
+	}
+	if errors.Is(err, ErrAmbiguousEntityName) {
+		return nil, err
 	}
 
 	// Create new entity
-	return m.CreateEntity(ctx, opts)
+	entity, err = m.CreateEntity(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &GetOrCreateEntityResult{Entity: entity, Created: true}, nil
+}
+
+// BoundForName resolves the entity called name, creating it if needed,
+// and returns a client bound to it
+//
+// Example:
+//
+//	client, err := metrics.BoundForName(ctx, "my-service")
+//	if err != nil {
+//		return err
+//	}
+//	client.Send(ctx, "cpu.usage", 45, "percent", nil)
+func (m *Metrics) BoundForName(ctx context.Context, name string) (*BoundMetrics, error) {
+	entity, err := m.GetOrCreateEntity(ctx, CreateEntityOptions{Name: name})
+	if err != nil {
+		return nil, err
+	}
+	return m.ForEntity(entity.ID), nil
 }
 
 // ForEntity creates a bound metrics client for a specific entity
@@ -226,13 +524,123 @@ func (m *Metrics) GetOrCreateEntity(ctx context.Context, opts CreateEntityOption
 //	client := metrics.ForEntity(entity.ID)
 //	client.Send(ctx, "cpu.usage", 45, "percent", nil)
 func (m *Metrics) ForEntity(entityID string) *BoundMetrics {
-	return &BoundMetrics{
+	b := &BoundMetrics{
 		http:         m.http,
 		entityID:     entityID,
 		debug:        m.debug,
+		defaultUnit:  m.defaultUnit,
+		maxTagLength: m.maxTagLength,
+		defaultTags:  m.defaultTags,
+		unitAliases:  m.unitAliases,
+		coalesce:     m.coalesce,
 		batchQueue:   make([]MetricEntry, 0),
 		lastHTTPCode: -1,
+
+		autoFlushSize: m.autoFlushSize,
+	}
+	if m.batchShards > 1 {
+		b.shards = make([]metricShard, m.batchShards)
+	}
+	return b
+}
+
+// SendMerged sends the queued metrics of several clients bound to the
+// same entity in a single batch request, for apps where each subsystem
+// batches into its own BoundMetrics. Clients in single- or multi-metric
+// batch mode can be mixed; clients not in batch mode or with an empty
+// queue are skipped. As with SendBatch, each queue is taken when the
+// request starts, so metrics added meanwhile stay queued for the next
+// send, and is put back if the request fails. Batch mode is left
+// unchanged.
+//
+// Example:
+//
+//	err := metrics.SendMerged(ctx, httpMetrics, dbMetrics, cacheMetrics)
+func (m *Metrics) SendMerged(ctx context.Context, clients ...*BoundMetrics) error {
+	var entityID string
+	for _, b := range clients {
+		if b == nil {
+			continue
+		}
+		if entityID == "" {
+			entityID = b.entityID
+		} else if b.entityID != entityID {
+			return fmt.Errorf("cannot merge batches for different entities %q and %q", entityID, b.entityID)
+		}
+	}
+
+	// Each client's queue is detached under its lock so entries added
+	// during the request stay queued, and put back if the send fails.
+	type detached struct {
+		b     *BoundMetrics
+		queue []MetricEntry
+		multi bool
+		name  string
+	}
+	var merged []BatchMetricEntry
+	var sources []detached
+	seen := make(map[*BoundMetrics]bool, len(clients))
+	for _, b := range clients {
+		if b == nil || seen[b] {
+			continue
+		}
+		seen[b] = true
+
+		b.mu.Lock()
+		if b.batchMode && b.queueLen() > 0 {
+			d := detached{b: b, queue: b.detach(), multi: b.multiBatchMode, name: b.batchMetricName}
+			sent := d.queue
+			if d.multi && b.coalesce != CoalesceOff {
+				sent = coalesceMetrics(sent, b.coalesce)
+			}
+			for _, entry := range sent {
+				merged = append(merged, BatchMetricEntry{
+					Name:  entry.Name,
+					Value: entry.Value,
+					Unit:  entry.Unit,
+					Tags:  entry.Tags,
+				})
+			}
+			sources = append(sources, d)
+		}
+		b.mu.Unlock()
+	}
+	if len(merged) == 0 {
+		return nil
 	}
+
+	requeue := func() {
+		for _, d := range sources {
+			d.b.mu.Lock()
+			d.b.requeue(d.queue, d.multi, d.name)
+			d.b.mu.Unlock()
+		}
+	}
+
+	payload := BatchMetricsPayload{
+		EntityID: entityID,
+		Metrics:  merged,
+	}
+
+	reqURL := baseMetricsURL + "/metrics/batch"
+	resp, _, err := m.http.Post(ctx, reqURL, payload)
+	if err != nil {
+		requeue()
+		m.lastError = err.Error()
+		return err
+	}
+
+	m.lastHTTPCode = resp.StatusCode
+
+	if !m.http.accepted(resp.StatusCode) {
+		requeue()
+		m.lastError = fmt.Sprintf("HTTP %d", resp.StatusCode)
+		return fmt.Errorf("merged batch send failed with status %d", resp.StatusCode)
+	}
+
+	m.lastError = ""
+	m.debugLog(fmt.Sprintf("Sent %d metrics from %d clients in one batch", len(merged), len(sources)))
+	return nil
 }
 
 // LastError returns the last error message
@@ -245,6 +653,41 @@ func (m *Metrics) LastHTTPCode() int {
 	return m.lastHTTPCode
 }
 
+// Config returns a copy of the client's resolved configuration, with the
+// API key masked
+func (m *Metrics) Config() MetricsConfig {
+	config := m.config
+	config.APIKey = maskAPIKey(config.APIKey)
+	config.Debug = m.debug
+
+	config.SuccessCodes = append([]int(nil), config.SuccessCodes...)
+	if config.DefaultTags != nil {
+		config.DefaultTags = make(map[string]interface{}, len(m.config.DefaultTags))
+		for k, v := range m.config.DefaultTags {
+			config.DefaultTags[k] = v
+		}
+	}
+	if config.UnitAliases != nil {
+		config.UnitAliases = make(map[string]string, len(m.config.UnitAliases))
+		for k, v := range m.config.UnitAliases {
+			config.UnitAliases[k] = v
+		}
+	}
+
+	retry := m.http.retryConfig()
+	config.RetryAttempts = retry.MaxAttempts
+	config.RetryBaseDelay = retry.BaseDelay
+	config.RetryMaxDelay = retry.MaxDelay
+	return config
+}
+
+// SetRetryConfig replaces the retry configuration at runtime, for this
+// client and the BoundMetrics created from it. Requests already being
+// retried keep their configuration; new requests use the updated one.
+func (m *Metrics) SetRetryConfig(retry RetryConfig) {
+	m.http.setRetryConfig(retry)
+}
+
 // SetDebug enables or disables debug output
 func (m *Metrics) SetDebug(enabled bool) {
 	m.debug = enabled
@@ -277,43 +720,123 @@ func (b *BoundMetrics) Send(ctx context.Context, name string, value float64, uni
 		EntityID: b.entityID,
 		Name:     name,
 		Value:    value,
-		Unit:     unit,
-		Tags:     formatTags(tags),
+		Unit:     b.resolveUnit(unit),
+		Tags:     b.formatTags(tags),
 	}
 
+	return b.sendEntry(ctx, entry)
+}
+
+// SendWithResponse transmits a single metric like Send and returns the
+// server's acknowledgement, for comparing client and server clocks or
+// confirming the accepted value. If the server accepts the metric but its
+// response cannot be decoded, e.g. an empty body, the acknowledgement
+// holds the metric as sent, with no ID and a zero Timestamp.
+//
+// Example:
+//
+//	ack, err := metrics.SendWithResponse(ctx, "cpu.usage", 45.2, "percent", nil)
+//	if err == nil && !ack.Data.Timestamp.IsZero() {
+//		skew := time.Since(ack.Data.Timestamp)
+//	}
+func (b *BoundMetrics) SendWithResponse(ctx context.Context, name string, value float64, unit string, tags map[string]interface{}) (*MetricResponse, error) {
+	b.mu.Lock()
+	if b.batchMode {
+		b.mu.Unlock()
+		b.lastError = "cannot use SendWithResponse() in batch mode"
+		return nil, fmt.Errorf("cannot use SendWithResponse() in batch mode")
+	}
+	b.mu.Unlock()
+
+	entry := MetricEntry{
+		EntityID: b.entityID,
+		Name:     name,
+		Value:    value,
+		Unit:     b.resolveUnit(unit),
+		Tags:     b.formatTags(tags),
+	}
+
+	body, err := b.postEntry(ctx, entry)
+	if err != nil {
+		return nil, err
+	}
+
+	var apiResp MetricResponse
+	if err := json.Unmarshal(body, &apiResp); err != nil {
+		// The metric was accepted; only the acknowledgement is unreadable
+		if b.debug {
+			fmt.Printf("[LogDotMetrics] Could not decode the response for %s: %v\n", name, err)
+		}
+		apiResp = MetricResponse{}
+		apiResp.Data.Name = entry.Name
+		apiResp.Data.Value = entry.Value
+		apiResp.Data.Unit = entry.Unit
+	}
+	return &apiResp, nil
+}
+
+// sendEntry posts a single pre-built metric entry
+func (b *BoundMetrics) sendEntry(ctx context.Context, entry MetricEntry) error {
+	_, err := b.postEntry(ctx, entry)
+	return err
+}
+
+// postEntry posts a single metric entry and returns the response body
+func (b *BoundMetrics) postEntry(ctx context.Context, entry MetricEntry) ([]byte, error) {
 	reqURL := baseMetricsURL + "/metrics"
-	resp, _, err := b.http.Post(ctx, reqURL, entry)
+	resp, body, err := b.http.Post(ctx, reqURL, entry)
 	if err != nil {
 		b.lastError = err.Error()
-		return err
+		return nil, err
 	}
 
 	b.mu.Lock()
 	b.lastHTTPCode = resp.StatusCode
 	b.mu.Unlock()
 
-	if resp.StatusCode != 200 && resp.StatusCode != 201 {
+	if !b.http.accepted(resp.StatusCode) {
 		b.lastError = fmt.Sprintf("HTTP %d", resp.StatusCode)
-		return fmt.Errorf("metric send failed with status %d", resp.StatusCode)
+		return nil, fmt.Errorf("metric send failed with status %d", resp.StatusCode)
 	}
 
 	b.lastError = ""
-	return nil
+	return body, nil
 }
 
 // BeginBatch starts single-metric batch mode
 func (b *BoundMetrics) BeginBatch(metricName, unit string) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
+	b.lockShards()
+	defer b.unlockShards()
 	b.batchMode = true
 	b.multiBatchMode = false
 	b.batchMetricName = metricName
-	b.batchUnit = unit
-	b.batchQueue = make([]MetricEntry, 0)
+	b.batchUnit = b.resolveUnit(unit)
+	b.resetQueue()
 }
 
 // Add adds a value to the current batch
 func (b *BoundMetrics) Add(value float64, tags map[string]interface{}) error {
+	if b.shards != nil {
+		formatted := b.formatTags(tags)
+		shard := b.lockShard()
+		ok := b.batchMode && !b.multiBatchMode
+		if ok {
+			shard.queue = append(shard.queue, MetricEntry{
+				Name:  b.batchMetricName,
+				Value: value,
+				Unit:  b.batchUnit,
+				Tags:  formatted,
+			})
+		}
+		shard.mu.Unlock()
+		if !ok {
+			return b.modeError("not in single-metric batch mode")
+		}
+		return nil
+	}
+
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
@@ -326,23 +849,68 @@ func (b *BoundMetrics) Add(value float64, tags map[string]interface{}) error {
 		Name:  b.batchMetricName,
 		Value: value,
 		Unit:  b.batchUnit,
-		Tags:  formatTags(tags),
+		Tags:  b.formatTags(tags),
 	})
 
 	return nil
 }
 
+// AddWithAutoFlush adds a value to the current batch like Add, then sends
+// the batch if it has reached the size set with WithMetricsAutoFlushSize.
+// The returned error is from Add or, when a flush was triggered, from
+// SendBatch; the client stays in batch mode either way.
+//
+// Example:
+//
+//	metrics := logdot.NewMetrics(apiKey, logdot.WithMetricsAutoFlushSize(100))
+//	client := metrics.ForEntity(entityID)
+//	client.BeginBatch("temperature", "celsius")
+//	for reading := range readings {
+//		client.AddWithAutoFlush(ctx, reading, nil)
+//	}
+//	client.SendBatch(ctx) // send the remainder
+func (b *BoundMetrics) AddWithAutoFlush(ctx context.Context, value float64, tags map[string]interface{}) error {
+	if err := b.Add(value, tags); err != nil {
+		return err
+	}
+	if b.autoFlushSize > 0 {
+		return b.sendBatch(ctx, b.autoFlushSize)
+	}
+	return nil
+}
+
 // BeginMultiBatch starts multi-metric batch mode
 func (b *BoundMetrics) BeginMultiBatch() {
 	b.mu.Lock()
 	defer b.mu.Unlock()
+	b.lockShards()
+	defer b.unlockShards()
 	b.batchMode = true
 	b.multiBatchMode = true
-	b.batchQueue = make([]MetricEntry, 0)
+	b.resetQueue()
 }
 
 // AddMetric adds a metric to the multi-batch queue
 func (b *BoundMetrics) AddMetric(name string, value float64, unit string, tags map[string]interface{}) error {
+	if b.shards != nil {
+		entry := MetricEntry{
+			Name:  name,
+			Value: value,
+			Unit:  b.resolveUnit(unit),
+			Tags:  b.formatTags(tags),
+		}
+		shard := b.lockShard()
+		ok := b.multiBatchMode
+		if ok {
+			shard.queue = append(shard.queue, entry)
+		}
+		shard.mu.Unlock()
+		if !ok {
+			return b.modeError("not in multi-metric batch mode")
+		}
+		return nil
+	}
+
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
@@ -354,29 +922,43 @@ func (b *BoundMetrics) AddMetric(name string, value float64, unit string, tags m
 	b.batchQueue = append(b.batchQueue, MetricEntry{
 		Name:  name,
 		Value: value,
-		Unit:  unit,
-		Tags:  formatTags(tags),
+		Unit:  b.resolveUnit(unit),
+		Tags:  b.formatTags(tags),
 	})
 
 	return nil
 }
 
-// SendBatch sends all queued metrics
+// SendBatch sends all queued metrics. The queue is detached before the
+// request, so metrics added meanwhile are kept for the next batch; on
+// failure the sent entries are put back at the front of the queue.
 func (b *BoundMetrics) SendBatch(ctx context.Context) error {
+	return b.sendBatch(ctx, 1)
+}
+
+// sendBatch sends the queue if it holds at least minSize entries
+func (b *BoundMetrics) sendBatch(ctx context.Context, minSize int) error {
 	b.mu.Lock()
-	if !b.batchMode || len(b.batchQueue) == 0 {
+	if !b.batchMode || b.queueLen() < minSize {
 		b.mu.Unlock()
 		return nil
 	}
+	queue := b.detach()
+	multi, name := b.multiBatchMode, b.batchMetricName
 
-	metrics := make([]BatchMetricEntry, len(b.batchQueue))
-	for i, entry := range b.batchQueue {
+	sent := queue
+	if multi && b.coalesce != CoalesceOff {
+		sent = coalesceMetrics(queue, b.coalesce)
+	}
+
+	metrics := make([]BatchMetricEntry, len(sent))
+	for i, entry := range sent {
 		metrics[i] = BatchMetricEntry{
 			Value: entry.Value,
 			Unit:  entry.Unit,
 			Tags:  entry.Tags,
 		}
-		if b.multiBatchMode {
+		if multi {
 			metrics[i].Name = entry.Name
 		}
 	}
@@ -386,28 +968,31 @@ func (b *BoundMetrics) SendBatch(ctx context.Context) error {
 		Metrics:  metrics,
 	}
 
-	if !b.multiBatchMode {
-		payload.Name = b.batchMetricName
+	if !multi {
+		payload.Name = name
 	}
 	b.mu.Unlock()
 
 	reqURL := baseMetricsURL + "/metrics/batch"
 	resp, _, err := b.http.Post(ctx, reqURL, payload)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
 	if err != nil {
+		b.requeue(queue, multi, name)
 		b.lastError = err.Error()
 		return err
 	}
 
-	b.mu.Lock()
 	b.lastHTTPCode = resp.StatusCode
-	b.mu.Unlock()
 
-	if resp.StatusCode != 200 && resp.StatusCode != 201 {
+	if !b.http.accepted(resp.StatusCode) {
+		b.requeue(queue, multi, name)
 		b.lastError = fmt.Sprintf("HTTP %d", resp.StatusCode)
 		return fmt.Errorf("batch send failed with status %d", resp.StatusCode)
 	}
 
-	b.ClearBatch()
 	b.lastError = ""
 	return nil
 }
@@ -416,23 +1001,129 @@ func (b *BoundMetrics) SendBatch(ctx context.Context) error {
 func (b *BoundMetrics) EndBatch() {
 	b.mu.Lock()
 	defer b.mu.Unlock()
+	b.lockShards()
+	defer b.unlockShards()
 	b.batchMode = false
 	b.multiBatchMode = false
-	b.batchQueue = make([]MetricEntry, 0)
+	b.resetQueue()
 }
 
 // ClearBatch clears the batch queue
 func (b *BoundMetrics) ClearBatch() {
 	b.mu.Lock()
 	defer b.mu.Unlock()
-	b.batchQueue = make([]MetricEntry, 0)
+	b.lockShards()
+	defer b.unlockShards()
+	b.resetQueue()
 }
 
 // BatchSize returns the number of queued metrics
 func (b *BoundMetrics) BatchSize() int {
 	b.mu.Lock()
 	defer b.mu.Unlock()
-	return len(b.batchQueue)
+	return b.queueLen()
+}
+
+// queueLen returns the number of queued metrics. Must be called with b.mu
+// held.
+func (b *BoundMetrics) queueLen() int {
+	if b.shards == nil {
+		return len(b.batchQueue)
+	}
+	n := 0
+	for i := range b.shards {
+		shard := &b.shards[i]
+		shard.mu.Lock()
+		n += len(shard.queue)
+		shard.mu.Unlock()
+	}
+	return n
+}
+
+// lockShard locks and returns a shard for an Add call. It starts at a
+// random shard, so callers share no counter, and takes the first one not
+// already locked.
+func (b *BoundMetrics) lockShard() *metricShard {
+	n := uint32(len(b.shards))
+	start := rand.Uint32() % n
+	for i := uint32(0); i < n; i++ {
+		shard := &b.shards[(start+i)%n]
+		if shard.mu.TryLock() {
+			return shard
+		}
+	}
+	shard := &b.shards[start]
+	shard.mu.Lock()
+	return shard
+}
+
+// lockShards locks every shard, in order, so the batch mode fields can
+// change. Must be called with b.mu held.
+func (b *BoundMetrics) lockShards() {
+	for i := range b.shards {
+		b.shards[i].mu.Lock()
+	}
+}
+
+// unlockShards releases the locks taken by lockShards
+func (b *BoundMetrics) unlockShards() {
+	for i := range b.shards {
+		b.shards[i].mu.Unlock()
+	}
+}
+
+// detach removes and returns the queued entries, merging shards, so they
+// can be sent without holding b.mu. Entries added to a shard after it has
+// been taken stay queued. Must be called with b.mu held.
+func (b *BoundMetrics) detach() []MetricEntry {
+	if b.shards == nil {
+		queue := b.batchQueue
+		b.batchQueue = make([]MetricEntry, 0)
+		return queue
+	}
+	var merged []MetricEntry
+	for i := range b.shards {
+		shard := &b.shards[i]
+		shard.mu.Lock()
+		merged = append(merged, shard.queue...)
+		shard.queue = nil
+		shard.mu.Unlock()
+	}
+	return merged
+}
+
+// requeue puts entries from a failed send back at the front of the queue,
+// unless the batch they belong to has since ended or been restarted with
+// another mode or metric name. Must be called with b.mu held.
+func (b *BoundMetrics) requeue(entries []MetricEntry, multi bool, name string) {
+	if !b.batchMode || b.multiBatchMode != multi || (!multi && b.batchMetricName != name) {
+		return
+	}
+	if b.shards == nil {
+		b.batchQueue = append(entries[:len(entries):len(entries)], b.batchQueue...)
+		return
+	}
+	shard := &b.shards[0]
+	shard.mu.Lock()
+	shard.queue = append(entries[:len(entries):len(entries)], shard.queue...)
+	shard.mu.Unlock()
+}
+
+// resetQueue empties the queue and any shards. Must be called with b.mu
+// and every shard lock held.
+func (b *BoundMetrics) resetQueue() {
+	b.batchQueue = make([]MetricEntry, 0)
+	for i := range b.shards {
+		b.shards[i].queue = nil
+	}
+}
+
+// modeError records and returns a batch mode error
+func (b *BoundMetrics) modeError(msg string) error {
+	b.mu.Lock()
+	b.lastError = msg
+	b.mu.Unlock()
+	return fmt.Errorf("%s", msg)
 }
 
 // LastError returns the last error message
@@ -454,6 +1145,71 @@ func (b *BoundMetrics) SetDebug(enabled bool) {
 	b.debug = enabled
 }
 
+// resolveUnit returns unit, or the default unit when unit is empty,
+// canonicalized through the unit aliases
+func (b *BoundMetrics) resolveUnit(unit string) string {
+	if unit == "" {
+		unit = b.defaultUnit
+	}
+	if canonical, ok := b.unitAliases[unit]; ok {
+		return canonical
+	}
+	return unit
+}
+
+// copyUnitAliases returns a copy of aliases, or nil when it is empty
+func copyUnitAliases(aliases map[string]string) map[string]string {
+	if len(aliases) == 0 {
+		return nil
+	}
+	copied := make(map[string]string, len(aliases))
+	for alias, canonical := range aliases {
+		copied[alias] = canonical
+	}
+	return copied
+}
+
+// formatTags formats tags merged over the default tags, truncating them to
+// the configured max length. NoTags yields no tags at all.
+func (b *BoundMetrics) formatTags(tags map[string]interface{}) []string {
+	if isNoTags(tags) {
+		return nil
+	}
+	if len(b.defaultTags) > 0 {
+		merged := make(map[string]interface{}, len(b.defaultTags)+len(tags))
+		for k, v := range b.defaultTags {
+			merged[k] = v
+		}
+		for k, v := range tags {
+			merged[k] = v
+		}
+		tags = merged
+	}
+	result := formatTags(tags)
+	if b.maxTagLength > 0 {
+		for i, tag := range result {
+			result[i] = truncateTag(tag, b.maxTagLength)
+		}
+	}
+	return result
+}
+
+// truncateTag shortens tag to at most max bytes, keeping valid UTF-8
+func truncateTag(tag string, max int) string {
+	if len(tag) <= max {
+		return tag
+	}
+	const ellipsis = "..."
+	if max <= len(ellipsis) {
+		return ellipsis[:max]
+	}
+	truncated := tag[:max-len(ellipsis)]
+	for len(truncated) > 0 && !utf8.ValidString(truncated) {
+		truncated = truncated[:len(truncated)-1]
+	}
+	return truncated + ellipsis
+}
+
 // formatTags converts a map to a list of "key:value" strings
 func formatTags(tags map[string]interface{}) []string {
 	if tags == nil || len(tags) == 0 {
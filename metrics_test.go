@@ -3,10 +3,15 @@ package logdot
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestNewMetrics(t *testing.T) {
@@ -258,3 +263,634 @@ func TestBoundMetricsWithTags(t *testing.T) {
 		t.Errorf("Expected batch size 2, got %d", client.BatchSize())
 	}
 }
+
+func TestMetricsDefaultUnit(t *testing.T) {
+	metrics := NewMetrics("test_api_key", WithMetricsDefaultUnit("ms"))
+	client := metrics.ForEntity("entity-uuid-123")
+
+	client.BeginBatch("latency", "")
+	client.Add(12, nil)
+	if client.batchQueue[0].Unit != "ms" {
+		t.Errorf("Expected default unit 'ms', got '%s'", client.batchQueue[0].Unit)
+	}
+
+	client.BeginMultiBatch()
+	client.AddMetric("latency", 12, "", nil)
+	client.AddMetric("size", 3, "KB", nil)
+	if client.batchQueue[0].Unit != "ms" {
+		t.Errorf("Expected default unit 'ms', got '%s'", client.batchQueue[0].Unit)
+	}
+	if client.batchQueue[1].Unit != "KB" {
+		t.Errorf("Expected explicit unit 'KB', got '%s'", client.batchQueue[1].Unit)
+	}
+}
+
+func TestMetricsUnitAliases(t *testing.T) {
+	var sent MetricEntry
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&sent)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	aliases := map[string]string{"milliseconds": "ms", "millisecond": "ms"}
+	metrics := NewMetrics("test_api_key",
+		WithMetricsUnitAliases(aliases),
+		WithMetricsDefaultUnit("millisecond"),
+	)
+	aliases["bytes"] = "B"
+	redirectToServer(metrics.http, server)
+	client := metrics.ForEntity("entity-uuid-123")
+
+	if err := client.Send(context.Background(), "latency", 12, "milliseconds", nil); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if sent.Unit != "ms" {
+		t.Errorf("Expected unit canonicalized to 'ms', got '%s'", sent.Unit)
+	}
+
+	client.BeginMultiBatch()
+	client.AddMetric("latency", 12, "", nil)
+	client.AddMetric("size", 3, "bytes", nil)
+	if client.batchQueue[0].Unit != "ms" {
+		t.Errorf("Expected default unit canonicalized to 'ms', got '%s'", client.batchQueue[0].Unit)
+	}
+	if client.batchQueue[1].Unit != "bytes" {
+		t.Errorf("Expected aliases to be copied at construction, got '%s'", client.batchQueue[1].Unit)
+	}
+}
+
+func TestBoundMetricsSendWithResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var entry MetricEntry
+		json.NewDecoder(r.Body).Decode(&entry)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{
+				"id":        "metric-uuid-1",
+				"name":      entry.Name,
+				"value":     entry.Value,
+				"unit":      entry.Unit,
+				"timestamp": "2024-05-01T12:00:00Z",
+			},
+			"status": "success",
+		})
+	}))
+	defer server.Close()
+
+	metrics := NewMetrics("test_api_key")
+	redirectToServer(metrics.http, server)
+	client := metrics.ForEntity("entity-uuid-123")
+
+	ack, err := client.SendWithResponse(context.Background(), "cpu.usage", 45.2, "percent", nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if ack.Data.ID != "metric-uuid-1" || ack.Data.Value != 45.2 {
+		t.Errorf("Expected acknowledged metric-uuid-1 with value 45.2, got %+v", ack.Data)
+	}
+	want := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	if !ack.Data.Timestamp.Equal(want) {
+		t.Errorf("Expected server timestamp %v, got %v", want, ack.Data.Timestamp)
+	}
+
+	client.BeginBatch("cpu.usage", "percent")
+	if _, err := client.SendWithResponse(context.Background(), "cpu.usage", 1, "percent", nil); err == nil {
+		t.Error("Expected SendWithResponse to fail in batch mode")
+	}
+}
+
+func TestBoundMetricsSendWithResponseUndecodableBody(t *testing.T) {
+	bodies := map[string]string{
+		"empty body":             "",
+		"non-RFC 3339 timestamp": `{"data":{"id":"metric-uuid-1","timestamp":"2024-05-01 12:00:00"},"status":"success"}`,
+	}
+	for name, body := range bodies {
+		t.Run(name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusCreated)
+				io.WriteString(w, body)
+			}))
+			defer server.Close()
+
+			metrics := NewMetrics("test_api_key")
+			redirectToServer(metrics.http, server)
+			client := metrics.ForEntity("entity-uuid-123")
+
+			ack, err := client.SendWithResponse(context.Background(), "cpu.usage", 45.2, "percent", nil)
+			if err != nil {
+				t.Fatalf("Expected an accepted metric not to fail, got %v", err)
+			}
+			if ack.Data.Name != "cpu.usage" || ack.Data.Value != 45.2 || !ack.Data.Timestamp.IsZero() {
+				t.Errorf("Expected the metric as sent with a zero timestamp, got %+v", ack.Data)
+			}
+			if client.LastError() != "" {
+				t.Errorf("Expected no last error, got %q", client.LastError())
+			}
+		})
+	}
+}
+
+func TestMetricsSendMerged(t *testing.T) {
+	var payloads []BatchMetricsPayload
+	fail := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var payload BatchMetricsPayload
+		json.NewDecoder(r.Body).Decode(&payload)
+		payloads = append(payloads, payload)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	metrics := NewMetrics("test_api_key", WithMetricsRetry(1, time.Millisecond, time.Millisecond))
+	redirectToServer(metrics.http, server)
+
+	httpMetrics := metrics.ForEntity("entity-uuid-123")
+	httpMetrics.BeginBatch("http.duration", "ms")
+	httpMetrics.Add(12, nil)
+	httpMetrics.Add(30, nil)
+
+	dbMetrics := metrics.ForEntity("entity-uuid-123")
+	dbMetrics.BeginMultiBatch()
+	dbMetrics.AddMetric("db.queries", 4, "count", nil)
+
+	idle := metrics.ForEntity("entity-uuid-123")
+
+	ctx := context.Background()
+	fail = true
+	if err := metrics.SendMerged(ctx, httpMetrics, dbMetrics, idle); err == nil {
+		t.Fatal("Expected error from failed send")
+	}
+	if httpMetrics.BatchSize() != 2 || dbMetrics.BatchSize() != 1 {
+		t.Errorf("Expected queues kept after failure, got %d and %d", httpMetrics.BatchSize(), dbMetrics.BatchSize())
+	}
+
+	fail = false
+	if err := metrics.SendMerged(ctx, httpMetrics, dbMetrics, idle, httpMetrics); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(payloads) != 1 {
+		t.Fatalf("Expected 1 request, got %d", len(payloads))
+	}
+	got := payloads[0]
+	if got.EntityID != "entity-uuid-123" || got.Name != "" || len(got.Metrics) != 3 {
+		t.Fatalf("Expected 3 merged metrics for entity-uuid-123, got %+v", got)
+	}
+	if got.Metrics[0].Name != "http.duration" || got.Metrics[2].Name != "db.queries" {
+		t.Errorf("Expected per-metric names, got %+v", got.Metrics)
+	}
+	if httpMetrics.BatchSize() != 0 || dbMetrics.BatchSize() != 0 {
+		t.Error("Expected queues cleared after a successful send")
+	}
+
+	other := metrics.ForEntity("entity-uuid-456")
+	other.BeginMultiBatch()
+	other.AddMetric("x", 1, "count", nil)
+	httpMetrics.Add(5, nil)
+	if err := metrics.SendMerged(ctx, httpMetrics, other); err == nil {
+		t.Error("Expected error when merging different entities")
+	}
+	if len(payloads) != 1 {
+		t.Errorf("Expected nothing sent for mismatched entities, got %d requests", len(payloads))
+	}
+}
+
+func TestMetricsSendMergedKeepsMetricsAddedDuringSend(t *testing.T) {
+	var client *BoundMetrics
+	var sizes []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload BatchMetricsPayload
+		json.NewDecoder(r.Body).Decode(&payload)
+		sizes = append(sizes, len(payload.Metrics))
+		if len(sizes) == 1 {
+			client.Add(3, nil)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	metrics := NewMetrics("test_api_key")
+	redirectToServer(metrics.http, server)
+	client = metrics.ForEntity("entity-uuid-123")
+	client.BeginBatch("http.duration", "ms")
+	client.Add(1, nil)
+	client.Add(2, nil)
+
+	ctx := context.Background()
+	if err := metrics.SendMerged(ctx, client); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if client.BatchSize() != 1 {
+		t.Fatalf("Expected the metric added during the send to stay queued, got %d", client.BatchSize())
+	}
+	if err := metrics.SendMerged(ctx, client); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(sizes) != 2 || sizes[0] != 2 || sizes[1] != 1 {
+		t.Errorf("Expected batches of 2 and 1, got %v", sizes)
+	}
+}
+
+func TestMetricsSuccessCodes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	metrics := NewMetrics("test_api_key", WithMetricsSuccessCodes(http.StatusAccepted))
+	redirectToServer(metrics.http, server)
+	client := metrics.ForEntity("entity-uuid-123")
+
+	if err := client.Send(ctx, "cpu.usage", 45, "percent", nil); err != nil {
+		t.Errorf("Expected 202 to be accepted, got %v", err)
+	}
+	client.BeginBatch("cpu.usage", "percent")
+	client.Add(46, nil)
+	if err := client.SendBatch(ctx); err != nil {
+		t.Errorf("Expected 202 batch to be accepted, got %v", err)
+	}
+}
+
+func TestMetricsMaxTagLength(t *testing.T) {
+	metrics := NewMetrics("test_api_key", WithMetricsMaxTagLength(12))
+	client := metrics.ForEntity("entity-uuid-123")
+
+	client.BeginMultiBatch()
+	client.AddMetric("latency", 1, "ms", map[string]interface{}{
+		"path": "/api/users/42/orders",
+	})
+	client.AddMetric("latency", 1, "ms", map[string]interface{}{
+		"m": "GET",
+	})
+
+	if got := client.batchQueue[0].Tags[0]; got != "path:/api..." {
+		t.Errorf("Expected truncated tag 'path:/api...', got '%s'", got)
+	}
+	if got := client.batchQueue[1].Tags[0]; got != "m:GET" {
+		t.Errorf("Expected short tag unchanged, got '%s'", got)
+	}
+}
+
+func TestMetricsDefaultTags(t *testing.T) {
+	defaults := map[string]interface{}{"host": "web-1", "region": "eu"}
+	metrics := NewMetrics("test_api_key", WithMetricsDefaultTags(defaults))
+	defaults["host"] = "changed"
+	client := metrics.ForEntity("entity-uuid-123")
+
+	client.BeginMultiBatch()
+	client.AddMetric("latency", 1, "ms", map[string]interface{}{"region": "us"})
+	client.AddMetric("latency", 1, "ms", nil)
+
+	first := strings.Join(client.batchQueue[0].Tags, ",")
+	if !strings.Contains(first, "host:web-1") || !strings.Contains(first, "region:us") || strings.Contains(first, "region:eu") {
+		t.Errorf("Expected defaults overridden by per-call tags, got %v", client.batchQueue[0].Tags)
+	}
+	if len(client.batchQueue[1].Tags) != 2 {
+		t.Errorf("Expected default tags on untagged metric, got %v", client.batchQueue[1].Tags)
+	}
+}
+
+func TestTruncateTagKeepsUTF8(t *testing.T) {
+	got := truncateTag("city:Zürich", 10)
+	if got != "city:Z..." {
+		t.Errorf("Expected 'city:Z...', got '%s'", got)
+	}
+}
+
+func TestMetricsConfig(t *testing.T) {
+	metrics := NewMetrics("short", WithMetricsDefaultUnit("ms"))
+
+	config := metrics.Config()
+	if config.APIKey != "*****" {
+		t.Errorf("Expected fully masked short key, got '%s'", config.APIKey)
+	}
+	if config.DefaultUnit != "ms" || config.RetryAttempts != 3 {
+		t.Errorf("Unexpected config: %+v", config)
+	}
+}
+
+func TestMetricsConfigIsDeepCopy(t *testing.T) {
+	metrics := NewMetrics("test_key",
+		WithMetricsDefaultTags(map[string]interface{}{"region": "eu"}),
+		WithMetricsUnitAliases(map[string]string{"millis": "ms"}),
+		WithMetricsSuccessCodes(200, 202),
+	)
+
+	config := metrics.Config()
+	config.DefaultTags["region"] = "us"
+	config.UnitAliases["secs"] = "s"
+	config.SuccessCodes[0] = 500
+
+	again := metrics.Config()
+	if again.DefaultTags["region"] != "eu" || len(again.UnitAliases) != 1 || again.SuccessCodes[0] != 200 {
+		t.Errorf("Expected the client's maps and slices unchanged, got %v, %v and %v",
+			again.DefaultTags, again.UnitAliases, again.SuccessCodes)
+	}
+	if got := metrics.ForEntity("entity-1").formatTags(nil); len(got) != 1 || got[0] != "region:eu" {
+		t.Errorf("Expected default tags unchanged on new clients, got %v", got)
+	}
+}
+
+func TestMetricsSetRetryConfig(t *testing.T) {
+	metrics := NewMetrics("test_key")
+	metrics.SetRetryConfig(RetryConfig{MaxAttempts: 7, BaseDelay: time.Second, MaxDelay: time.Minute})
+
+	cfg := metrics.Config()
+	if cfg.RetryAttempts != 7 || cfg.RetryBaseDelay != time.Second || cfg.RetryMaxDelay != time.Minute {
+		t.Errorf("Expected updated retry config, got %+v", cfg)
+	}
+}
+
+func TestGetOrCreateEntityResult(t *testing.T) {
+	exists := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "GET" && strings.Contains(r.URL.Path, "/entities/by-name/"):
+			if !exists {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write([]byte(`{"data":{"id":"entity-1","name":"svc"}}`))
+		case r.Method == "POST" && strings.HasSuffix(r.URL.Path, "/entities"):
+			exists = true
+			w.Write([]byte(`{"data":{"id":"entity-1","name":"svc"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	metrics := NewMetrics("test_key")
+	redirectToServer(metrics.http, server)
+
+	ctx := context.Background()
+	first, err := metrics.GetOrCreateEntityResult(ctx, CreateEntityOptions{Name: "svc"})
+	if err != nil || !first.Created || first.Entity.ID != "entity-1" {
+		t.Fatalf("Expected created entity, got %+v (%v)", first, err)
+	}
+
+	second, err := metrics.GetOrCreateEntityResult(ctx, CreateEntityOptions{Name: "svc"})
+	if err != nil || second.Created || second.Entity.ID != "entity-1" {
+		t.Errorf("Expected fetched entity, got %+v (%v)", second, err)
+	}
+}
+
+func TestMetricsDuplicateEntityNames(t *testing.T) {
+	created := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "GET" && strings.HasSuffix(r.URL.Path, "/entities/by-name/svc"):
+			w.Write([]byte(`{"data":[{"id":"entity-1","name":"svc"},{"id":"entity-2","name":"svc"}]}`))
+		case r.Method == "GET" && strings.HasSuffix(r.URL.Path, "/entities/by-name/one"):
+			w.Write([]byte(`{"data":{"id":"entity-3","name":"one"}}`))
+		case r.Method == "POST":
+			created = true
+			w.Write([]byte(`{"data":{"id":"entity-4","name":"svc"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	lenient := NewMetrics("test_key")
+	redirectToServer(lenient.http, server)
+
+	entity, err := lenient.GetEntityByName(ctx, "svc")
+	if err != nil || entity.ID != "entity-1" {
+		t.Errorf("Expected first match without strict mode, got %+v (%v)", entity, err)
+	}
+
+	entities, err := lenient.FindEntities(ctx, "svc")
+	if err != nil || len(entities) != 2 || entities[1].ID != "entity-2" {
+		t.Errorf("Expected both matches, got %+v (%v)", entities, err)
+	}
+	entities, err = lenient.FindEntities(ctx, "one")
+	if err != nil || len(entities) != 1 || entities[0].ID != "entity-3" {
+		t.Errorf("Expected single match, got %+v (%v)", entities, err)
+	}
+	entities, err = lenient.FindEntities(ctx, "missing")
+	if err != nil || entities == nil || len(entities) != 0 {
+		t.Errorf("Expected empty result for unknown name, got %+v (%v)", entities, err)
+	}
+
+	strict := NewMetrics("test_key", WithMetricsStrictEntityNames(true))
+	redirectToServer(strict.http, server)
+
+	if _, err := strict.GetEntityByName(ctx, "svc"); !errors.Is(err, ErrAmbiguousEntityName) {
+		t.Errorf("Expected ErrAmbiguousEntityName, got %v", err)
+	}
+	if _, err := strict.GetOrCreateEntity(ctx, CreateEntityOptions{Name: "svc"}); !errors.Is(err, ErrAmbiguousEntityName) {
+		t.Errorf("Expected GetOrCreateEntity to fail on ambiguity, got %v", err)
+	}
+	if created {
+		t.Error("Expected no entity to be created for an ambiguous name")
+	}
+	if entity, err := strict.GetEntityByName(ctx, "one"); err != nil || entity.ID != "entity-3" {
+		t.Errorf("Expected unique name to resolve in strict mode, got %+v (%v)", entity, err)
+	}
+}
+
+func TestMetricsBoundForName(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" && strings.HasSuffix(r.URL.Path, "/entities/by-name/svc") {
+			w.Write([]byte(`{"data":{"id":"entity-1","name":"svc"}}`))
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	metrics := NewMetrics("test_key", WithMetricsRetry(1, time.Millisecond, time.Millisecond))
+	redirectToServer(metrics.http, server)
+
+	client, err := metrics.BoundForName(context.Background(), "svc")
+	if err != nil || client.EntityID() != "entity-1" {
+		t.Fatalf("Expected client bound to entity-1, got %v (%v)", client, err)
+	}
+
+	if _, err := metrics.BoundForName(context.Background(), "missing"); err == nil {
+		t.Error("Expected error when the entity can't be resolved")
+	}
+}
+
+func TestBoundMetricsShardedBatch(t *testing.T) {
+	var payload BatchMetricsPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&payload)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	metrics := NewMetrics("test_key", WithMetricsBatchShards(4))
+	redirectToServer(metrics.http, server)
+	client := metrics.ForEntity("entity-1")
+
+	if err := client.AddMetric("queue.depth", 1, "count", nil); err == nil {
+		t.Error("Expected error when not in multi-batch mode")
+	}
+
+	client.BeginMultiBatch()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				client.AddMetric("queue.depth", float64(j), "count", nil)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if client.BatchSize() != 400 {
+		t.Fatalf("Expected 400 queued metrics, got %d", client.BatchSize())
+	}
+	if err := client.SendBatch(context.Background()); err != nil {
+		t.Fatalf("SendBatch failed: %v", err)
+	}
+	if len(payload.Metrics) != 400 || payload.Metrics[0].Name != "queue.depth" {
+		t.Errorf("Expected 400 merged metrics, got %d", len(payload.Metrics))
+	}
+	if client.BatchSize() != 0 {
+		t.Errorf("Expected shards cleared after send, got %d", client.BatchSize())
+	}
+}
+
+func TestMetricsNoTagsSkipsDefaults(t *testing.T) {
+	metrics := NewMetrics("test_api_key", WithMetricsDefaultTags(map[string]interface{}{"host": "web-1"}))
+	client := metrics.ForEntity("entity-uuid-123")
+
+	client.BeginMultiBatch()
+	client.AddMetric("latency", 1, "ms", NoTags())
+
+	if tags := client.batchQueue[0].Tags; tags != nil {
+		t.Errorf("Expected no tags, got %v", tags)
+	}
+}
+
+func TestBoundMetricsAddWithAutoFlush(t *testing.T) {
+	var sizes []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload BatchMetricsPayload
+		json.NewDecoder(r.Body).Decode(&payload)
+		sizes = append(sizes, len(payload.Metrics))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	metrics := NewMetrics("test_key", WithMetricsAutoFlushSize(3))
+	redirectToServer(metrics.http, server)
+	client := metrics.ForEntity("entity-1")
+
+	ctx := context.Background()
+	if err := client.AddWithAutoFlush(ctx, 1, nil); err == nil {
+		t.Error("Expected error when not in batch mode")
+	}
+
+	client.BeginBatch("temperature", "celsius")
+	for i := 0; i < 7; i++ {
+		if err := client.AddWithAutoFlush(ctx, float64(i), nil); err != nil {
+			t.Fatalf("AddWithAutoFlush failed: %v", err)
+		}
+	}
+
+	if len(sizes) != 2 || sizes[0] != 3 || sizes[1] != 3 {
+		t.Errorf("Expected two flushes of 3 metrics, got %v", sizes)
+	}
+	if client.BatchSize() != 1 {
+		t.Errorf("Expected 1 metric left in the queue, got %d", client.BatchSize())
+	}
+}
+
+func TestBoundMetricsAddWithAutoFlushConcurrent(t *testing.T) {
+	for _, shards := range []int{1, 4} {
+		var mu sync.Mutex
+		seen := make(map[float64]int)
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var payload BatchMetricsPayload
+			json.NewDecoder(r.Body).Decode(&payload)
+			mu.Lock()
+			defer mu.Unlock()
+			requests++
+			if requests%3 == 0 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			for _, m := range payload.Metrics {
+				seen[m.Value]++
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+
+		metrics := NewMetrics("test_key",
+			WithMetricsAutoFlushSize(10),
+			WithMetricsBatchShards(shards),
+			WithMetricsRetry(1, time.Millisecond, time.Millisecond))
+		redirectToServer(metrics.http, server)
+		client := metrics.ForEntity("entity-1")
+		client.BeginBatch("temperature", "celsius")
+
+		const workers, perWorker = 8, 100
+		ctx := context.Background()
+		var wg sync.WaitGroup
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				for i := 0; i < perWorker; i++ {
+					client.AddWithAutoFlush(ctx, float64(w*perWorker+i), nil)
+				}
+			}(w)
+		}
+		wg.Wait()
+		for attempt := 0; client.BatchSize() > 0 && attempt < 10; attempt++ {
+			client.SendBatch(ctx)
+		}
+		server.Close()
+
+		if len(seen) != workers*perWorker {
+			t.Errorf("shards=%d: Expected %d distinct values sent, got %d", shards, workers*perWorker, len(seen))
+		}
+		for value, n := range seen {
+			if n != 1 {
+				t.Errorf("shards=%d: Expected value %v sent once, got %d", shards, value, n)
+			}
+		}
+	}
+}
+
+func TestBoundMetricsAddWithAutoFlushDisabled(t *testing.T) {
+	client := NewMetrics("test_key").ForEntity("entity-1")
+	client.BeginBatch("temperature", "celsius")
+	for i := 0; i < 5; i++ {
+		client.AddWithAutoFlush(context.Background(), float64(i), nil)
+	}
+	if client.BatchSize() != 5 {
+		t.Errorf("Expected values to stay queued without a flush size, got %d", client.BatchSize())
+	}
+}
+
+// BenchmarkAddParallel compares an unsharded queue with a sharded one
+// under contention; run with -cpu to vary the number of goroutines.
+func BenchmarkAddParallel(b *testing.B) {
+	for _, shards := range []int{1, 8} {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			metrics := NewMetrics("test_key", WithMetricsBatchShards(shards))
+			client := metrics.ForEntity("entity-1")
+			client.BeginBatch("latency", "ms")
+
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					client.Add(1, nil)
+				}
+			})
+		})
+	}
+}
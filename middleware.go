@@ -1,15 +1,37 @@
 package logdot
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"math/rand"
+	"mime"
 	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 	"unicode/utf8"
 )
 
-const maxMessageBytes = 16000
+const (
+	maxMessageBytes     = 16000
+	defaultMaxBodyBytes = 4096
+)
+
+// sensitiveKeys lists JSON keys whose values are replaced with
+// "[REDACTED]" before a captured request body is logged.
+var sensitiveKeys = []string{
+	"password", "passwd", "secret", "token", "access_token", "refresh_token",
+	"api_key", "apikey", "authorization", "credit_card", "card_number", "cvv",
+}
 
 // MiddlewareConfig configures the HTTP auto-instrumentation middleware.
 type MiddlewareConfig struct {
@@ -24,6 +46,10 @@ type MiddlewareConfig struct {
 	// Logger.Hostname() when empty.
 	EntityName string
 
+	// EntityDescription is used when the middleware creates the entity.
+	// Defaults to "HTTP service: <EntityName>" when empty.
+	EntityDescription string
+
 	// LogRequests enables per-request log entries.
 	// Zero value (false) means the caller must explicitly set it to true.
 	// Use DefaultMiddlewareConfig() for sane defaults.
@@ -34,6 +60,99 @@ type MiddlewareConfig struct {
 
 	// IgnorePaths lists URL paths that should not be logged or metered.
 	IgnorePaths []string
+
+	// PathLevelOverride sets the log level for successful (< 400)
+	// requests to the given URL paths, e.g. LevelDebug for busy health
+	// checks. 4xx and 5xx responses keep their warn and error levels.
+	PathLevelOverride map[string]LogLevel
+
+	// CaptureBodyOnError adds a request_body tag to 4xx/5xx request logs
+	// when the request has a JSON content type. Sensitive keys are redacted.
+	CaptureBodyOnError bool
+
+	// MaxBodyBytes caps how much of the request body is captured.
+	// Defaults to 4096 when zero.
+	MaxBodyBytes int
+
+	// CountRequestBytes counts the bytes the handler reads from the
+	// request body, so request_bytes is also reported for requests
+	// without a Content-Length (e.g. chunked uploads). Without it,
+	// request_bytes comes from Content-Length only.
+	CountRequestBytes bool
+
+	// CaptureQuery adds the raw query string as an http_query tag, with
+	// the values of sensitive parameters redacted.
+	CaptureQuery bool
+
+	// RedactQueryParams lists query parameter names (case-insensitive)
+	// whose values are redacted in http_query. Defaults to the same
+	// sensitive keys used for request bodies (token, api_key, ...).
+	RedactQueryParams []string
+
+	// MetricStatusFilter, when set, decides per response status whether
+	// the duration metric is sent. Requests are still logged either way.
+	MetricStatusFilter func(status int) bool
+
+	// LogRequestCount also sends an http.request.count metric (value 1)
+	// per request, tagged like the duration metric. Counts and the
+	// DurationBuckets points are sent by a background goroutine, not on
+	// the request path; when more than 1024 are waiting, further points
+	// are dropped.
+	LogRequestCount bool
+
+	// DurationBuckets, when set, are upper bounds in milliseconds for a
+	// latency histogram. Each request also sends a count of 1 to
+	// http.request.duration.bucket, tagged le with the smallest bound
+	// that is >= the duration ("+Inf" when it exceeds them all).
+	DurationBuckets []float64
+
+	// IgnoreCORSPreflight skips CORS preflight requests (OPTIONS with an
+	// Access-Control-Request-Method header). Other OPTIONS requests are
+	// still logged.
+	IgnoreCORSPreflight bool
+
+	// SampleRate is the fraction of requests (0 < rate < 1) that are
+	// logged and metered; the rest are skipped entirely. Zero or values
+	// >= 1 keep every request. The decision is stored on the request
+	// context (see WithSampled), so logs made with it follow it; a
+	// decision already on the context takes precedence.
+	SampleRate float64
+
+	// RepanicAfterLogging re-raises handler panics after the request has
+	// been logged, so outer recovery middleware can handle them. By
+	// default (false) panics are recovered and answered with a 500.
+	RepanicAfterLogging bool
+
+	// HandlerNameFunc, when set, names the logical handler of a request,
+	// e.g. a route pattern from the router's context. It is called after
+	// the handler has run; a non-empty result is added as a handler tag to
+	// the request log and metrics.
+	HandlerNameFunc func(r *http.Request) string
+
+	// EntityIDContextKey, when set, is looked up on the request context
+	// for a metrics entity ID (e.g. a tenant's entity resolved by an outer
+	// middleware). A non-empty string value sends the request's metrics
+	// to that entity instead of the one named by EntityName.
+	EntityIDContextKey interface{}
+
+	// FlushEachRequest sends the logger's batch in the background after
+	// each logged request, for loggers in batch mode. Without it, batched
+	// request logs stay queued until something else flushes the logger.
+	FlushEachRequest bool
+
+	// CaptureTrace tags request logs with the request's trace_id and
+	// span_id, taken from TraceIDs when set, else trace_id alone from the
+	// W3C traceparent header. Requests without a trace are not tagged.
+	CaptureTrace bool
+
+	// TraceIDs returns the current span's IDs for CaptureTrace, e.g.
+	// logdototel.SpanIDs for OpenTelemetry instrumentation
+	TraceIDs TraceIDsFunc
+
+	// OnInternalError, when set, is called when the middleware's own
+	// logging or metric sending fails or panics. Such failures never
+	// affect the response; without this callback they are dropped.
+	OnInternalError func(err error)
 }
 
 // DefaultMiddlewareConfig returns a MiddlewareConfig with sensible defaults.
@@ -60,7 +179,154 @@ func DefaultMiddlewareConfig() MiddlewareConfig {
 //
 //	handler := logdot.Middleware(cfg)(mux)
 //	http.ListenAndServe(":8080", handler)
+//
+// Each request gets an ID, taken from the X-Request-ID header or generated,
+// which tags its request log. Handlers can log with the same request_id,
+// http_method and http_path tags through logdot.FromContext(r.Context()).
 func Middleware(config MiddlewareConfig) func(http.Handler) http.Handler {
+	return newMiddlewareState(config).wrap
+}
+
+// wrap returns next instrumented according to mw.config
+func (mw *middlewareState) wrap(next http.Handler) http.Handler {
+	config := mw.config
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Never break request handling
+		defer func() {
+			if rec := recover(); rec != nil {
+				if config.RepanicAfterLogging {
+					panic(rec)
+				}
+				// If the inner handler panicked, return 500
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			}
+		}()
+
+		// Skip ignored paths and preflights. Skipped requests get the
+		// base logger rather than a request-scoped one.
+		if mw.excluded(r) {
+			next.ServeHTTP(w, mw.withBaseLogger(r))
+			return
+		}
+
+		// Skip sampled-out requests, passing the decision on so the
+		// handler's own logs are dropped too
+		var sampled bool
+		r, sampled = mw.sample(r)
+		if !sampled {
+			next.ServeHTTP(w, mw.withBaseLogger(r))
+			return
+		}
+
+		if config.Logger != nil {
+			r = withRequestLogger(r, config.Logger)
+		}
+
+		var body *cappedBuffer
+		if config.CaptureBodyOnError && config.LogRequests {
+			body = captureJSONBody(r, config.MaxBodyBytes)
+		}
+
+		var counter *countingReader
+		if config.CountRequestBytes && config.LogRequests && r.Body != nil && r.Body != http.NoBody {
+			counter = &countingReader{ReadCloser: r.Body}
+			r.Body = counter
+		}
+
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		// Log panicking requests as 500s before passing the panic on
+		defer func() {
+			if p := recover(); p != nil {
+				if config.LogRequests && config.Logger != nil {
+					durationMs := float64(time.Since(start).Microseconds()) / 1000.0
+					mw.logRequest(r, http.StatusInternalServerError, durationMs, body, counter, p)
+				}
+				panic(p)
+			}
+		}()
+
+		next.ServeHTTP(rec, r)
+
+		durationMs := float64(time.Since(start).Microseconds()) / 1000.0
+
+		if config.LogRequests && config.Logger != nil {
+			mw.logRequest(r, rec.status, durationMs, body, counter, nil)
+		}
+
+		if config.LogMetrics && config.Metrics != nil {
+			mw.sendMetric(r, rec.status, durationMs)
+		}
+	})
+}
+
+// requestIDHeader carries an incoming request ID. One is generated when
+// the header is missing.
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength caps request IDs taken from the header
+const maxRequestIDLength = 128
+
+type requestIDContextKey struct{}
+
+// withRequestLogger returns r with a request ID and a logger derived from
+// logger, tagged with the ID, method and path, attached to its context
+func withRequestLogger(r *http.Request, logger *Logger) *http.Request {
+	id := r.Header.Get(requestIDHeader)
+	if len(id) > maxRequestIDLength {
+		id = id[:maxRequestIDLength]
+	}
+	if id == "" {
+		id = newUUID()
+	}
+
+	reqLogger := logger.WithContext(map[string]interface{}{
+		"request_id":  id,
+		"http_method": r.Method,
+		"http_path":   r.URL.Path,
+	})
+	ctx := context.WithValue(r.Context(), requestIDContextKey{}, id)
+	return r.WithContext(NewContext(ctx, reqLogger))
+}
+
+// withBaseLogger returns r with the configured logger attached to its
+// context, so FromContext is usable in handlers of requests that are not
+// logged, without the cost of a request-scoped logger and ID
+func (mw *middlewareState) withBaseLogger(r *http.Request) *http.Request {
+	if mw.config.Logger == nil {
+		return r
+	}
+	return r.WithContext(NewContext(r.Context(), mw.config.Logger))
+}
+
+// nilLoggerWarning makes sure the missing-Logger warning is printed once
+// per process
+var nilLoggerWarning sync.Once
+
+// warningOutput receives configuration warnings
+var warningOutput io.Writer = os.Stderr
+
+// Validate reports configuration mistakes that would make the middleware
+// silently do nothing, such as a missing Logger
+func (c MiddlewareConfig) Validate() error {
+	if c.LogRequests && c.Logger == nil {
+		return fmt.Errorf("middleware config: LogRequests is set but Logger is nil")
+	}
+	if !c.LogRequests && !(c.LogMetrics && c.Metrics != nil) {
+		return fmt.Errorf("middleware config: neither request logging nor metrics are enabled")
+	}
+	return nil
+}
+
+func newMiddlewareState(config MiddlewareConfig) *middlewareState {
+	if config.LogRequests && config.Logger == nil {
+		nilLoggerWarning.Do(func() {
+			fmt.Fprintln(warningOutput, "[LogDot] Middleware created without a Logger; requests will not be logged")
+		})
+	}
+
 	ignorePaths := make(map[string]struct{}, len(config.IgnorePaths))
 	for _, p := range config.IgnorePaths {
 		ignorePaths[p] = struct{}{}
@@ -71,43 +337,66 @@ func Middleware(config MiddlewareConfig) func(http.Handler) http.Handler {
 		entityName = config.Logger.Hostname()
 	}
 
+	if config.MaxBodyBytes <= 0 {
+		config.MaxBodyBytes = defaultMaxBodyBytes
+	}
+	if config.RedactQueryParams == nil {
+		config.RedactQueryParams = sensitiveKeys
+	}
+	if len(config.DurationBuckets) > 0 {
+		buckets := append([]float64(nil), config.DurationBuckets...)
+		sort.Float64s(buckets)
+		config.DurationBuckets = buckets
+	}
+
 	mw := &middlewareState{
 		config:      config,
 		ignorePaths: ignorePaths,
 		entityName:  entityName,
+		entityReady: make(chan struct{}),
 	}
+	if config.LogRequestCount || len(config.DurationBuckets) > 0 {
+		mw.pointMetrics = make(chan pointMetric, pointMetricQueueSize)
+	}
+	if config.LogMetrics && config.Metrics != nil {
+		mw.ensureEntity()
+	}
+	return mw
+}
 
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Never break request handling
-			defer func() {
-				if rec := recover(); rec != nil {
-					// If the inner handler panicked, return 500
-					http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-				}
-			}()
-
-			// Skip ignored paths
-			if _, skip := mw.ignorePaths[r.URL.Path]; skip {
-				next.ServeHTTP(w, r)
-				return
-			}
+// RequestObserver records completed requests the same way Middleware does.
+// It is meant for adapters of frameworks that don't use http.Handler (see
+// the logdotfiber package); net/http users should use Middleware.
+type RequestObserver struct {
+	mw *middlewareState
+}
 
-			start := time.Now()
-			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+// NewRequestObserver creates a RequestObserver from a MiddlewareConfig.
+// Options that wrap the request or response (such as CaptureBodyOnError)
+// are not applied by the observer.
+func NewRequestObserver(config MiddlewareConfig) *RequestObserver {
+	return &RequestObserver{mw: newMiddlewareState(config)}
+}
 
-			next.ServeHTTP(rec, r)
+// Ignored reports whether the request's path is listed in IgnorePaths,
+// it is a CORS preflight and IgnoreCORSPreflight is set, or it was
+// sampled out by its context (see WithSampled) or SampleRate. Call it
+// once per request.
+func (o *RequestObserver) Ignored(r *http.Request) bool {
+	return o.mw.ignored(r)
+}
 
-			durationMs := float64(time.Since(start).Microseconds()) / 1000.0
+// Observe logs the completed request and sends its duration metric
+// according to the config. It never panics.
+func (o *RequestObserver) Observe(r *http.Request, status int, duration time.Duration) {
+	durationMs := float64(duration.Microseconds()) / 1000.0
 
-			if config.LogRequests && config.Logger != nil {
-				mw.logRequest(r, rec.status, durationMs)
-			}
+	if o.mw.config.LogRequests && o.mw.config.Logger != nil {
+		o.mw.logRequest(r, status, durationMs, nil, nil, nil)
+	}
 
-			if config.LogMetrics && config.Metrics != nil {
-				mw.sendMetric(r, rec.status, durationMs)
-			}
-		})
+	if o.mw.config.LogMetrics && o.mw.config.Metrics != nil {
+		o.mw.sendMetric(r, status, durationMs)
 	}
 }
 
@@ -117,13 +406,86 @@ type middlewareState struct {
 	ignorePaths map[string]struct{}
 	entityName  string
 
-	entityMu     sync.Mutex
-	entityDone   bool
-	boundMetrics *BoundMetrics
+	// boundMetrics is the resolved default entity's client, nil until
+	// resolveEntity succeeds, which also closes entityReady.
+	// entityResolving guards the single resolution in flight;
+	// entityRetryAt and entityBackoff (under entityMu) space out attempts
+	// after a failure.
+	boundMetrics    atomic.Pointer[BoundMetrics]
+	entityReady     chan struct{}
+	entityResolving atomic.Bool
+	entityMu        sync.Mutex
+	entityRetryAt   time.Time
+	entityBackoff   time.Duration
+
+	// flushing is set while a FlushEachRequest flush runs; flushPending
+	// asks it for another pass
+	flushing     atomic.Bool
+	flushPending atomic.Bool
+
+	// pointMetrics feeds the goroutine, started on first use, that sends
+	// the count and bucket points off the request path; pendingPoints
+	// counts points queued but not yet sent
+	pointMetrics  chan pointMetric
+	pointsStart   sync.Once
+	pendingPoints sync.WaitGroup
 }
 
-func (mw *middlewareState) logRequest(r *http.Request, status int, durationMs float64) {
-	defer func() { recover() }() //nolint:errcheck // never crash
+// pointMetricQueueSize bounds the count and bucket points waiting to be
+// sent. When the queue is full, further points are dropped.
+const pointMetricQueueSize = 1024
+
+// pointMetric is a count of one for the request metric name
+type pointMetric struct {
+	bound *BoundMetrics
+	name  string
+	tags  map[string]interface{}
+}
+
+// ignored reports whether the request should be neither logged nor
+// metered: it is excluded or sampled out
+func (mw *middlewareState) ignored(r *http.Request) bool {
+	return mw.excluded(r) || !mw.sampled(r)
+}
+
+// excluded reports whether the request's path is ignored or it is a
+// skipped preflight
+func (mw *middlewareState) excluded(r *http.Request) bool {
+	if _, skip := mw.ignorePaths[r.URL.Path]; skip {
+		return true
+	}
+	return mw.config.IgnoreCORSPreflight && isCORSPreflight(r)
+}
+
+// sampled reports whether the request is sampled in. A decision stored on
+// its context with WithSampled wins over SampleRate.
+func (mw *middlewareState) sampled(r *http.Request) bool {
+	if sampled, ok := sampledFromContext(r.Context()); ok {
+		return sampled
+	}
+	rate := mw.config.SampleRate
+	return rate <= 0 || rate >= 1 || rand.Float64() < rate
+}
+
+// sample is like sampled, but also records a SampleRate decision on the
+// returned request's context
+func (mw *middlewareState) sample(r *http.Request) (*http.Request, bool) {
+	if sampled, ok := sampledFromContext(r.Context()); ok {
+		return r, sampled
+	}
+	rate := mw.config.SampleRate
+	if rate <= 0 || rate >= 1 {
+		return r, true
+	}
+	sampled := rand.Float64() < rate
+	return r.WithContext(WithSampled(r.Context(), sampled)), sampled
+}
+
+// logRequest logs a completed request. counter is nil unless
+// CountRequestBytes is set; panicValue is the recovered value when the
+// handler panicked, otherwise nil.
+func (mw *middlewareState) logRequest(r *http.Request, status int, durationMs float64, body *cappedBuffer, counter *countingReader, panicValue interface{}) {
+	defer mw.recoverInternal("log request")
 
 	method := r.Method
 	path := r.URL.Path
@@ -137,62 +499,267 @@ func (mw *middlewareState) logRequest(r *http.Request, status int, durationMs fl
 		"source":      "http_middleware",
 	}
 
+	if id, _ := r.Context().Value(requestIDContextKey{}).(string); id != "" {
+		tags["request_id"] = id
+	}
+
+	if name := mw.handlerName(r); name != "" {
+		tags["handler"] = name
+	}
+
+	if n := requestBytes(r, counter); n > 0 {
+		tags["request_bytes"] = n
+	}
+
+	if mw.config.CaptureTrace {
+		for k, v := range traceTags(r, mw.config.TraceIDs) {
+			tags[k] = v
+		}
+	}
+
+	if mw.config.CaptureQuery && r.URL.RawQuery != "" {
+		tags["http_query"] = redactQuery(r.URL.RawQuery, mw.config.RedactQueryParams)
+	}
+
+	if body != nil && status >= 400 && body.buf.Len() > 0 {
+		tags["request_body"] = redactBody(body)
+	}
+
+	if panicValue != nil {
+		tags["panic"] = truncateMessage(fmt.Sprint(panicValue))
+	}
+
 	level := severityFromStatus(status)
+	if override, ok := mw.config.PathLevelOverride[path]; ok && status < 400 {
+		level = override
+	}
 
 	// Use background context — logging should not be tied to client's request ctx
-	ctx := context.Background()
-	switch level {
-	case LevelError:
-		mw.config.Logger.Error(ctx, message, tags)
-	case LevelWarn:
-		mw.config.Logger.Warn(ctx, message, tags)
-	default:
-		mw.config.Logger.Info(ctx, message, tags)
+	if err := mw.config.Logger.Log(context.Background(), level, message, tags); err != nil {
+		mw.reportInternalError(fmt.Errorf("middleware: log request: %w", err))
+	}
+
+	if mw.config.FlushEachRequest {
+		mw.flushBatch(context.WithoutCancel(r.Context()))
+	}
+}
+
+// flushBatch sends the logger's queued entries in the background. At most
+// one flush runs at a time; requests logged while it runs make it go
+// around once more instead of starting another.
+func (mw *middlewareState) flushBatch(ctx context.Context) {
+	mw.flushPending.Store(true)
+	if !mw.flushing.CompareAndSwap(false, true) {
+		return
 	}
+
+	go func() {
+		defer mw.recoverInternal("flush batch")
+		for {
+			mw.flushPending.Store(false)
+			if err := mw.config.Logger.SendBatch(ctx); err != nil {
+				mw.reportInternalError(fmt.Errorf("middleware: flush batch: %w", err))
+			}
+			mw.flushing.Store(false)
+			if !mw.flushPending.Load() || !mw.flushing.CompareAndSwap(false, true) {
+				return
+			}
+		}
+	}()
 }
 
 func (mw *middlewareState) sendMetric(r *http.Request, status int, durationMs float64) {
-	defer func() { recover() }() //nolint:errcheck // never crash
+	defer mw.recoverInternal("send metric")
 
-	mw.ensureEntity()
+	if mw.config.MetricStatusFilter != nil && !mw.config.MetricStatusFilter(status) {
+		return
+	}
 
-	if mw.boundMetrics == nil {
+	bound := mw.metricsFor(r)
+	if bound == nil {
 		return
 	}
 
-	mw.boundMetrics.Send(
+	tags := map[string]interface{}{
+		"method": r.Method,
+		"path":   r.URL.Path,
+		"status": fmt.Sprintf("%d", status),
+	}
+	if name := mw.handlerName(r); name != "" {
+		tags["handler"] = name
+	}
+
+	mw.reportMetricError("http.request.duration", bound.Send(
 		context.Background(),
 		"http.request.duration",
 		round2(durationMs),
 		"ms",
-		map[string]interface{}{
-			"method": r.Method,
-			"path":   r.URL.Path,
-			"status": fmt.Sprintf("%d", status),
-		},
-	)
+		tags,
+	))
+
+	if mw.config.LogRequestCount {
+		mw.enqueuePoint(pointMetric{bound: bound, name: "http.request.count", tags: tags})
+	}
+
+	if len(mw.config.DurationBuckets) > 0 {
+		bucketTags := copyTags(tags)
+		bucketTags["le"] = bucketLabel(mw.config.DurationBuckets, durationMs)
+		mw.enqueuePoint(pointMetric{bound: bound, name: "http.request.duration.bucket", tags: bucketTags})
+	}
+}
+
+// enqueuePoint hands a count or bucket point to the sending goroutine
+// without blocking the request. The point is dropped when the queue is
+// full.
+func (mw *middlewareState) enqueuePoint(p pointMetric) {
+	mw.pointsStart.Do(func() { go mw.sendPoints() })
+	mw.pendingPoints.Add(1)
+	select {
+	case mw.pointMetrics <- p:
+	default:
+		mw.pendingPoints.Done()
+		mw.reportMetricError(p.name, fmt.Errorf("queue full, point dropped"))
+	}
+}
+
+// sendPoints sends queued points one at a time for the lifetime of the
+// middleware
+func (mw *middlewareState) sendPoints() {
+	for p := range mw.pointMetrics {
+		mw.sendPoint(p)
+		mw.pendingPoints.Done()
+	}
+}
+
+func (mw *middlewareState) sendPoint(p pointMetric) {
+	defer mw.recoverInternal("send metric")
+	mw.reportMetricError(p.name, p.bound.Send(context.Background(), p.name, 1, "count", p.tags))
+}
+
+// metricsFor returns the client for the request's metrics: the entity
+// from EntityIDContextKey when present, else the default entity. The
+// default is resolved in the background, so it returns nil (and the
+// request's metrics are skipped) until resolution has succeeded.
+func (mw *middlewareState) metricsFor(r *http.Request) *BoundMetrics {
+	if mw.config.EntityIDContextKey != nil {
+		if id, ok := r.Context().Value(mw.config.EntityIDContextKey).(string); ok && id != "" {
+			return mw.config.Metrics.ForEntity(id)
+		}
+	}
+	if bound := mw.boundMetrics.Load(); bound != nil {
+		return bound
+	}
+	mw.ensureEntity()
+	return nil
 }
 
+// handlerName returns the HandlerNameFunc result, or "" when unset
+func (mw *middlewareState) handlerName(r *http.Request) string {
+	if mw.config.HandlerNameFunc == nil {
+		return ""
+	}
+	return mw.config.HandlerNameFunc(r)
+}
+
+// reportMetricError reports a failed metric send, if err is non-nil
+func (mw *middlewareState) reportMetricError(name string, err error) {
+	if err != nil {
+		mw.reportInternalError(fmt.Errorf("middleware: send metric %s: %w", name, err))
+	}
+}
+
+// recoverInternal recovers a panic in the middleware's own instrumentation
+// and reports it. It must be deferred directly.
+func (mw *middlewareState) recoverInternal(op string) {
+	if p := recover(); p != nil {
+		mw.reportInternalError(fmt.Errorf("middleware: %s panicked: %v", op, p))
+	}
+}
+
+// reportInternalError passes err to OnInternalError, if set. A panicking
+// callback is ignored so it can't crash the request.
+func (mw *middlewareState) reportInternalError(err error) {
+	if mw.config.OnInternalError == nil {
+		return
+	}
+	defer func() { recover() }() //nolint:errcheck // never crash
+	mw.config.OnInternalError(err)
+}
+
+// bucketLabel returns the le label of the first (sorted) bucket that
+// durationMs fits in, or "+Inf".
+func bucketLabel(buckets []float64, durationMs float64) string {
+	for _, b := range buckets {
+		if durationMs <= b {
+			return strconv.FormatFloat(b, 'f', -1, 64)
+		}
+	}
+	return "+Inf"
+}
+
+// Backoff between failed entity resolutions, doubling up to the cap
+const (
+	entityRetryBaseDelay = time.Second
+	entityRetryMaxDelay  = time.Minute
+)
+
+// ensureEntity starts resolving the default entity in the background
+// unless a resolution is already running or a failed one is backing off.
+// Requests never wait on it.
 func (mw *middlewareState) ensureEntity() {
 	mw.entityMu.Lock()
-	defer mw.entityMu.Unlock()
+	wait := time.Now().Before(mw.entityRetryAt)
+	mw.entityMu.Unlock()
+	if wait || !mw.entityResolving.CompareAndSwap(false, true) {
+		return
+	}
 
-	if mw.entityDone {
+	go func() {
+		defer mw.entityResolving.Store(false)
+		defer mw.recoverInternal("resolve metrics entity")
+		mw.resolveEntity()
+	}()
+}
+
+// resolveEntity gets or creates the default entity. On failure the next
+// attempt is delayed with exponential backoff.
+func (mw *middlewareState) resolveEntity() {
+	if mw.boundMetrics.Load() != nil {
 		return
 	}
 
-	entity, err := mw.config.Metrics.GetOrCreateEntity(
+	description := mw.config.EntityDescription
+	if description == "" {
+		description = fmt.Sprintf("HTTP service: %s", mw.entityName)
+	}
+
+	result, err := mw.config.Metrics.GetOrCreateEntityResult(
 		context.Background(),
 		CreateEntityOptions{
 			Name:        mw.entityName,
-			Description: fmt.Sprintf("HTTP service: %s", mw.entityName),
+			Description: description,
 		},
 	)
-	if err == nil && entity != nil {
-		mw.boundMetrics = mw.config.Metrics.ForEntity(entity.ID)
-		mw.entityDone = true
+	if err != nil {
+		mw.reportInternalError(fmt.Errorf("middleware: resolve metrics entity %q: %w", mw.entityName, err))
+	}
+	if err != nil || result.Entity == nil {
+		mw.entityMu.Lock()
+		mw.entityBackoff = min(max(2*mw.entityBackoff, entityRetryBaseDelay), entityRetryMaxDelay)
+		mw.entityRetryAt = time.Now().Add(mw.entityBackoff)
+		mw.entityMu.Unlock()
+		return
+	}
+
+	mw.boundMetrics.Store(mw.config.Metrics.ForEntity(result.Entity.ID))
+	close(mw.entityReady)
+
+	if result.Created && mw.config.Logger != nil {
+		mw.config.Logger.Info(context.Background(), "Created metrics entity "+mw.entityName, map[string]interface{}{
+			"entity_id": result.Entity.ID,
+			"source":    "http_middleware",
+		})
 	}
-	// On failure, entityDone stays false so next request retries
 }
 
 // statusRecorder wraps http.ResponseWriter to capture the status code.
@@ -218,8 +785,161 @@ func (r *statusRecorder) Write(b []byte) (int, error) {
 	return r.ResponseWriter.Write(b)
 }
 
+// cappedBuffer keeps the first max bytes written to it and silently
+// discards the rest. Writes never fail, so a TeeReader using it never
+// interferes with the handler reading the body.
+type cappedBuffer struct {
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (c *cappedBuffer) Write(p []byte) (int, error) {
+	if room := c.max - c.buf.Len(); room > 0 {
+		if len(p) > room {
+			c.buf.Write(p[:room])
+			c.truncated = true
+		} else {
+			c.buf.Write(p)
+		}
+	} else if len(p) > 0 {
+		c.truncated = true
+	}
+	return len(p), nil
+}
+
+// captureJSONBody wraps r.Body so that whatever the handler reads is also
+// copied into a capped buffer. The body is never read by the middleware
+// itself, so streaming handlers are unaffected.
+func captureJSONBody(r *http.Request, max int) *cappedBuffer {
+	if r.Body == nil || r.Body == http.NoBody || !isJSONContentType(r.Header.Get("Content-Type")) {
+		return nil
+	}
+	buf := &cappedBuffer{max: max}
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.TeeReader(r.Body, buf), r.Body}
+	return buf
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// requestBytes returns the request body size from Content-Length, falling
+// back to the bytes counted by counter (if any) when it is unknown
+func requestBytes(r *http.Request, counter *countingReader) int64 {
+	if r.ContentLength >= 0 {
+		return r.ContentLength
+	}
+	if counter != nil {
+		return counter.n
+	}
+	return 0
+}
+
 // --- helpers ---
 
+func isJSONContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+var sensitiveValuePattern = regexp.MustCompile(
+	`(?i)("(?:` + strings.Join(sensitiveKeys, "|") + `)"\s*:\s*)("(?:[^"\\]|\\.)*"?|[^,}\s]*)`,
+)
+
+// redactBody returns the captured body with sensitive keys redacted.
+// Complete JSON documents are redacted structurally; truncated ones fall
+// back to pattern matching on the raw text.
+func redactBody(body *cappedBuffer) string {
+	raw := body.buf.Bytes()
+
+	var doc interface{}
+	if !body.truncated && json.Unmarshal(raw, &doc) == nil {
+		if out, err := json.Marshal(redactValue(doc)); err == nil {
+			return string(out)
+		}
+	}
+
+	redacted := sensitiveValuePattern.ReplaceAllString(string(raw), `$1"[REDACTED]"`)
+	if body.truncated {
+		redacted += "... [truncated]"
+	}
+	return redacted
+}
+
+func redactValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, inner := range val {
+			if isSensitiveKey(k) {
+				val[k] = "[REDACTED]"
+			} else {
+				val[k] = redactValue(inner)
+			}
+		}
+	case []interface{}:
+		for i, inner := range val {
+			val[i] = redactValue(inner)
+		}
+	}
+	return v
+}
+
+func isSensitiveKey(key string) bool {
+	return containsFold(sensitiveKeys, key)
+}
+
+// redactQuery replaces the values of the given parameters in a raw query
+// string, preserving parameter order and encoding. The query is split by
+// hand rather than parsed, so malformed escapes anywhere in it never stop
+// the other parameters from being redacted; a key that fails to unescape
+// is compared as is.
+func redactQuery(rawQuery string, redact []string) string {
+	params := strings.Split(rawQuery, "&")
+	for i, param := range params {
+		key, _, hasValue := strings.Cut(param, "=")
+		if !hasValue {
+			continue
+		}
+		name, err := url.QueryUnescape(key)
+		if err != nil {
+			name = key
+		}
+		if containsFold(redact, name) {
+			params[i] = key + "=[REDACTED]"
+		}
+	}
+	return strings.Join(params, "&")
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// isCORSPreflight reports whether r is a CORS preflight request
+func isCORSPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+}
+
 func severityFromStatus(status int) LogLevel {
 	switch {
 	case status >= 500:
@@ -0,0 +1,181 @@
+package logdot
+
+import "net/http"
+
+// MiddlewareBuilder configures HTTP middleware step by step, as an
+// alternative to filling in a MiddlewareConfig. It starts from
+// DefaultMiddlewareConfig.
+//
+// Example:
+//
+//	handler := logdot.NewMiddleware().
+//		WithLogger(logger).
+//		WithMetrics(metrics).
+//		IgnorePaths("/health", "/metrics").
+//		SampleRate(0.1).
+//		Build()(mux)
+type MiddlewareBuilder struct {
+	config MiddlewareConfig
+}
+
+// NewMiddleware starts a MiddlewareBuilder with default settings
+func NewMiddleware() *MiddlewareBuilder {
+	return &MiddlewareBuilder{config: DefaultMiddlewareConfig()}
+}
+
+// WithLogger sets the Logger requests are logged through (required)
+func (b *MiddlewareBuilder) WithLogger(logger *Logger) *MiddlewareBuilder {
+	b.config.Logger = logger
+	return b
+}
+
+// WithMetrics sets the Metrics client used for request metrics
+func (b *MiddlewareBuilder) WithMetrics(metrics *Metrics) *MiddlewareBuilder {
+	b.config.Metrics = metrics
+	return b
+}
+
+// EntityName sets the metrics entity name
+func (b *MiddlewareBuilder) EntityName(name string) *MiddlewareBuilder {
+	b.config.EntityName = name
+	return b
+}
+
+// EntityDescription sets the description of a newly created entity
+func (b *MiddlewareBuilder) EntityDescription(description string) *MiddlewareBuilder {
+	b.config.EntityDescription = description
+	return b
+}
+
+// LogRequests enables or disables per-request log entries
+func (b *MiddlewareBuilder) LogRequests(enabled bool) *MiddlewareBuilder {
+	b.config.LogRequests = enabled
+	return b
+}
+
+// LogMetrics enables or disables the request duration metric
+func (b *MiddlewareBuilder) LogMetrics(enabled bool) *MiddlewareBuilder {
+	b.config.LogMetrics = enabled
+	return b
+}
+
+// LogRequestCount enables or disables the request count metric
+func (b *MiddlewareBuilder) LogRequestCount(enabled bool) *MiddlewareBuilder {
+	b.config.LogRequestCount = enabled
+	return b
+}
+
+// IgnorePaths adds paths that are neither logged nor metered
+func (b *MiddlewareBuilder) IgnorePaths(paths ...string) *MiddlewareBuilder {
+	b.config.IgnorePaths = append(b.config.IgnorePaths, paths...)
+	return b
+}
+
+// IgnoreCORSPreflight enables or disables skipping CORS preflights
+func (b *MiddlewareBuilder) IgnoreCORSPreflight(enabled bool) *MiddlewareBuilder {
+	b.config.IgnoreCORSPreflight = enabled
+	return b
+}
+
+// SampleRate sets the fraction of requests that are logged and metered
+func (b *MiddlewareBuilder) SampleRate(rate float64) *MiddlewareBuilder {
+	b.config.SampleRate = rate
+	return b
+}
+
+// CaptureBodyOnError enables capturing up to maxBytes of JSON request
+// bodies on 4xx/5xx responses. maxBytes <= 0 uses the default.
+func (b *MiddlewareBuilder) CaptureBodyOnError(maxBytes int) *MiddlewareBuilder {
+	b.config.CaptureBodyOnError = true
+	b.config.MaxBodyBytes = maxBytes
+	return b
+}
+
+// CountRequestBytes enables or disables counting request body bytes
+func (b *MiddlewareBuilder) CountRequestBytes(enabled bool) *MiddlewareBuilder {
+	b.config.CountRequestBytes = enabled
+	return b
+}
+
+// CaptureQuery enables the http_query tag. redact replaces the default
+// list of parameters whose values are redacted, when given.
+func (b *MiddlewareBuilder) CaptureQuery(redact ...string) *MiddlewareBuilder {
+	b.config.CaptureQuery = true
+	if len(redact) > 0 {
+		b.config.RedactQueryParams = redact
+	}
+	return b
+}
+
+// MetricStatusFilter sets which response statuses get duration metrics
+func (b *MiddlewareBuilder) MetricStatusFilter(filter func(status int) bool) *MiddlewareBuilder {
+	b.config.MetricStatusFilter = filter
+	return b
+}
+
+// DurationBuckets sets the latency histogram bucket bounds in milliseconds
+func (b *MiddlewareBuilder) DurationBuckets(buckets ...float64) *MiddlewareBuilder {
+	b.config.DurationBuckets = buckets
+	return b
+}
+
+// RepanicAfterLogging re-raises handler panics after logging instead of
+// turning them into 500s
+func (b *MiddlewareBuilder) RepanicAfterLogging(enabled bool) *MiddlewareBuilder {
+	b.config.RepanicAfterLogging = enabled
+	return b
+}
+
+// HandlerNameFunc sets the function naming each request's handler
+func (b *MiddlewareBuilder) HandlerNameFunc(fn func(r *http.Request) string) *MiddlewareBuilder {
+	b.config.HandlerNameFunc = fn
+	return b
+}
+
+// PathLevelOverride sets the log level for successful requests to path
+func (b *MiddlewareBuilder) PathLevelOverride(path string, level LogLevel) *MiddlewareBuilder {
+	if b.config.PathLevelOverride == nil {
+		b.config.PathLevelOverride = make(map[string]LogLevel)
+	}
+	b.config.PathLevelOverride[path] = level
+	return b
+}
+
+// FlushEachRequest enables or disables flushing a batching logger after
+// each request
+func (b *MiddlewareBuilder) FlushEachRequest(enabled bool) *MiddlewareBuilder {
+	b.config.FlushEachRequest = enabled
+	return b
+}
+
+// CaptureTrace enables the trace_id and span_id tags. traceIDs may be nil
+// to read the trace ID from the traceparent header.
+func (b *MiddlewareBuilder) CaptureTrace(traceIDs TraceIDsFunc) *MiddlewareBuilder {
+	b.config.CaptureTrace = true
+	b.config.TraceIDs = traceIDs
+	return b
+}
+
+// EntityIDContextKey sets the request context key holding a metrics
+// entity ID that overrides the default entity
+func (b *MiddlewareBuilder) EntityIDContextKey(key interface{}) *MiddlewareBuilder {
+	b.config.EntityIDContextKey = key
+	return b
+}
+
+// OnInternalError sets the callback for failures of the middleware's own
+// logging and metric sending
+func (b *MiddlewareBuilder) OnInternalError(fn func(err error)) *MiddlewareBuilder {
+	b.config.OnInternalError = fn
+	return b
+}
+
+// Config returns the MiddlewareConfig built so far
+func (b *MiddlewareBuilder) Config() MiddlewareConfig {
+	return b.config
+}
+
+// Build returns the middleware, equivalent to Middleware(b.Config())
+func (b *MiddlewareBuilder) Build() func(http.Handler) http.Handler {
+	return Middleware(b.config)
+}
@@ -0,0 +1,53 @@
+package logdot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMiddlewareBuilder(t *testing.T) {
+	logger := NewLogger("test_key", "test-service")
+	logger.BeginBatch()
+
+	builder := NewMiddleware().
+		WithLogger(logger).
+		IgnorePaths("/health").
+		CaptureQuery("secret").
+		DurationBuckets(10, 100)
+
+	cfg := builder.Config()
+	if !cfg.LogRequests || !cfg.LogMetrics || cfg.RepanicAfterLogging {
+		t.Errorf("expected builder to start from defaults, got %+v", cfg)
+	}
+	if !cfg.CaptureQuery || len(cfg.RedactQueryParams) != 1 || len(cfg.DurationBuckets) != 2 {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+
+	handler := builder.Build()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/health", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/users?secret=x", nil))
+
+	if logger.BatchSize() != 1 {
+		t.Fatalf("expected 1 log entry, got %d", logger.BatchSize())
+	}
+	if got := logger.batchQueue[0].Tags["http_query"]; got != "secret=[REDACTED]" {
+		t.Errorf("expected redacted query, got %v", got)
+	}
+}
+
+func TestMiddlewareSampleRate(t *testing.T) {
+	logger := NewLogger("test_key", "test-service")
+	logger.BeginBatch()
+
+	handler := NewMiddleware().WithLogger(logger).SampleRate(0.5).
+		Build()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for i := 0; i < 1000; i++ {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/users", nil))
+	}
+
+	if n := logger.BatchSize(); n < 350 || n > 650 {
+		t.Errorf("expected about half the requests logged, got %d", n)
+	}
+}
@@ -1,12 +1,49 @@
 package logdot
 
 import (
+	"context"
+	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func newTestMiddleware(overrides ...func(*MiddlewareConfig)) (http.Handler, *Logger) {
+	cfg, inner, logger := newTestMiddlewareConfig(overrides...)
+	return Middleware(cfg)(inner), logger
+}
+
+// newResolvedTestMiddleware is newTestMiddleware, returning once the
+// default metrics entity has been resolved
+func newResolvedTestMiddleware(t *testing.T, overrides ...func(*MiddlewareConfig)) (http.Handler, *Logger) {
+	cfg, inner, logger := newTestMiddlewareConfig(overrides...)
+	return newResolvedMiddleware(t, cfg, inner), logger
+}
+
+// newResolvedMiddleware is Middleware(cfg)(next), returning once the
+// default metrics entity has been resolved in the background
+func newResolvedMiddleware(t *testing.T, cfg MiddlewareConfig, next http.Handler) http.Handler {
+	t.Helper()
+	return newResolvedMiddlewareState(t, cfg).wrap(next)
+}
+
+func newResolvedMiddlewareState(t *testing.T, cfg MiddlewareConfig) *middlewareState {
+	t.Helper()
+	mw := newMiddlewareState(cfg)
+	select {
+	case <-mw.entityReady:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected metrics entity to be resolved")
+	}
+	return mw
+}
+
+func newTestMiddlewareConfig(overrides ...func(*MiddlewareConfig)) (MiddlewareConfig, http.Handler, *Logger) {
 	logger := NewLogger("test_key", "test-service")
 	logger.BeginBatch()
 
@@ -33,8 +70,7 @@ func newTestMiddleware(overrides ...func(*MiddlewareConfig)) (http.Handler, *Log
 		w.Write([]byte("ok"))
 	})
 
-	handler := Middleware(cfg)(inner)
-	return handler, logger
+	return cfg, inner, logger
 }
 
 func TestMiddlewareReturnsResponse(t *testing.T) {
@@ -194,6 +230,62 @@ func TestMiddlewareNeverPanics(t *testing.T) {
 	}
 }
 
+func TestMiddlewareLogsPanics(t *testing.T) {
+	handler, logger := newTestMiddleware()
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/panic", nil))
+
+	if logger.BatchSize() != 1 {
+		t.Fatalf("expected 1 log entry, got %d", logger.BatchSize())
+	}
+	entry := logger.batchQueue[0]
+	if entry.Level != LevelError || entry.Tags["http_status"] != 500 {
+		t.Errorf("expected error-level 500 entry, got %+v", entry)
+	}
+	if entry.Tags["panic"] != "test panic" {
+		t.Errorf("expected panic tag, got %v", entry.Tags["panic"])
+	}
+}
+
+func TestMiddlewareZeroConfigRecoversPanics(t *testing.T) {
+	handler := Middleware(MiddlewareConfig{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("test panic")
+	}))
+
+	rr := httptest.NewRecorder()
+	func() {
+		defer func() {
+			if p := recover(); p != nil {
+				t.Errorf("expected panic to be recovered, got %v", p)
+			}
+		}()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", "/panic", nil))
+	}()
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("expected 500, got %d", rr.Code)
+	}
+}
+
+func TestMiddlewareRepanicAfterLogging(t *testing.T) {
+	handler, logger := newTestMiddleware(func(cfg *MiddlewareConfig) {
+		cfg.RepanicAfterLogging = true
+	})
+
+	var recovered interface{}
+	func() {
+		defer func() { recovered = recover() }()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/panic", nil))
+	}()
+
+	if recovered != "test panic" {
+		t.Errorf("expected panic to propagate, got %v", recovered)
+	}
+	if logger.BatchSize() != 1 {
+		t.Errorf("expected panicking request to be logged, got %d", logger.BatchSize())
+	}
+}
+
 func TestMiddlewareNoMetricsWhenNil(t *testing.T) {
 	handler, logger := newTestMiddleware(func(cfg *MiddlewareConfig) {
 		cfg.Metrics = nil
@@ -251,3 +343,957 @@ func containsAll(s string, substrs ...string) bool {
 	}
 	return true
 }
+
+func newBodyTestMiddleware(status int, maxBytes int) (http.Handler, *Logger) {
+	logger := NewLogger("test_key", "test-service")
+	logger.BeginBatch()
+
+	cfg := DefaultMiddlewareConfig()
+	cfg.Logger = logger
+	cfg.CaptureBodyOnError = true
+	cfg.MaxBodyBytes = maxBytes
+
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		w.WriteHeader(status)
+	})
+
+	return Middleware(cfg)(inner), logger
+}
+
+func TestMiddlewareCapturesBodyOnError(t *testing.T) {
+	handler, logger := newBodyTestMiddleware(http.StatusBadRequest, 0)
+
+	req := httptest.NewRequest("POST", "/api/login", strings.NewReader(`{"user":"bob","password":"hunter2"}`))
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	body, _ := logger.batchQueue[0].Tags["request_body"].(string)
+	if !strings.Contains(body, `"user":"bob"`) {
+		t.Errorf("expected request_body to contain user, got %q", body)
+	}
+	if strings.Contains(body, "hunter2") || !strings.Contains(body, "[REDACTED]") {
+		t.Errorf("expected password to be redacted, got %q", body)
+	}
+}
+
+func TestMiddlewareCapturedBodyTruncated(t *testing.T) {
+	handler, logger := newBodyTestMiddleware(http.StatusInternalServerError, 20)
+
+	req := httptest.NewRequest("POST", "/api/login", strings.NewReader(`{"token":"abcdef","padding":"xxxxxxxxxxxxxxxx"}`))
+	req.Header.Set("Content-Type", "application/json")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	body, _ := logger.batchQueue[0].Tags["request_body"].(string)
+	if strings.Contains(body, "abcdef") {
+		t.Errorf("expected token to be redacted, got %q", body)
+	}
+	if !strings.HasSuffix(body, "... [truncated]") {
+		t.Errorf("expected truncation marker, got %q", body)
+	}
+}
+
+func TestMiddlewareSkipsBodyOnSuccess(t *testing.T) {
+	handler, logger := newBodyTestMiddleware(http.StatusOK, 0)
+
+	req := httptest.NewRequest("POST", "/api/users", strings.NewReader(`{"name":"bob"}`))
+	req.Header.Set("Content-Type", "application/json")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if _, ok := logger.batchQueue[0].Tags["request_body"]; ok {
+		t.Error("expected no request_body tag for 2xx response")
+	}
+}
+
+func TestMiddlewareSkipsNonJSONBody(t *testing.T) {
+	handler, logger := newBodyTestMiddleware(http.StatusBadRequest, 0)
+
+	req := httptest.NewRequest("POST", "/upload", strings.NewReader("plain text"))
+	req.Header.Set("Content-Type", "text/plain")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if _, ok := logger.batchQueue[0].Tags["request_body"]; ok {
+		t.Error("expected no request_body tag for non-JSON body")
+	}
+}
+
+func TestMiddlewareCapturesQuery(t *testing.T) {
+	handler, logger := newTestMiddleware(func(cfg *MiddlewareConfig) {
+		cfg.CaptureQuery = true
+	})
+
+	req := httptest.NewRequest("GET", "/api/users?action=list&Token=secret&page=2", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	tags := logger.batchQueue[0].Tags
+	if tags["http_query"] != "action=list&Token=[REDACTED]&page=2" {
+		t.Errorf("unexpected http_query: %v", tags["http_query"])
+	}
+	if tags["http_path"] != "/api/users" {
+		t.Errorf("expected http_path without query, got %v", tags["http_path"])
+	}
+}
+
+func TestMiddlewareCapturesQueryCustomRedaction(t *testing.T) {
+	handler, logger := newTestMiddleware(func(cfg *MiddlewareConfig) {
+		cfg.CaptureQuery = true
+		cfg.RedactQueryParams = []string{"email"}
+	})
+
+	req := httptest.NewRequest("GET", "/api/users?email=a%40b.c&token=abc", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if got := logger.batchQueue[0].Tags["http_query"]; got != "email=[REDACTED]&token=abc" {
+		t.Errorf("unexpected http_query: %v", got)
+	}
+}
+
+func TestMiddlewareRedactsMalformedQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"token=secret&x=%zz", "token=[REDACTED]&x=%zz"},
+		{"x=%zz&password=hunter2", "x=%zz&password=[REDACTED]"},
+		{"token=sec%zzret", "token=[REDACTED]"},
+		{"to%zzken=secret&api_key=k", "to%zzken=secret&api_key=[REDACTED]"},
+		{"%zz&token=a&token=b", "%zz&token=[REDACTED]&token=[REDACTED]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			handler, logger := newTestMiddleware(func(cfg *MiddlewareConfig) {
+				cfg.CaptureQuery = true
+			})
+
+			req := httptest.NewRequest("GET", "/api/users", nil)
+			req.URL.RawQuery = tt.query
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if got := logger.batchQueue[0].Tags["http_query"]; got != tt.want {
+				t.Errorf("expected %q, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMiddlewareOmitsQueryByDefault(t *testing.T) {
+	handler, logger := newTestMiddleware()
+
+	req := httptest.NewRequest("GET", "/api/users?action=list", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if _, ok := logger.batchQueue[0].Tags["http_query"]; ok {
+		t.Error("expected no http_query tag by default")
+	}
+}
+
+func TestRequestObserver(t *testing.T) {
+	logger := NewLogger("test_key", "test-service")
+	logger.BeginBatch()
+
+	cfg := DefaultMiddlewareConfig()
+	cfg.Logger = logger
+	cfg.IgnorePaths = []string{"/health"}
+	observer := NewRequestObserver(cfg)
+
+	if !observer.Ignored(httptest.NewRequest("GET", "/health", nil)) {
+		t.Error("expected /health to be ignored")
+	}
+
+	observer.Observe(httptest.NewRequest("PUT", "/api/items/1", nil), http.StatusConflict, 1500*time.Microsecond)
+
+	if logger.BatchSize() != 1 {
+		t.Fatalf("expected 1 log entry, got %d", logger.BatchSize())
+	}
+	entry := logger.batchQueue[0]
+	if entry.Level != LevelWarn || entry.Tags["http_status"] != 409 || entry.Tags["duration_ms"] != 1.5 {
+		t.Errorf("unexpected entry: %+v", entry)
+	}
+}
+
+func TestMiddlewareMetricStatusFilter(t *testing.T) {
+	var metricCalls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/entities/by-name/test-service":
+			w.Write([]byte(`{"data":{"id":"entity-1"}}`))
+		case "/api/v1/metrics":
+			metricCalls++
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	metrics := NewMetrics("test_key")
+	redirectToServer(metrics.http, server)
+
+	logger := NewLogger("test_key", "test-service")
+	logger.BeginBatch()
+
+	cfg := DefaultMiddlewareConfig()
+	cfg.Logger = logger
+	cfg.Metrics = metrics
+	cfg.MetricStatusFilter = func(status int) bool {
+		return status < 300 || status >= 400 // skip redirects
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/old", http.RedirectHandler("/new", http.StatusFound))
+	mux.HandleFunc("/new", func(w http.ResponseWriter, r *http.Request) {})
+	handler := newResolvedMiddleware(t, cfg, mux)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/new", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/old", nil))
+
+	if metricCalls != 1 {
+		t.Errorf("expected 1 metric (redirect filtered), got %d", metricCalls)
+	}
+	if logger.BatchSize() != 2 {
+		t.Errorf("expected both requests logged, got %d", logger.BatchSize())
+	}
+}
+
+func TestMiddlewareDurationBuckets(t *testing.T) {
+	var bucketTags [][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/entities/by-name/test-service":
+			w.Write([]byte(`{"data":{"id":"entity-1"}}`))
+		case "/api/v1/metrics":
+			var payload struct {
+				Name string   `json:"name"`
+				Tags []string `json:"tags"`
+			}
+			json.NewDecoder(r.Body).Decode(&payload)
+			if payload.Name == "http.request.duration.bucket" {
+				bucketTags = append(bucketTags, payload.Tags)
+			}
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	metrics := NewMetrics("test_key")
+	redirectToServer(metrics.http, server)
+
+	logger := NewLogger("test_key", "test-service")
+	logger.BeginBatch()
+
+	cfg := DefaultMiddlewareConfig()
+	cfg.Logger = logger
+	cfg.Metrics = metrics
+	cfg.DurationBuckets = []float64{1000, 50}
+
+	mw := newResolvedMiddlewareState(t, cfg)
+	handler := mw.wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/fast", nil))
+	mw.pendingPoints.Wait()
+
+	if len(bucketTags) != 1 {
+		t.Fatalf("expected 1 bucket metric, got %d", len(bucketTags))
+	}
+	found := false
+	for _, tag := range bucketTags[0] {
+		if tag == "le:50" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected le:50 tag, got %v", bucketTags[0])
+	}
+}
+
+func TestBucketLabel(t *testing.T) {
+	buckets := []float64{10, 50.5, 100}
+	cases := map[float64]string{
+		3:   "10",
+		10:  "10",
+		42:  "50.5",
+		100: "100",
+		250: "+Inf",
+	}
+	for duration, want := range cases {
+		if got := bucketLabel(buckets, duration); got != want {
+			t.Errorf("bucketLabel(%v) = %q, expected %q", duration, got, want)
+		}
+	}
+}
+
+func TestMiddlewareIgnoreCORSPreflight(t *testing.T) {
+	logger := NewLogger("test_key", "test-service")
+	logger.BeginBatch()
+
+	cfg := DefaultMiddlewareConfig()
+	cfg.Logger = logger
+	cfg.IgnoreCORSPreflight = true
+
+	handler := Middleware(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	preflight := httptest.NewRequest("OPTIONS", "/api/users", nil)
+	preflight.Header.Set("Origin", "https://example.com")
+	preflight.Header.Set("Access-Control-Request-Method", "POST")
+	handler.ServeHTTP(httptest.NewRecorder(), preflight)
+
+	if logger.BatchSize() != 0 {
+		t.Errorf("expected preflight to be skipped, got %d entries", logger.BatchSize())
+	}
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("OPTIONS", "/api/users", nil))
+
+	if logger.BatchSize() != 1 {
+		t.Errorf("expected plain OPTIONS to be logged, got %d entries", logger.BatchSize())
+	}
+}
+
+func TestMiddlewareRequestCount(t *testing.T) {
+	var names []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/entities/by-name/test-service":
+			w.Write([]byte(`{"data":{"id":"entity-1"}}`))
+		case "/api/v1/metrics":
+			var payload struct {
+				Name  string  `json:"name"`
+				Value float64 `json:"value"`
+			}
+			json.NewDecoder(r.Body).Decode(&payload)
+			if payload.Name == "http.request.count" && payload.Value != 1 {
+				t.Errorf("expected count value 1, got %v", payload.Value)
+			}
+			names = append(names, payload.Name)
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	metrics := NewMetrics("test_key")
+	redirectToServer(metrics.http, server)
+
+	logger := NewLogger("test_key", "test-service")
+	logger.BeginBatch()
+
+	cfg := DefaultMiddlewareConfig()
+	cfg.Logger = logger
+	cfg.Metrics = metrics
+	cfg.LogRequestCount = true
+
+	mw := newResolvedMiddlewareState(t, cfg)
+	handler := mw.wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/users", nil))
+	mw.pendingPoints.Wait()
+
+	if len(names) != 2 || names[0] != "http.request.duration" || names[1] != "http.request.count" {
+		t.Errorf("expected duration and count metrics, got %v", names)
+	}
+}
+
+func TestMiddlewarePointMetricsDoNotBlockRequests(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/entities/by-name/test-service":
+			w.Write([]byte(`{"data":{"id":"entity-1"}}`))
+		case "/api/v1/metrics":
+			var payload struct {
+				Name string `json:"name"`
+			}
+			json.NewDecoder(r.Body).Decode(&payload)
+			if payload.Name != "http.request.duration" {
+				<-release
+			}
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	metrics := NewMetrics("test_key", WithMetricsRetry(1, time.Millisecond, time.Millisecond))
+	redirectToServer(metrics.http, server)
+
+	logger := NewLogger("test_key", "test-service")
+	logger.BeginBatch()
+
+	cfg := DefaultMiddlewareConfig()
+	cfg.Logger = logger
+	cfg.Metrics = metrics
+	cfg.LogRequestCount = true
+	cfg.DurationBuckets = []float64{50}
+
+	mw := newResolvedMiddlewareState(t, cfg)
+	handler := mw.wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 3; i++ {
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/users", nil))
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		close(release)
+		t.Fatal("expected requests not to wait for the count and bucket sends")
+	}
+
+	close(release)
+	mw.pendingPoints.Wait()
+}
+
+func TestMiddlewareRequestBytes(t *testing.T) {
+	logger := NewLogger("test_key", "test-service")
+	logger.BeginBatch()
+
+	cfg := DefaultMiddlewareConfig()
+	cfg.Logger = logger
+	cfg.CountRequestBytes = true
+
+	handler := Middleware(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/upload", strings.NewReader("0123456789")))
+
+	chunked := httptest.NewRequest("POST", "/upload", io.NopCloser(strings.NewReader("abcdef")))
+	chunked.ContentLength = -1
+	handler.ServeHTTP(httptest.NewRecorder(), chunked)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/users", nil))
+
+	if logger.BatchSize() != 3 {
+		t.Fatalf("expected 3 log entries, got %d", logger.BatchSize())
+	}
+	if got := logger.batchQueue[0].Tags["request_bytes"]; got != 10 {
+		t.Errorf("expected request_bytes 10 from Content-Length, got %v", got)
+	}
+	if got := logger.batchQueue[1].Tags["request_bytes"]; got != 6 {
+		t.Errorf("expected request_bytes 6 counted from the body, got %v", got)
+	}
+	if _, ok := logger.batchQueue[2].Tags["request_bytes"]; ok {
+		t.Errorf("expected no request_bytes for a bodiless request")
+	}
+}
+
+func TestMiddlewareRequestBytesChunkedWithoutCounting(t *testing.T) {
+	handler, logger := newTestMiddleware()
+
+	chunked := httptest.NewRequest("POST", "/upload", io.NopCloser(strings.NewReader("abcdef")))
+	chunked.ContentLength = -1
+	handler.ServeHTTP(httptest.NewRecorder(), chunked)
+
+	if _, ok := logger.batchQueue[0].Tags["request_bytes"]; ok {
+		t.Errorf("expected no request_bytes for unknown length without counting")
+	}
+}
+
+func TestMiddlewareConfigValidate(t *testing.T) {
+	cfg := DefaultMiddlewareConfig()
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "Logger is nil") {
+		t.Errorf("expected missing logger error, got %v", err)
+	}
+
+	cfg.Logger = NewLogger("test_key", "test-service")
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("expected valid config, got %v", err)
+	}
+
+	if err := (MiddlewareConfig{Logger: cfg.Logger}).Validate(); err == nil {
+		t.Error("expected error when nothing is enabled")
+	}
+}
+
+func TestMiddlewareWarnsWithoutLogger(t *testing.T) {
+	var out strings.Builder
+	defer func(w io.Writer) { warningOutput = w }(warningOutput)
+	warningOutput = &out
+	nilLoggerWarning = sync.Once{}
+
+	Middleware(DefaultMiddlewareConfig())
+	Middleware(DefaultMiddlewareConfig())
+
+	if strings.Count(out.String(), "without a Logger") != 1 {
+		t.Errorf("expected a single warning, got %q", out.String())
+	}
+}
+
+func TestMiddlewareOnInternalErrorMetricFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/entities/by-name/test-service":
+			w.Write([]byte(`{"data":{"id":"entity-1"}}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	metrics := NewMetrics("test_key", WithMetricsRetry(1, time.Millisecond, time.Millisecond))
+	redirectToServer(metrics.http, server)
+
+	var errs []error
+	handler, _ := newResolvedTestMiddleware(t, func(c *MiddlewareConfig) {
+		c.Metrics = metrics
+		c.OnInternalError = func(err error) { errs = append(errs, err) }
+	})
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/users", nil))
+
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "http.request.duration") {
+		t.Errorf("expected one metric send error, got %v", errs)
+	}
+}
+
+func TestMiddlewareOnInternalErrorPanic(t *testing.T) {
+	var errs []error
+	handler, logger := newTestMiddleware(func(c *MiddlewareConfig) {
+		c.Metrics = NewMetrics("test_key")
+		c.MetricStatusFilter = func(int) bool { panic("filter bug") }
+		c.OnInternalError = func(err error) {
+			errs = append(errs, err)
+			panic("callback bug")
+		}
+	})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/api/users", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d", rec.Code)
+	}
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "filter bug") {
+		t.Errorf("expected the recovered panic to be reported, got %v", errs)
+	}
+	if logger.BatchSize() != 1 {
+		t.Errorf("expected the request to still be logged, got %d entries", logger.BatchSize())
+	}
+}
+
+func TestMiddlewareAttachesRequestLogger(t *testing.T) {
+	logger := NewLogger("test_key", "test-service")
+	logger.BeginBatch()
+
+	cfg := DefaultMiddlewareConfig()
+	cfg.Logger = logger
+
+	var reqLogger *Logger
+	handler := Middleware(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqLogger = FromContext(r.Context())
+	}))
+
+	req := httptest.NewRequest("GET", "/api/users", nil)
+	req.Header.Set("X-Request-ID", "req-42")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if reqLogger == nil {
+		t.Fatal("expected a logger on the request context")
+	}
+	if reqLogger.logCtx["request_id"] != "req-42" || reqLogger.logCtx["http_method"] != "GET" || reqLogger.logCtx["http_path"] != "/api/users" {
+		t.Errorf("expected request-scoped tags, got %v", reqLogger.logCtx)
+	}
+	if got := logger.batchQueue[0].Tags["request_id"]; got != "req-42" {
+		t.Errorf("expected request log tagged request_id=req-42, got %v", got)
+	}
+}
+
+func TestMiddlewareSkippedRequestsGetBaseLogger(t *testing.T) {
+	logger := NewLogger("test_key", "test-service")
+	logger.BeginBatch()
+
+	cfg := DefaultMiddlewareConfig()
+	cfg.Logger = logger
+	cfg.IgnorePaths = []string{"/health"}
+
+	var reqLogger *Logger
+	handler := Middleware(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqLogger = FromContext(r.Context())
+	}))
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest("GET", "/health", nil),
+		httptest.NewRequest("GET", "/api/users", nil).WithContext(WithSampled(context.Background(), false)),
+	} {
+		reqLogger = nil
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		if reqLogger != logger {
+			t.Errorf("expected the base logger for skipped request %s, got %p", req.URL.Path, reqLogger)
+		}
+	}
+}
+
+func TestMiddlewareGeneratesRequestID(t *testing.T) {
+	handler, logger := newTestMiddleware()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/a", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/b", nil))
+
+	first, _ := logger.batchQueue[0].Tags["request_id"].(string)
+	second, _ := logger.batchQueue[1].Tags["request_id"].(string)
+	if first == "" || first == second {
+		t.Errorf("expected distinct generated request IDs, got %q and %q", first, second)
+	}
+}
+
+func TestMiddlewareEntityDescription(t *testing.T) {
+	var descriptions []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST" && strings.HasSuffix(r.URL.Path, "/entities"):
+			var payload EntityPayload
+			json.NewDecoder(r.Body).Decode(&payload)
+			descriptions = append(descriptions, payload.Description)
+			w.Write([]byte(`{"data":{"id":"entity-1"}}`))
+		case strings.HasSuffix(r.URL.Path, "/metrics"):
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	for _, custom := range []string{"", "Checkout API (staging)"} {
+		metrics := NewMetrics("test_key")
+		redirectToServer(metrics.http, server)
+
+		handler, _ := newResolvedTestMiddleware(t, func(c *MiddlewareConfig) {
+			c.Metrics = metrics
+			c.EntityDescription = custom
+		})
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/users", nil))
+	}
+
+	if len(descriptions) != 2 || descriptions[0] != "HTTP service: test-service" || descriptions[1] != "Checkout API (staging)" {
+		t.Errorf("expected default then custom description, got %q", descriptions)
+	}
+}
+
+func TestMiddlewareHandlerName(t *testing.T) {
+	var metricTags []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/entities/by-name/test-service":
+			w.Write([]byte(`{"data":{"id":"entity-1"}}`))
+		case "/api/v1/metrics":
+			var payload MetricEntry
+			json.NewDecoder(r.Body).Decode(&payload)
+			metricTags = payload.Tags
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	metrics := NewMetrics("test_key")
+	redirectToServer(metrics.http, server)
+
+	handler, logger := newResolvedTestMiddleware(t, func(c *MiddlewareConfig) {
+		c.Metrics = metrics
+		c.HandlerNameFunc = func(r *http.Request) string {
+			if strings.HasPrefix(r.URL.Path, "/users/") {
+				return "GetUser"
+			}
+			return ""
+		}
+	})
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/users/42", nil))
+	if got := logger.batchQueue[0].Tags["handler"]; got != "GetUser" {
+		t.Errorf("expected handler tag on log, got %v", got)
+	}
+	if !strings.Contains(strings.Join(metricTags, ","), "handler:GetUser") {
+		t.Errorf("expected handler tag on metric, got %v", metricTags)
+	}
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/other", nil))
+	if _, ok := logger.batchQueue[1].Tags["handler"]; ok {
+		t.Error("expected no handler tag for an empty name")
+	}
+}
+
+func TestMiddlewareEntityResolutionDoesNotBlock(t *testing.T) {
+	release := make(chan struct{})
+	var metricCalls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/entities/by-name/test-service":
+			<-release
+			w.Write([]byte(`{"data":{"id":"entity-1"}}`))
+		case "/api/v1/metrics":
+			metricCalls.Add(1)
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	var releaseOnce sync.Once
+	defer releaseOnce.Do(func() { close(release) })
+
+	metrics := NewMetrics("test_key")
+	redirectToServer(metrics.http, server)
+
+	cfg, inner, _ := newTestMiddlewareConfig(func(c *MiddlewareConfig) { c.Metrics = metrics })
+	mw := newMiddlewareState(cfg)
+	handler := mw.wrap(inner)
+
+	done := make(chan struct{})
+	go func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/a", nil))
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected request not to wait for entity resolution")
+	}
+	if n := metricCalls.Load(); n != 0 {
+		t.Errorf("expected metrics skipped until the entity resolves, got %d", n)
+	}
+
+	releaseOnce.Do(func() { close(release) })
+	select {
+	case <-mw.entityReady:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected metrics entity to be resolved")
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/b", nil))
+	if n := metricCalls.Load(); n != 1 {
+		t.Errorf("expected 1 metric once resolved, got %d", n)
+	}
+}
+
+func TestMiddlewareEntityResolutionBackoff(t *testing.T) {
+	var entityCalls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "/entities") {
+			entityCalls.Add(1)
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	metrics := NewMetrics("test_key", WithMetricsRetry(1, time.Millisecond, time.Millisecond))
+	redirectToServer(metrics.http, server)
+
+	cfg, _, _ := newTestMiddlewareConfig(func(c *MiddlewareConfig) { c.Metrics = metrics })
+	mw := newMiddlewareState(cfg)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		mw.entityMu.Lock()
+		backoff := mw.entityBackoff
+		mw.entityMu.Unlock()
+		if backoff > 0 && !mw.entityResolving.Load() {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the failed resolution to back off")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	calls := entityCalls.Load()
+	mw.ensureEntity()
+	if mw.entityResolving.Load() || entityCalls.Load() != calls {
+		t.Error("expected no new resolution while backing off")
+	}
+	if mw.entityBackoff != entityRetryBaseDelay {
+		t.Errorf("expected backoff %v, got %v", entityRetryBaseDelay, mw.entityBackoff)
+	}
+}
+
+func TestMiddlewareCaptureTrace(t *testing.T) {
+	handler, logger := newTestMiddleware(func(c *MiddlewareConfig) {
+		c.CaptureTrace = true
+	})
+
+	for _, header := range []string{
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"garbage",
+		"",
+	} {
+		req := httptest.NewRequest("GET", "/api/users", nil)
+		if header != "" {
+			req.Header.Set("traceparent", header)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	first := logger.batchQueue[0].Tags
+	if first["trace_id"] != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("expected trace_id from traceparent, got %v", first["trace_id"])
+	}
+	if _, ok := first["span_id"]; ok {
+		t.Error("expected no span_id from the caller's traceparent")
+	}
+	for i, entry := range logger.batchQueue[1:] {
+		if _, ok := entry.Tags["trace_id"]; ok {
+			t.Errorf("request %d: expected no trace_id, got %v", i+2, entry.Tags["trace_id"])
+		}
+	}
+
+	handler, logger = newTestMiddleware(func(c *MiddlewareConfig) {
+		c.CaptureTrace = true
+		c.TraceIDs = func(ctx context.Context) (string, string) {
+			return "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7"
+		}
+	})
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/users", nil))
+	if tags := logger.batchQueue[0].Tags; tags["trace_id"] != "4bf92f3577b34da6a3ce929d0e0e4736" || tags["span_id"] != "00f067aa0ba902b7" {
+		t.Errorf("expected trace_id and span_id from TraceIDs, got %v", tags)
+	}
+}
+
+func TestMiddlewareEntityIDContextKey(t *testing.T) {
+	type tenantEntityKey struct{}
+
+	var entityIDs []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/entities/by-name/test-service":
+			w.Write([]byte(`{"data":{"id":"entity-default"}}`))
+		case "/api/v1/metrics":
+			var payload MetricEntry
+			json.NewDecoder(r.Body).Decode(&payload)
+			entityIDs = append(entityIDs, payload.EntityID)
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	metrics := NewMetrics("test_key")
+	redirectToServer(metrics.http, server)
+
+	handler, _ := newResolvedTestMiddleware(t, func(c *MiddlewareConfig) {
+		c.Metrics = metrics
+		c.EntityIDContextKey = tenantEntityKey{}
+	})
+
+	req := httptest.NewRequest("GET", "/api/users", nil)
+	req = req.WithContext(context.WithValue(req.Context(), tenantEntityKey{}, "entity-tenant"))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/users", nil))
+
+	if len(entityIDs) != 2 || entityIDs[0] != "entity-tenant" || entityIDs[1] != "entity-default" {
+		t.Errorf("expected tenant entity then default entity, got %v", entityIDs)
+	}
+}
+
+func TestMiddlewarePathLevelOverride(t *testing.T) {
+	status := http.StatusOK
+	logger := NewLogger("test_key", "test-service")
+	logger.BeginBatch()
+	cfg := DefaultMiddlewareConfig()
+	cfg.Logger = logger
+	cfg.PathLevelOverride = map[string]LogLevel{"/healthz": LevelDebug}
+	handler := Middleware(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/healthz", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/users", nil))
+	status = http.StatusServiceUnavailable
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/healthz", nil))
+
+	want := []LogLevel{LevelDebug, LevelInfo, LevelError}
+	for i, level := range want {
+		if got := logger.batchQueue[i].Level; got != level {
+			t.Errorf("request %d: expected level %s, got %s", i, level, got)
+		}
+	}
+}
+
+func TestMiddlewareFlushEachRequest(t *testing.T) {
+	var mu sync.Mutex
+	var sent []LogEntry
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload BatchLogsPayload
+		json.NewDecoder(r.Body).Decode(&payload)
+		mu.Lock()
+		sent = append(sent, payload.Logs...)
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	handler, logger := newTestMiddleware(func(c *MiddlewareConfig) {
+		c.FlushEachRequest = true
+	})
+	redirectToServer(logger.http, server)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/users", nil))
+		}()
+	}
+	wg.Wait()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		mu.Lock()
+		n := len(sent)
+		mu.Unlock()
+		if n == 20 && logger.BatchSize() == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected all 20 request logs delivered, got %d sent and %d queued", n, logger.BatchSize())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestMiddlewareFollowsContextSampling(t *testing.T) {
+	handler, logger := newTestMiddleware()
+
+	req := httptest.NewRequest("GET", "/api/users", nil)
+	req = req.WithContext(WithSampled(req.Context(), false))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if logger.BatchSize() != 0 {
+		t.Errorf("expected sampled-out request to be skipped, got %d entries", logger.BatchSize())
+	}
+}
+
+func TestMiddlewareStoresSamplingDecision(t *testing.T) {
+	logger := NewLogger("test_key", "test-service")
+	logger.BeginBatch()
+
+	var decisions []bool
+	handler := NewMiddleware().WithLogger(logger).SampleRate(0.5).
+		Build()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sampled, ok := sampledFromContext(r.Context())
+			if !ok {
+				t.Fatal("expected a sampling decision on the context")
+			}
+			decisions = append(decisions, sampled)
+			logger.Info(r.Context(), "handler log", nil)
+		}))
+
+	for i := 0; i < 50; i++ {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}
+
+	kept := 0
+	for _, sampled := range decisions {
+		if sampled {
+			kept++
+		}
+	}
+	// Each sampled-in request logs twice (handler + request log); sampled-out
+	// requests log nothing
+	if logger.BatchSize() != 2*kept {
+		t.Errorf("expected %d entries for %d sampled-in requests, got %d", 2*kept, kept, logger.BatchSize())
+	}
+}
@@ -0,0 +1,27 @@
+package logdot
+
+import (
+	"context"
+	"errors"
+)
+
+// Observe logs message and sends metricName with the same tags, so the log
+// entry and the metric can be joined later. Both are attempted even if one
+// fails; the returned error joins their failures. A nil logger or metrics
+// client skips that half.
+//
+// Example:
+//
+//	logdot.Observe(ctx, logger, client, logdot.LevelInfo, "payment captured",
+//		"payment.duration", float64(elapsed.Milliseconds()), "ms",
+//		map[string]interface{}{"provider": "stripe", "currency": "EUR"})
+func Observe(ctx context.Context, logger *Logger, metrics *BoundMetrics, level LogLevel, message, metricName string, value float64, unit string, tags map[string]interface{}) error {
+	var logErr, metricErr error
+	if logger != nil {
+		logErr = logger.Log(ctx, level, message, tags)
+	}
+	if metrics != nil {
+		metricErr = metrics.Send(ctx, metricName, value, unit, tags)
+	}
+	return errors.Join(logErr, metricErr)
+}
@@ -0,0 +1,61 @@
+package logdot
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestObserve(t *testing.T) {
+	var entry MetricEntry
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&entry)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	metrics := NewMetrics("test_key")
+	redirectToServer(metrics.http, server)
+	logger := NewLogger("test_key", "test-service")
+	logger.BeginBatch()
+
+	err := Observe(context.Background(), logger, metrics.ForEntity("entity-1"), LevelInfo, "payment captured",
+		"payment.duration", 120, "ms", map[string]interface{}{"provider": "stripe"})
+	if err != nil {
+		t.Fatalf("Observe failed: %v", err)
+	}
+
+	if logger.BatchSize() != 1 || logger.batchQueue[0].Tags["provider"] != "stripe" {
+		t.Errorf("Expected tagged log entry, got %+v", logger.batchQueue)
+	}
+	if entry.Name != "payment.duration" || entry.Value != 120 || len(entry.Tags) != 1 || entry.Tags[0] != "provider:stripe" {
+		t.Errorf("Expected tagged metric, got %+v", entry)
+	}
+}
+
+func TestObserveMetricFailureStillLogs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	metrics := NewMetrics("test_key", WithMetricsRetry(1, time.Millisecond, time.Millisecond))
+	redirectToServer(metrics.http, server)
+	logger := NewLogger("test_key", "test-service")
+	logger.BeginBatch()
+
+	err := Observe(context.Background(), logger, metrics.ForEntity("entity-1"), LevelWarn, "slow", "op.duration", 900, "ms", nil)
+	if err == nil {
+		t.Error("Expected the metric failure to be returned")
+	}
+	if logger.BatchSize() != 1 {
+		t.Errorf("Expected the log entry despite the metric failure, got %d", logger.BatchSize())
+	}
+
+	if err := Observe(context.Background(), nil, nil, LevelInfo, "noop", "noop", 1, "count", nil); err != nil {
+		t.Errorf("Expected nil error with nil clients, got %v", err)
+	}
+}
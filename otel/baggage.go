@@ -0,0 +1,39 @@
+// Package logdototel connects LogDot to OpenTelemetry context propagation.
+//
+// It lives in its own module so the core SDK stays free of third-party
+// dependencies.
+package logdototel
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/baggage"
+
+	logdot "github.com/logdot-io/logdot-go"
+)
+
+// BaggageExtractor returns the OTel baggage members in ctx as tags
+// prefixed with "baggage." (e.g. baggage.tenant), or nil when there is no
+// baggage. Use it with logdot.WithLoggerContextExtractor.
+//
+// Example:
+//
+//	logger := logdot.NewLogger(apiKey, "my-service",
+//		logdot.WithLoggerContextExtractor(logdototel.BaggageExtractor),
+//	)
+func BaggageExtractor(ctx context.Context) map[string]interface{} {
+	bag := baggage.FromContext(ctx)
+	if bag.Len() == 0 {
+		return nil
+	}
+
+	members := bag.Members()
+	tags := make(map[string]interface{}, len(members))
+	for _, m := range members {
+		tags["baggage."+m.Key()] = m.Value()
+	}
+	return tags
+}
+
+// Verify the signature matches at compile time.
+var _ logdot.ContextExtractor = BaggageExtractor
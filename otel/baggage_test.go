@@ -0,0 +1,48 @@
+package logdototel
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/baggage"
+
+	logdot "github.com/logdot-io/logdot-go"
+	"github.com/logdot-io/logdot-go/logdottest"
+)
+
+func contextWithBaggage(t *testing.T, header string) context.Context {
+	t.Helper()
+	bag, err := baggage.Parse(header)
+	if err != nil {
+		t.Fatalf("parse baggage: %v", err)
+	}
+	return baggage.ContextWithBaggage(context.Background(), bag)
+}
+
+func TestBaggageExtractor(t *testing.T) {
+	ctx := contextWithBaggage(t, "tenant=acme,flag.checkout=v2")
+
+	tags := BaggageExtractor(ctx)
+	if tags["baggage.tenant"] != "acme" || tags["baggage.flag.checkout"] != "v2" {
+		t.Errorf("unexpected tags: %v", tags)
+	}
+	if BaggageExtractor(context.Background()) != nil {
+		t.Error("expected nil without baggage")
+	}
+}
+
+func TestBaggageExtractorWithLogger(t *testing.T) {
+	rec := logdottest.NewRecorder()
+	logger := rec.NewLogger("test-service", logdot.WithLoggerContextExtractor(BaggageExtractor))
+
+	ctx := contextWithBaggage(t, "tenant=acme")
+	logger.Info(ctx, "order placed", map[string]interface{}{"order_id": "A-1"})
+
+	logs := rec.Logs()
+	if len(logs) != 1 {
+		t.Fatalf("expected 1 log, got %d", len(logs))
+	}
+	if logs[0].Tags["baggage.tenant"] != "acme" || logs[0].Tags["order_id"] != "A-1" {
+		t.Errorf("unexpected tags: %v", logs[0].Tags)
+	}
+}
@@ -0,0 +1,11 @@
+module github.com/logdot-io/logdot-go/otel
+
+go 1.21
+
+require (
+	github.com/logdot-io/logdot-go v1.0.0
+	go.opentelemetry.io/otel v1.24.0
+	go.opentelemetry.io/otel/trace v1.24.0
+)
+
+replace github.com/logdot-io/logdot-go => ..
@@ -0,0 +1,32 @@
+package logdototel
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+
+	logdot "github.com/logdot-io/logdot-go"
+)
+
+// SpanIDs returns the trace and span IDs of the span in ctx, or empty
+// strings when there is no valid span. Use it as
+// logdot.MiddlewareConfig.TraceIDs so request logs carry the IDs of the
+// server span started by OTel HTTP instrumentation (e.g. otelhttp)
+// wrapped around the LogDot middleware.
+//
+// Example:
+//
+//	cfg := logdot.DefaultMiddlewareConfig()
+//	cfg.CaptureTrace = true
+//	cfg.TraceIDs = logdototel.SpanIDs
+//	handler := otelhttp.NewHandler(logdot.Middleware(cfg)(mux), "api")
+func SpanIDs(ctx context.Context) (traceID, spanID string) {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return "", ""
+	}
+	return sc.TraceID().String(), sc.SpanID().String()
+}
+
+// Verify the signature matches at compile time.
+var _ logdot.TraceIDsFunc = SpanIDs
@@ -0,0 +1,62 @@
+package logdototel
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+
+	logdot "github.com/logdot-io/logdot-go"
+	"github.com/logdot-io/logdot-go/logdottest"
+)
+
+func contextWithSpan(t *testing.T) context.Context {
+	t.Helper()
+	traceID, err := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	if err != nil {
+		t.Fatalf("parse trace ID: %v", err)
+	}
+	spanID, err := trace.SpanIDFromHex("00f067aa0ba902b7")
+	if err != nil {
+		t.Fatalf("parse span ID: %v", err)
+	}
+	sc := trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID})
+	return trace.ContextWithSpanContext(context.Background(), sc)
+}
+
+func TestSpanIDs(t *testing.T) {
+	traceID, spanID := SpanIDs(contextWithSpan(t))
+	if traceID != "4bf92f3577b34da6a3ce929d0e0e4736" || spanID != "00f067aa0ba902b7" {
+		t.Errorf("unexpected IDs: %s %s", traceID, spanID)
+	}
+	if traceID, spanID := SpanIDs(context.Background()); traceID != "" || spanID != "" {
+		t.Errorf("expected empty IDs without a span, got %q %q", traceID, spanID)
+	}
+}
+
+func TestSpanIDsWithMiddleware(t *testing.T) {
+	rec := logdottest.NewRecorder()
+
+	cfg := logdot.DefaultMiddlewareConfig()
+	cfg.Logger = rec.NewLogger("test-service")
+	cfg.CaptureTrace = true
+	cfg.TraceIDs = SpanIDs
+	handler := logdot.Middleware(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest("GET", "/api/users", nil).WithContext(contextWithSpan(t))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/users", nil))
+
+	logs := rec.Logs()
+	if len(logs) != 2 {
+		t.Fatalf("expected 2 logs, got %d", len(logs))
+	}
+	if logs[0].Tags["trace_id"] != "4bf92f3577b34da6a3ce929d0e0e4736" || logs[0].Tags["span_id"] != "00f067aa0ba902b7" {
+		t.Errorf("unexpected tags: %v", logs[0].Tags)
+	}
+	if _, ok := logs[1].Tags["trace_id"]; ok {
+		t.Errorf("expected no trace_id without a span, got %v", logs[1].Tags)
+	}
+}
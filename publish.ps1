@@ -1,30 +1,66 @@
 [CmdletBinding()]
 param(
-    [switch]$Bump
+    [switch]$Bump,
+    [ValidateSet("", "fiber", "otel", "grpc")]
+    [string]$Module = ""
 )
 
 $ErrorActionPreference = "Stop"
 Push-Location $PSScriptRoot
 
+$rootModule = "github.com/logdot-io/logdot-go"
+
 try {
+    $prefix = ""
+    if ($Module) { $prefix = "$Module/" }
+
     # Get the latest version tag
-    $latestTag = git describe --tags --abbrev=0 --match "v*" 2>$null
-    if (-not $latestTag) { $latestTag = "v0.0.0" }
-    $currentVersion = $latestTag.TrimStart('v')
+    $latestTag = git describe --tags --abbrev=0 --match "${prefix}v*" 2>$null
+    if (-not $latestTag) { $latestTag = "${prefix}v0.0.0" }
+    $currentVersion = $latestTag.Substring($prefix.Length).TrimStart('v')
 
     if ($Bump) {
         $parts = $currentVersion.Split('.')
         $newPatch = [int]$parts[2] + 1
         $newVersion = "$($parts[0]).$($parts[1]).$newPatch"
-        $tag = "v${newVersion}"
+        $tag = "${prefix}v${newVersion}"
 
-        Write-Host "Bumping version: v${currentVersion} -> ${tag}"
+        Write-Host "Bumping version: ${prefix}v${currentVersion} -> ${tag}"
     } else {
         $newVersion = $currentVersion
-        $tag = "v${newVersion}"
+        $tag = "${prefix}v${newVersion}"
         Write-Host "Publishing ${tag}..."
     }
 
+    if ($Module) {
+        # Nested modules keep a replace directive for local development, but
+        # users get the root version named in their require line, so it must
+        # be a released tag.
+        $rootTag = git describe --tags --abbrev=0 --match "v*" 2>$null
+        if (-not $rootTag) { throw "No root release tag found; publish the root module first" }
+
+        Set-Location $Module
+        $required = go list -m -f '{{.Version}}' $rootModule
+        if ($required -ne $rootTag) {
+            throw "$Module/go.mod requires $rootModule $required, but the latest root release is $rootTag. Run: go mod edit -require=$rootModule@$rootTag in $Module, commit, and retry."
+        }
+
+        Write-Host "Running tests against $rootModule ${rootTag}..."
+        Copy-Item go.mod go.release.mod
+        Copy-Item go.sum go.release.sum
+        try {
+            go mod edit -dropreplace="$rootModule" go.release.mod
+            if ($LASTEXITCODE -ne 0) { throw "go mod edit failed" }
+            go get -modfile=go.release.mod "$rootModule@$rootTag"
+            if ($LASTEXITCODE -ne 0) { throw "go get failed" }
+            go test -modfile=go.release.mod ./...
+            if ($LASTEXITCODE -ne 0) { throw "Tests against $rootTag failed" }
+        }
+        finally {
+            Remove-Item go.release.mod, go.release.sum -ErrorAction SilentlyContinue
+        }
+    }
+
     Write-Host "Running tests..."
     go test ./...
     if ($LASTEXITCODE -ne 0) { throw "Tests failed" }
@@ -43,7 +79,9 @@ try {
     git push origin $tag
     if ($LASTEXITCODE -ne 0) { throw "git push failed" }
 
-    Write-Host "Successfully published github.com/logdot-io/logdot-go ${tag}"
+    $modulePath = $rootModule
+    if ($Module) { $modulePath = "$rootModule/$Module" }
+    Write-Host "Successfully published $modulePath v${newVersion}"
     Write-Host "Module will be available on pkg.go.dev shortly."
 }
 finally {
@@ -4,36 +4,83 @@ set -euo pipefail
 SCRIPT_DIR="$(cd "$(dirname "${BASH_SOURCE[0]}")" && pwd)"
 cd "$SCRIPT_DIR"
 
+ROOT_MODULE="github.com/logdot-io/logdot-go"
 BUMP=false
+MODULE=""
 
-for arg in "$@"; do
-  case "$arg" in
+usage() {
+  echo "Usage: $0 [--bump] [--module fiber|otel|grpc]"
+  echo "  --bump    Bump patch version before publishing"
+  echo "  --module  Publish a nested module (tagged <module>/vX.Y.Z) instead of the root"
+  exit 1
+}
+
+while [ $# -gt 0 ]; do
+  case "$1" in
     --bump) BUMP=true ;;
-    *)
-      echo "Usage: $0 [--bump]"
-      echo "  --bump  Bump patch version before publishing"
-      exit 1
+    --module)
+      [ $# -ge 2 ] || usage
+      MODULE="${2%/}"
+      shift
       ;;
+    *) usage ;;
   esac
+  shift
 done
 
+PREFIX=""
+if [ -n "$MODULE" ]; then
+  if [ ! -f "$MODULE/go.mod" ]; then
+    echo "No go.mod in ${MODULE}/"
+    exit 1
+  fi
+  PREFIX="${MODULE}/"
+fi
+
 # Get the latest version tag
-LATEST_TAG=$(git describe --tags --abbrev=0 --match "v*" 2>/dev/null || echo "v0.0.0")
-CURRENT_VERSION="${LATEST_TAG#v}"
+LATEST_TAG=$(git describe --tags --abbrev=0 --match "${PREFIX}v*" 2>/dev/null || echo "${PREFIX}v0.0.0")
+CURRENT_VERSION="${LATEST_TAG#"${PREFIX}"v}"
 
 if [ "$BUMP" = true ]; then
   IFS='.' read -r MAJOR MINOR PATCH <<< "$CURRENT_VERSION"
   NEW_PATCH=$((PATCH + 1))
   NEW_VERSION="${MAJOR}.${MINOR}.${NEW_PATCH}"
-  TAG="v${NEW_VERSION}"
+  TAG="${PREFIX}v${NEW_VERSION}"
 
-  echo "Bumping version: v${CURRENT_VERSION} -> ${TAG}"
+  echo "Bumping version: ${PREFIX}v${CURRENT_VERSION} -> ${TAG}"
 else
   NEW_VERSION="$CURRENT_VERSION"
-  TAG="v${NEW_VERSION}"
+  TAG="${PREFIX}v${NEW_VERSION}"
   echo "Publishing ${TAG}..."
 fi
 
+if [ -n "$MODULE" ]; then
+  # Nested modules keep a replace directive for local development, but
+  # users get the root version named in their require line, so it must be
+  # a released tag.
+  ROOT_TAG=$(git describe --tags --abbrev=0 --match "v*" 2>/dev/null || true)
+  if [ -z "$ROOT_TAG" ]; then
+    echo "No root release tag found; publish the root module first."
+    exit 1
+  fi
+
+  cd "$MODULE"
+  REQUIRED=$(go list -m -f '{{.Version}}' "$ROOT_MODULE")
+  if [ "$REQUIRED" != "$ROOT_TAG" ]; then
+    echo "${MODULE}/go.mod requires ${ROOT_MODULE} ${REQUIRED}, but the latest root release is ${ROOT_TAG}."
+    echo "Run: (cd ${MODULE} && go mod edit -require=${ROOT_MODULE}@${ROOT_TAG}), commit, and retry."
+    exit 1
+  fi
+
+  echo "Running tests against ${ROOT_MODULE} ${ROOT_TAG}..."
+  cp go.mod go.release.mod
+  cp go.sum go.release.sum
+  trap 'rm -f go.release.mod go.release.sum' EXIT
+  go mod edit -dropreplace="$ROOT_MODULE" go.release.mod
+  go get -modfile=go.release.mod "${ROOT_MODULE}@${ROOT_TAG}"
+  go test -modfile=go.release.mod ./...
+fi
+
 echo "Running tests..."
 go test ./...
 
@@ -48,5 +95,5 @@ fi
 echo "Pushing tag ${TAG}..."
 git push origin "$TAG"
 
-echo "Successfully published github.com/logdot-io/logdot-go ${TAG}"
+echo "Successfully published ${ROOT_MODULE}${MODULE:+/$MODULE} ${TAG#"${PREFIX}"}"
 echo "Module will be available on pkg.go.dev shortly."
@@ -0,0 +1,127 @@
+package logdot
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RateCounter pre-aggregates a high-frequency count on the client and
+// sends it periodically instead of once per event. Create one with
+// BoundMetrics.RateCounter.
+type RateCounter struct {
+	bound    *BoundMetrics
+	name     string
+	unit     string
+	interval time.Duration
+	count    atomic.Int64
+
+	// mu serializes flushes; lastFlush is when the last count was sent
+	mu        sync.Mutex
+	lastFlush time.Time
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// RateCounter returns a counter that accumulates Inc/Add calls and, every
+// flushInterval, sends one batch request with two metrics: name, the count
+// since the last flush, tagged sample_rate:1 since every event is counted,
+// and name.rate, that count per second of elapsed time (per the metrics
+// client's Clock) since the last successful flush. Intervals without
+// events send nothing. Call Stop to flush the remainder
+// and end the background flushing. A non-positive flushInterval defaults
+// to 10 seconds.
+//
+// Example:
+//
+//	hits := client.RateCounter("cache.hits", "count", 10*time.Second)
+//	defer hits.Stop()
+//	hits.Inc() // on the hot path
+func (b *BoundMetrics) RateCounter(name, unit string, flushInterval time.Duration) *RateCounter {
+	if flushInterval <= 0 {
+		flushInterval = 10 * time.Second
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &RateCounter{
+		bound:     b,
+		name:      name,
+		unit:      b.resolveUnit(unit),
+		interval:  flushInterval,
+		lastFlush: b.http.clock.Now(),
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+	go c.run(ctx)
+	return c
+}
+
+// Inc adds one to the counter
+func (c *RateCounter) Inc() {
+	c.count.Add(1)
+}
+
+// Add adds n to the counter
+func (c *RateCounter) Add(n int64) {
+	c.count.Add(n)
+}
+
+// Flush sends the count accumulated since the last flush. On failure the
+// count is kept for the next flush.
+func (c *RateCounter) Flush(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := c.count.Swap(0)
+	if n == 0 {
+		return nil
+	}
+
+	now := c.bound.http.clock.Now()
+	tags := c.bound.formatTags(nil)
+	// The count is not sampled, so the backend should not scale it up
+	countTags := c.bound.formatTags(map[string]interface{}{"sample_rate": 1})
+	payload := BatchMetricsPayload{
+		EntityID: c.bound.entityID,
+		Metrics:  []BatchMetricEntry{{Name: c.name, Value: float64(n), Unit: c.unit, Tags: countTags}},
+	}
+	if elapsed := now.Sub(c.lastFlush); elapsed > 0 {
+		payload.Metrics = append(payload.Metrics, BatchMetricEntry{
+			Name: c.name + ".rate", Value: float64(n) / elapsed.Seconds(), Unit: c.unit + "/s", Tags: tags,
+		})
+	}
+
+	resp, _, err := c.bound.http.Post(ctx, baseMetricsURL+"/metrics/batch", payload)
+	if err == nil && !c.bound.http.accepted(resp.StatusCode) {
+		err = fmt.Errorf("rate counter flush failed with status %d", resp.StatusCode)
+	}
+	if err != nil {
+		c.count.Add(n)
+		return err
+	}
+	c.lastFlush = now
+	return nil
+}
+
+// Stop ends background flushing and sends any remaining count
+func (c *RateCounter) Stop() {
+	c.cancel()
+	<-c.done
+	c.Flush(context.Background()) //nolint:errcheck // best effort
+}
+
+func (c *RateCounter) run(ctx context.Context) {
+	defer close(c.done)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.bound.http.clock.After(c.interval):
+			c.Flush(ctx) //nolint:errcheck // retried on the next tick
+		}
+	}
+}
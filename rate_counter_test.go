@@ -0,0 +1,161 @@
+package logdot
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRateCounter(t *testing.T) {
+	var mu sync.Mutex
+	var payloads []BatchMetricsPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload BatchMetricsPayload
+		json.NewDecoder(r.Body).Decode(&payload)
+		mu.Lock()
+		payloads = append(payloads, payload)
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	metrics := NewMetrics("test_key")
+	redirectToServer(metrics.http, server)
+
+	counter := metrics.ForEntity("entity-1").RateCounter("cache.hits", "count", time.Hour)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				counter.Inc()
+			}
+		}()
+	}
+	wg.Wait()
+	counter.Add(24)
+	counter.Stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(payloads) != 1 {
+		t.Fatalf("Expected 1 flush, got %d", len(payloads))
+	}
+	p := payloads[0]
+	if p.EntityID != "entity-1" || len(p.Metrics) != 2 {
+		t.Fatalf("Unexpected payload: %+v", p)
+	}
+	if p.Metrics[0].Name != "cache.hits" || p.Metrics[0].Value != 1024 {
+		t.Errorf("Expected count 1024, got %+v", p.Metrics[0])
+	}
+	if !reflect.DeepEqual(p.Metrics[0].Tags, []string{"sample_rate:1"}) {
+		t.Errorf("Expected the count tagged with its sample rate, got %v", p.Metrics[0].Tags)
+	}
+	if p.Metrics[1].Name != "cache.hits.rate" || p.Metrics[1].Unit != "count/s" {
+		t.Errorf("Expected rate metric, got %+v", p.Metrics[1])
+	}
+}
+
+// manualClock is a Clock that only moves when the test advances it; its
+// After never fires
+type manualClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *manualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *manualClock) After(d time.Duration) <-chan time.Time { return nil }
+
+func (c *manualClock) advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+func TestRateCounterRateUsesElapsedTime(t *testing.T) {
+	var mu sync.Mutex
+	var rates []float64
+	fail := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload BatchMetricsPayload
+		json.NewDecoder(r.Body).Decode(&payload)
+		mu.Lock()
+		defer mu.Unlock()
+		if fail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		rates = append(rates, payload.Metrics[1].Value)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	clock := &manualClock{now: time.Unix(0, 0)}
+	metrics := NewMetrics("test_key", WithMetricsClock(clock), WithMetricsRetry(1, time.Millisecond, time.Millisecond))
+	redirectToServer(metrics.http, server)
+
+	counter := metrics.ForEntity("entity-1").RateCounter("cache.hits", "count", 10*time.Second)
+	defer counter.cancel()
+
+	counter.Add(40)
+	clock.advance(10 * time.Second)
+	if err := counter.Flush(context.Background()); err == nil {
+		t.Fatal("Expected flush to fail")
+	}
+
+	mu.Lock()
+	fail = false
+	mu.Unlock()
+	clock.advance(10 * time.Second)
+	if err := counter.Flush(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	counter.Add(5)
+	clock.advance(500 * time.Millisecond)
+	if err := counter.Flush(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(rates) != 2 || rates[0] != 2 || rates[1] != 10 {
+		t.Errorf("Expected rates [2 10] per second, got %v", rates)
+	}
+}
+
+func TestRateCounterKeepsCountOnFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	metrics := NewMetrics("test_key")
+	redirectToServer(metrics.http, server)
+
+	counter := metrics.ForEntity("entity-1").RateCounter("cache.hits", "count", time.Hour)
+	defer counter.cancel()
+	counter.Add(5)
+
+	if err := counter.Flush(context.Background()); err == nil {
+		t.Error("Expected flush to fail")
+	}
+	if got := counter.count.Load(); got != 5 {
+		t.Errorf("Expected count to be kept, got %d", got)
+	}
+	if err := (&RateCounter{}).Flush(context.Background()); err != nil {
+		t.Errorf("Expected empty flush to be a no-op, got %v", err)
+	}
+}
@@ -0,0 +1,63 @@
+package logdot
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+)
+
+// Recover logs a panic with its stack trace at error level and re-panics.
+// Defer it directly at the top of a function or goroutine:
+//
+//	go func() {
+//		defer logdot.Recover(logger)
+//		work()
+//	}()
+//
+// Queued error entries are flushed before the panic continues, since it
+// usually ends the process. A nil logger only re-panics.
+func Recover(logger *Logger) {
+	if p := recover(); p != nil {
+		logPanic(logger, p, debug.Stack())
+		if logger != nil {
+			flushPanic(logger)
+		}
+		panic(p)
+	}
+}
+
+// RecoverAndContinue is like Recover but swallows the panic, for worker
+// loops that must keep running. Defer it directly:
+//
+//	for job := range jobs {
+//		func() {
+//			defer logdot.RecoverAndContinue(logger)
+//			handle(job)
+//		}()
+//	}
+func RecoverAndContinue(logger *Logger) {
+	if p := recover(); p != nil {
+		logPanic(logger, p, debug.Stack())
+	}
+}
+
+// logPanic logs a recovered panic value. It never panics itself.
+func logPanic(logger *Logger, p interface{}, stack []byte) {
+	if logger == nil {
+		return
+	}
+	defer func() { recover() }() //nolint:errcheck // never crash
+
+	logger.Error(context.Background(), truncateMessage(fmt.Sprintf("panic: %v", p)), map[string]interface{}{ //nolint:errcheck // best effort
+		"panic":  truncateMessage(fmt.Sprint(p)),
+		"stack":  truncateMessage(string(stack)),
+		"source": "panic",
+	})
+}
+
+// flushPanic sends queued error entries, including the panic just logged
+func flushPanic(logger *Logger) {
+	defer func() { recover() }() //nolint:errcheck // never crash
+
+	logger.FlushLevel(context.Background(), LevelError) //nolint:errcheck // best effort
+}
@@ -0,0 +1,86 @@
+package logdot
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRecoverLogsAndRepanics(t *testing.T) {
+	var sent []LogEntry
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload BatchLogsPayload
+		json.NewDecoder(r.Body).Decode(&payload)
+		sent = append(sent, payload.Logs...)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	logger := NewLogger("test_api_key", "test-service")
+	redirectToServer(logger.http, server)
+	logger.BeginBatch()
+	logger.Debug(context.Background(), "before", nil)
+
+	var repanicked interface{}
+	func() {
+		defer func() { repanicked = recover() }()
+		defer Recover(logger)
+		panic("boom")
+	}()
+
+	if repanicked != "boom" {
+		t.Errorf("Expected the panic to continue, got %v", repanicked)
+	}
+	if len(sent) != 1 {
+		t.Fatalf("Expected the panic entry to be flushed, got %d entries", len(sent))
+	}
+	entry := sent[0]
+	if entry.Level != LevelError || entry.Message != "panic: boom" || entry.Tags["panic"] != "boom" {
+		t.Errorf("Unexpected panic entry: %+v", entry)
+	}
+	if stack, _ := entry.Tags["stack"].(string); !strings.Contains(stack, "TestRecoverLogsAndRepanics") {
+		t.Errorf("Expected stack trace of the panicking function, got %q", stack)
+	}
+	if logger.BatchSize() != 1 {
+		t.Errorf("Expected less severe entries to stay queued, got %d", logger.BatchSize())
+	}
+}
+
+func TestRecoverAndContinue(t *testing.T) {
+	logger := NewLogger("test_api_key", "test-service")
+	logger.BeginBatch()
+
+	for i := 0; i < 2; i++ {
+		func() {
+			defer RecoverAndContinue(logger)
+			panic("job failed")
+		}()
+	}
+
+	if logger.BatchSize() != 2 {
+		t.Fatalf("Expected 2 panic entries, got %d", logger.BatchSize())
+	}
+	if got := logger.batchQueue[0].Tags["source"]; got != "panic" {
+		t.Errorf("Expected source panic, got %v", got)
+	}
+}
+
+func TestRecoverWithoutPanic(t *testing.T) {
+	logger := NewLogger("test_api_key", "test-service")
+	logger.BeginBatch()
+
+	func() {
+		defer Recover(logger)
+	}()
+	func() {
+		defer RecoverAndContinue(nil)
+		panic("no logger")
+	}()
+
+	if logger.BatchSize() != 0 {
+		t.Errorf("Expected nothing logged, got %d", logger.BatchSize())
+	}
+}
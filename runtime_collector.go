@@ -0,0 +1,77 @@
+package logdot
+
+import (
+	"context"
+	"runtime"
+	"time"
+)
+
+// defaultRuntimeInterval is used when StartRuntimeCollector is given a
+// non-positive interval
+const defaultRuntimeInterval = 10 * time.Second
+
+// RuntimeCollector periodically sends Go runtime metrics. Create one with
+// Metrics.StartRuntimeCollector.
+type RuntimeCollector struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// StartRuntimeCollector sends go.goroutines, go.heap_alloc and go.gc_pause
+// (the most recent GC pause) for entityID every interval, in one batch
+// request per tick. It runs until ctx is done or Stop is called. A
+// non-positive interval defaults to 10 seconds.
+//
+// Example:
+//
+//	collector := metrics.StartRuntimeCollector(ctx, entity.ID, 30*time.Second)
+//	defer collector.Stop()
+func (m *Metrics) StartRuntimeCollector(ctx context.Context, entityID string, interval time.Duration) *RuntimeCollector {
+	if interval <= 0 {
+		interval = defaultRuntimeInterval
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	c := &RuntimeCollector{cancel: cancel, done: make(chan struct{})}
+	go c.run(ctx, m.ForEntity(entityID), interval)
+	return c
+}
+
+// Stop halts the collector and waits for an in-progress send to finish
+func (c *RuntimeCollector) Stop() {
+	c.cancel()
+	<-c.done
+}
+
+func (c *RuntimeCollector) run(ctx context.Context, bound *BoundMetrics, interval time.Duration) {
+	defer close(c.done)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-bound.http.clock.After(interval):
+			sendRuntimeMetrics(ctx, bound)
+		}
+	}
+}
+
+// sendRuntimeMetrics reads the runtime stats and sends them as one batch
+func sendRuntimeMetrics(ctx context.Context, bound *BoundMetrics) {
+	defer func() { recover() }() //nolint:errcheck // never crash
+
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+
+	var lastPause float64
+	if stats.NumGC > 0 {
+		lastPause = float64(stats.PauseNs[(stats.NumGC+255)%256]) / 1e6
+	}
+
+	bound.BeginMultiBatch()
+	bound.AddMetric("go.goroutines", float64(runtime.NumGoroutine()), "count", nil)
+	bound.AddMetric("go.heap_alloc", float64(stats.HeapAlloc), "bytes", nil)
+	bound.AddMetric("go.gc_pause", lastPause, "ms", nil)
+	bound.SendBatch(ctx)
+	bound.EndBatch()
+}
@@ -0,0 +1,99 @@
+package logdot
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRuntimeCollector(t *testing.T) {
+	var mu sync.Mutex
+	var names []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload BatchMetricsPayload
+		json.NewDecoder(r.Body).Decode(&payload)
+		mu.Lock()
+		if len(names) == 0 {
+			for _, m := range payload.Metrics {
+				names = append(names, m.Name)
+			}
+		}
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	metrics := NewMetrics("test_key")
+	redirectToServer(metrics.http, server)
+
+	collector := metrics.StartRuntimeCollector(context.Background(), "entity-1", 10*time.Millisecond)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		mu.Lock()
+		n := len(names)
+		mu.Unlock()
+		if n > 0 {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	collector.Stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(names) != 3 || names[0] != "go.goroutines" || names[1] != "go.heap_alloc" || names[2] != "go.gc_pause" {
+		t.Errorf("Expected runtime metrics, got %v", names)
+	}
+}
+
+func TestRuntimeCollectorStopsOnContextCancel(t *testing.T) {
+	metrics := NewMetrics("test_key")
+	ctx, cancel := context.WithCancel(context.Background())
+
+	collector := metrics.StartRuntimeCollector(ctx, "entity-1", time.Hour)
+	cancel()
+
+	select {
+	case <-collector.done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected collector to stop after context cancellation")
+	}
+	collector.Stop() // safe after the context is done
+}
+
+// tickClock is a Clock whose After fires only when the test sends on ticks
+type tickClock struct {
+	ticks chan time.Time
+}
+
+func (c tickClock) Now() time.Time { return time.Unix(0, 0) }
+
+func (c tickClock) After(d time.Duration) <-chan time.Time { return c.ticks }
+
+func TestRuntimeCollectorUsesClock(t *testing.T) {
+	sent := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent <- struct{}{}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	clock := tickClock{ticks: make(chan time.Time)}
+	metrics := NewMetrics("test_key", WithMetricsClock(clock))
+	redirectToServer(metrics.http, server)
+
+	collector := metrics.StartRuntimeCollector(context.Background(), "entity-1", time.Hour)
+	defer collector.Stop()
+
+	clock.ticks <- time.Time{}
+	select {
+	case <-sent:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected a send when the clock fires, not after the real interval")
+	}
+}
@@ -0,0 +1,149 @@
+package logdot
+
+import (
+	"context"
+	"time"
+)
+
+// Scope is a logger that buffers its entries for the duration of an
+// operation, for "log on failure": Flush sends them when the operation
+// fails and Discard drops them when it succeeds. It has the logging
+// methods of the Logger it came from, with the same context and
+// configuration, but not its batch or configuration methods.
+//
+// A scope captures every level regardless of the logger's minimum level,
+// since its entries are only sent on demand; a level set on the context
+// with WithLevel still applies.
+type Scope struct {
+	logger *Logger
+}
+
+// Scope returns a Scope that buffers entries until Flush or Discard. The
+// scope's batch is separate from the logger's and is not sent by
+// FlushAll.
+//
+// Example:
+//
+//	scope := logger.Scope()
+//	scope.Debug(ctx, "fetched rates", map[string]interface{}{"count": len(rates)})
+//	if err := reconcile(ctx, scope); err != nil {
+//		scope.Error(ctx, err.Error(), nil)
+//		scope.Flush(ctx)
+//		return err
+//	}
+//	scope.Discard()
+func (l *Logger) Scope() *Scope {
+	scoped := l.WithContext(nil)
+	scoped.minLevel = LevelDebug
+	scoped.batchMode = true
+	return &Scope{logger: scoped}
+}
+
+// Flush sends the buffered entries in one batch request. They stay
+// buffered if the send fails. The scope keeps buffering afterwards.
+func (s *Scope) Flush(ctx context.Context) error {
+	return s.logger.SendBatch(ctx)
+}
+
+// Discard drops the buffered entries. The scope keeps buffering
+// afterwards.
+func (s *Scope) Discard() {
+	s.logger.ClearBatch()
+}
+
+// BatchSize returns the number of buffered entries
+func (s *Scope) BatchSize() int {
+	return s.logger.BatchSize()
+}
+
+// Debug buffers a debug entry; see Logger.Debug
+func (s *Scope) Debug(ctx context.Context, message string, tags map[string]interface{}) error {
+	return s.logger.Debug(ctx, message, tags)
+}
+
+// Info buffers an info entry; see Logger.Info
+func (s *Scope) Info(ctx context.Context, message string, tags map[string]interface{}) error {
+	return s.logger.Info(ctx, message, tags)
+}
+
+// Warn buffers a warn entry; see Logger.Warn
+func (s *Scope) Warn(ctx context.Context, message string, tags map[string]interface{}) error {
+	return s.logger.Warn(ctx, message, tags)
+}
+
+// Error buffers an error entry; see Logger.Error
+func (s *Scope) Error(ctx context.Context, message string, tags map[string]interface{}) error {
+	return s.logger.Error(ctx, message, tags)
+}
+
+// Debugf buffers a formatted debug entry; see Logger.Debugf
+func (s *Scope) Debugf(ctx context.Context, format string, args ...interface{}) error {
+	return s.logger.Debugf(ctx, format, args...)
+}
+
+// Infof buffers a formatted info entry; see Logger.Infof
+func (s *Scope) Infof(ctx context.Context, format string, args ...interface{}) error {
+	return s.logger.Infof(ctx, format, args...)
+}
+
+// Warnf buffers a formatted warn entry; see Logger.Warnf
+func (s *Scope) Warnf(ctx context.Context, format string, args ...interface{}) error {
+	return s.logger.Warnf(ctx, format, args...)
+}
+
+// Errorf buffers a formatted error entry; see Logger.Errorf
+func (s *Scope) Errorf(ctx context.Context, format string, args ...interface{}) error {
+	return s.logger.Errorf(ctx, format, args...)
+}
+
+// Log buffers an entry at level; see Logger.Log
+func (s *Scope) Log(ctx context.Context, level LogLevel, message string, tags map[string]interface{}) error {
+	return s.logger.Log(ctx, level, message, tags)
+}
+
+// LogAt buffers an entry with an explicit timestamp; see Logger.LogAt
+func (s *Scope) LogAt(ctx context.Context, t time.Time, level LogLevel, message string, tags map[string]interface{}) error {
+	return s.logger.LogAt(ctx, t, level, message, tags)
+}
+
+// LogAttrs buffers an entry with typed attributes; see Logger.LogAttrs
+func (s *Scope) LogAttrs(ctx context.Context, level LogLevel, message string, attrs ...Attr) error {
+	return s.logger.LogAttrs(ctx, level, message, attrs...)
+}
+
+// LogTemplate buffers a templated entry; see Logger.LogTemplate
+func (s *Scope) LogTemplate(ctx context.Context, level LogLevel, template string, args ...interface{}) error {
+	return s.logger.LogTemplate(ctx, level, template, args...)
+}
+
+// LogIndexed buffers an entry with indexed tags and unindexed fields; see
+// Logger.LogIndexed
+func (s *Scope) LogIndexed(ctx context.Context, level LogLevel, message string, indexed, fields map[string]interface{}) error {
+	return s.logger.LogIndexed(ctx, level, message, indexed, fields)
+}
+
+// LogAll buffers one entry per message; see Logger.LogAll
+func (s *Scope) LogAll(ctx context.Context, level LogLevel, messages []string, tags map[string]interface{}) error {
+	return s.logger.LogAll(ctx, level, messages, tags)
+}
+
+// LogErrWithLevel buffers an entry for err at its classified level; see
+// Logger.LogErrWithLevel
+func (s *Scope) LogErrWithLevel(ctx context.Context, err error, tags map[string]interface{}) error {
+	return s.logger.LogErrWithLevel(ctx, err, tags)
+}
+
+// Event buffers a structured event; see Logger.Event
+func (s *Scope) Event(ctx context.Context, eventType string, payload map[string]interface{}) error {
+	return s.logger.Event(ctx, eventType, payload)
+}
+
+// Enabled reports whether an entry at level would be buffered
+func (s *Scope) Enabled(level LogLevel) bool {
+	return s.logger.Enabled(level)
+}
+
+// EnabledContext is like Enabled but honors a level set with WithLevel
+func (s *Scope) EnabledContext(ctx context.Context, level LogLevel) bool {
+	return s.logger.EnabledContext(ctx, level)
+}
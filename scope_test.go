@@ -0,0 +1,121 @@
+package logdot
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLoggerScopeFlush(t *testing.T) {
+	var sent []LogEntry
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload BatchLogsPayload
+		json.NewDecoder(r.Body).Decode(&payload)
+		sent = append(sent, payload.Logs...)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	logger := NewLogger("test_api_key", "test-service", WithLoggerMinLevel(LevelInfo)).
+		WithContext(map[string]interface{}{"job": "reconcile"})
+	redirectToServer(logger.http, server)
+
+	ctx := context.Background()
+	scope := logger.Scope()
+	scope.Debug(ctx, "fetched rates", nil)
+	scope.Error(ctx, "reconcile failed", nil)
+
+	if len(sent) != 0 {
+		t.Fatalf("Expected nothing sent before Flush, got %d entries", len(sent))
+	}
+	if scope.BatchSize() != 2 {
+		t.Fatalf("Expected 2 buffered entries including debug, got %d", scope.BatchSize())
+	}
+
+	if err := scope.Flush(ctx); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if len(sent) != 2 || sent[0].Message != "fetched rates" || sent[0].Tags["job"] != "reconcile" {
+		t.Errorf("Expected buffered entries with logger context, got %+v", sent)
+	}
+	if scope.BatchSize() != 0 {
+		t.Errorf("Expected buffer emptied after Flush, got %d", scope.BatchSize())
+	}
+}
+
+func TestLoggerScopeDiscard(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	logger := NewLogger("test_api_key", "test-service")
+	redirectToServer(logger.http, server)
+	logger.BeginBatch()
+
+	ctx := context.Background()
+	scope := logger.Scope()
+	scope.Debug(ctx, "step 1", nil)
+	scope.Discard()
+	scope.Debug(ctx, "step 2", nil)
+
+	if scope.BatchSize() != 1 {
+		t.Errorf("Expected scope to keep buffering after Discard, got %d", scope.BatchSize())
+	}
+	if err := logger.FlushAll(ctx); err != nil {
+		t.Fatalf("FlushAll failed: %v", err)
+	}
+	if requests != 0 || scope.BatchSize() != 1 {
+		t.Errorf("Expected FlushAll to leave the scope alone, got %d requests", requests)
+	}
+}
+
+func TestLoggerScopeFlushKeepsConcurrentEntries(t *testing.T) {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{}, 1)
+	var sent []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload BatchLogsPayload
+		json.NewDecoder(r.Body).Decode(&payload)
+		started <- struct{}{}
+		<-release
+		for _, entry := range payload.Logs {
+			sent = append(sent, entry.Message)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	logger := NewLogger("test_api_key", "test-service")
+	redirectToServer(logger.http, server)
+
+	ctx := context.Background()
+	scope := logger.Scope()
+	scope.Error(ctx, "reconcile failed", nil)
+
+	done := make(chan error, 1)
+	go func() { done <- scope.Flush(ctx) }()
+	<-started
+	scope.Info(ctx, "retrying", nil)
+	release <- struct{}{}
+	if err := <-done; err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if scope.BatchSize() != 1 {
+		t.Fatalf("Expected the entry logged during Flush to stay buffered, got %d", scope.BatchSize())
+	}
+
+	go func() { done <- scope.Flush(ctx) }()
+	<-started
+	release <- struct{}{}
+	if err := <-done; err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if len(sent) != 2 || sent[0] != "reconcile failed" || sent[1] != "retrying" {
+		t.Errorf("Expected each entry sent once, got %v", sent)
+	}
+}
@@ -2,7 +2,9 @@ package logdot
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
+	"net/http"
 	"runtime"
 	"sync"
 )
@@ -24,8 +26,16 @@ type SlogHandler struct {
 	level  slog.Leveler
 	attrs  []slog.Attr
 	group  string
+
+	metricExtractor SlogMetricExtractor
+	metrics         *slogMetricSender
 }
 
+// SlogMetricExtractor derives metrics from a slog record. Return nil when
+// the record carries no measurement. EntityID on returned entries is
+// ignored; metrics are sent for the entity given to WithSlogMetricExtractor.
+type SlogMetricExtractor func(record slog.Record) []MetricEntry
+
 // SlogHandlerOption configures a SlogHandler.
 type SlogHandlerOption func(*SlogHandler)
 
@@ -37,6 +47,37 @@ func WithSlogLevel(level slog.Leveler) SlogHandlerOption {
 	}
 }
 
+// WithSlogMetricExtractor sends metrics derived from log records, in
+// addition to forwarding the records themselves. Metrics are sent one at
+// a time by a background goroutine; when more than 1024 are waiting,
+// further metrics are dropped. Failures never affect log forwarding.
+//
+// Example:
+//
+//	extract := func(r slog.Record) []logdot.MetricEntry {
+//		var out []logdot.MetricEntry
+//		r.Attrs(func(a slog.Attr) bool {
+//			if a.Key == "duration_ms" {
+//				out = append(out, logdot.MetricEntry{Name: "duration", Value: a.Value.Float64(), Unit: "ms"})
+//			}
+//			return true
+//		})
+//		return out
+//	}
+//	h := logdot.NewSlogHandler(logger, logdot.WithSlogMetricExtractor(extract, metrics, entity.ID))
+func WithSlogMetricExtractor(extractor SlogMetricExtractor, metrics *Metrics, entityID string) SlogHandlerOption {
+	return func(h *SlogHandler) {
+		if extractor == nil || metrics == nil {
+			return
+		}
+		h.metricExtractor = extractor
+		h.metrics = &slogMetricSender{
+			bound: metrics.ForEntity(entityID),
+			queue: make(chan MetricEntry, slogMetricQueueSize),
+		}
+	}
+}
+
 // NewSlogHandler creates a slog.Handler that forwards records to LogDot.
 //
 // Example:
@@ -55,11 +96,21 @@ func NewSlogHandler(logger *Logger, opts ...SlogHandlerOption) *SlogHandler {
 }
 
 // Enabled reports whether the handler handles records at the given level.
-func (h *SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
-	return level >= h.level.Level()
+// Records the Logger would drop (see WithLoggerMinLevel and WithLevel) are
+// reported as disabled so slog skips building them, unless a metric
+// extractor still needs to see them.
+func (h *SlogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	if level < h.level.Level() {
+		return false
+	}
+	if h.metricExtractor != nil {
+		return true
+	}
+	return h.logger.EnabledContext(ctx, mapSlogLevel(level))
 }
 
-// Handle processes a log record by forwarding it to LogDot.
+// Handle processes a log record by forwarding it to LogDot with the
+// record's context.
 func (h *SlogHandler) Handle(ctx context.Context, record slog.Record) error {
 	// Goroutine-based recursion guard: prevent LogDot's HTTP calls
 	// from triggering slog → LogDot → slog infinite loops.
@@ -88,21 +139,79 @@ func (h *SlogHandler) Handle(ctx context.Context, record slog.Record) error {
 		return true
 	})
 
-	bgCtx := context.Background()
+	// Forward the record's context so context extractors, level
+	// overrides and cancellation apply
+	if ctx == nil {
+		ctx = context.Background()
+	}
 	switch level {
 	case LevelDebug:
-		h.logger.Debug(bgCtx, message, tags)
+		h.logger.Debug(ctx, message, tags)
 	case LevelWarn:
-		h.logger.Warn(bgCtx, message, tags)
+		h.logger.Warn(ctx, message, tags)
 	case LevelError:
-		h.logger.Error(bgCtx, message, tags)
+		h.logger.Error(ctx, message, tags)
 	default:
-		h.logger.Info(bgCtx, message, tags)
+		h.logger.Info(ctx, message, tags)
+	}
+
+	if h.metricExtractor != nil {
+		h.emitMetrics(record)
 	}
 
 	return nil
 }
 
+// emitMetrics extracts metrics from the record and queues them for the
+// background sender so slow or failing metric sends never hold up logging.
+func (h *SlogHandler) emitMetrics(record slog.Record) {
+	entries := h.metricExtractor(record)
+	if len(entries) == 0 {
+		return
+	}
+	h.metrics.enqueue(entries)
+}
+
+// slogMetricQueueSize bounds the extracted metrics waiting to be sent
+const slogMetricQueueSize = 1024
+
+// slogMetricSender sends extracted metrics from one background goroutine,
+// shared by a handler and the handlers derived from it
+type slogMetricSender struct {
+	bound *BoundMetrics
+	queue chan MetricEntry
+	start sync.Once
+}
+
+// enqueue queues entries without blocking, dropping those that don't fit.
+// The sending goroutine is started on first use.
+func (s *slogMetricSender) enqueue(entries []MetricEntry) {
+	s.start.Do(func() { go s.run() })
+	for _, entry := range entries {
+		select {
+		case s.queue <- entry:
+		default:
+			if s.bound.debug {
+				fmt.Printf("[LogDotMetrics] Slog metric queue full, dropped %s\n", entry.Name)
+			}
+		}
+	}
+}
+
+func (s *slogMetricSender) run() {
+	for entry := range s.queue {
+		s.send(entry)
+	}
+}
+
+func (s *slogMetricSender) send(entry MetricEntry) {
+	defer func() { recover() }() //nolint:errcheck // never crash
+
+	entry.EntityID = s.bound.EntityID()
+	entry.Unit = s.bound.resolveUnit(entry.Unit)
+	s.bound.sendEntry(context.Background(), entry)
+}
+
 // WithAttrs returns a new handler with the given attributes added.
 func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
 	newAttrs := make([]slog.Attr, len(h.attrs), len(h.attrs)+len(attrs))
@@ -110,10 +219,12 @@ func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
 	newAttrs = append(newAttrs, attrs...)
 
 	return &SlogHandler{
-		logger: h.logger,
-		level:  h.level,
-		attrs:  newAttrs,
-		group:  h.group,
+		logger:          h.logger,
+		level:           h.level,
+		attrs:           newAttrs,
+		group:           h.group,
+		metricExtractor: h.metricExtractor,
+		metrics:         h.metrics,
 	}
 }
 
@@ -133,10 +244,12 @@ func (h *SlogHandler) WithGroup(name string) slog.Handler {
 	copy(newAttrs, h.attrs)
 
 	return &SlogHandler{
-		logger: h.logger,
-		level:  h.level,
-		attrs:  newAttrs,
-		group:  newGroup,
+		logger:          h.logger,
+		level:           h.level,
+		attrs:           newAttrs,
+		group:           newGroup,
+		metricExtractor: h.metricExtractor,
+		metrics:         h.metrics,
 	}
 }
 
@@ -214,3 +327,33 @@ func SetSlogCapture(logger *Logger, opts ...SlogHandlerOption) {
 	slog.SetDefault(slog.New(NewSlogHandler(logger, opts...)))
 }
 
+// RequestSlogHandler returns a SlogHandler bound to the request's logger,
+// as attached by Middleware, so slog output inside a handler carries the
+// request's request_id, http_method and http_path tags. Without a request
+// logger it falls back to the default logger (see SetDefault); if neither
+// is set, the returned handler discards every record.
+//
+// Example:
+//
+//	func handler(w http.ResponseWriter, r *http.Request) {
+//		log := slog.New(logdot.RequestSlogHandler(r))
+//		log.InfoContext(r.Context(), "loading cart", "cart_id", cartID)
+//	}
+func RequestSlogHandler(r *http.Request, opts ...SlogHandlerOption) slog.Handler {
+	logger := FromContext(r.Context())
+	if logger == nil {
+		logger = Default()
+	}
+	if logger == nil {
+		return discardSlogHandler{}
+	}
+	return NewSlogHandler(logger, opts...)
+}
+
+// discardSlogHandler drops every record
+type discardSlogHandler struct{}
+
+func (discardSlogHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardSlogHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardSlogHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardSlogHandler) WithGroup(string) slog.Handler           { return h }
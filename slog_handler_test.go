@@ -2,9 +2,14 @@ package logdot
 
 import (
 	"context"
+	"encoding/json"
 	"log/slog"
+	"net/http"
+	"net/http/httptest"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func newTestSlogHandler(opts ...SlogHandlerOption) (*SlogHandler, *Logger) {
@@ -95,9 +100,9 @@ func TestSlogHandlerIncludesAttrs(t *testing.T) {
 	if !ok {
 		t.Error("expected user_id tag")
 	}
-	// slog stores int as int64
-	if v, ok := uid.(int64); !ok || v != 42 {
-		t.Errorf("expected user_id 42 (int64), got %v (%T)", uid, uid)
+	// slog stores int as int64; tags normalize integers to int
+	if v, ok := uid.(int); !ok || v != 42 {
+		t.Errorf("expected user_id 42 (int), got %v (%T)", uid, uid)
 	}
 	if tags["action"] != "login" {
 		t.Errorf("expected action 'login', got %v", tags["action"])
@@ -180,6 +185,28 @@ func TestSlogHandlerEnabled(t *testing.T) {
 	}
 }
 
+func TestSlogHandlerEnabledConsultsLogger(t *testing.T) {
+	logger := NewLogger("test_key", "test-service", WithLoggerMinLevel(LevelWarn))
+	logger.BeginBatch()
+	h := NewSlogHandler(logger)
+
+	ctx := context.Background()
+	if h.Enabled(ctx, slog.LevelInfo) {
+		t.Error("expected info to be disabled when the logger drops it")
+	}
+	if !h.Enabled(ctx, slog.LevelError) {
+		t.Error("expected error to be enabled")
+	}
+	if !h.Enabled(WithLevel(ctx, LevelDebug), slog.LevelDebug) {
+		t.Error("expected a context level override to enable debug")
+	}
+
+	slog.New(h).Info("dropped")
+	if logger.BatchSize() != 0 {
+		t.Errorf("expected no entries, got %d", logger.BatchSize())
+	}
+}
+
 func TestSlogHandlerLevelFiltering(t *testing.T) {
 	h, logger := newTestSlogHandler(WithSlogLevel(slog.LevelWarn))
 	slogLogger := slog.New(h)
@@ -285,3 +312,176 @@ func TestSetSlogCapture(t *testing.T) {
 		t.Fatalf("expected 1 log entry after SetSlogCapture, got %d", logger.BatchSize())
 	}
 }
+
+func TestSlogHandlerNormalizesNumbers(t *testing.T) {
+	h, logger := newTestSlogHandler()
+	slogLogger := slog.New(h)
+	slogLogger.Info("numbers", "count", uint8(3), "whole", 12.0, "ratio", 0.5)
+
+	tags := logger.batchQueue[0].Tags
+	if v, ok := tags["count"].(int); !ok || v != 3 {
+		t.Errorf("expected count 3 (int), got %v (%T)", tags["count"], tags["count"])
+	}
+	if v, ok := tags["whole"].(int); !ok || v != 12 {
+		t.Errorf("expected whole 12 (int), got %v (%T)", tags["whole"], tags["whole"])
+	}
+	if v, ok := tags["ratio"].(float64); !ok || v != 0.5 {
+		t.Errorf("expected ratio 0.5 (float64), got %v (%T)", tags["ratio"], tags["ratio"])
+	}
+}
+
+func TestSlogHandlerMetricExtractor(t *testing.T) {
+	received := make(chan MetricEntry, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var entry MetricEntry
+		json.NewDecoder(r.Body).Decode(&entry)
+		received <- entry
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	metrics := NewMetrics("test_key")
+	redirectToServer(metrics.http, server)
+
+	extract := func(r slog.Record) []MetricEntry {
+		var out []MetricEntry
+		r.Attrs(func(a slog.Attr) bool {
+			if a.Key == "duration_ms" {
+				out = append(out, MetricEntry{Name: "request.duration", Value: a.Value.Float64(), Unit: "ms"})
+			}
+			return true
+		})
+		return out
+	}
+
+	h, logger := newTestSlogHandler(WithSlogMetricExtractor(extract, metrics, "entity-1"))
+	slogLogger := slog.New(h).With("service", "api")
+	slogLogger.Info("no measurement")
+	slogLogger.Info("request done", "duration_ms", 123.5)
+
+	if logger.BatchSize() != 2 {
+		t.Fatalf("expected 2 log entries, got %d", logger.BatchSize())
+	}
+
+	select {
+	case entry := <-received:
+		if entry.Name != "request.duration" || entry.Value != 123.5 || entry.EntityID != "entity-1" {
+			t.Errorf("unexpected metric: %+v", entry)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected a metric to be sent")
+	}
+}
+
+func TestSlogHandlerMetricQueueIsBounded(t *testing.T) {
+	release := make(chan struct{})
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	defer close(release)
+
+	metrics := NewMetrics("test_key", WithMetricsRetry(1, time.Millisecond, time.Millisecond))
+	redirectToServer(metrics.http, server)
+
+	extract := func(r slog.Record) []MetricEntry {
+		return []MetricEntry{{Name: "jobs", Value: 1, Unit: "count"}}
+	}
+	h, _ := newTestSlogHandler(WithSlogMetricExtractor(extract, metrics, "entity-1"))
+	slogLogger := slog.New(h)
+
+	// Wait for the worker to block on the first send so the rest fill the queue
+	slogLogger.Info("job done")
+	for deadline := time.Now().Add(5 * time.Second); requests.Load() == 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the first metric to be sent")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 2*slogMetricQueueSize; i++ {
+			slogLogger.Info("job done")
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected logging not to block on a full metric queue")
+	}
+
+	if n := len(h.metrics.queue); n != slogMetricQueueSize {
+		t.Errorf("expected the queue to hold %d metrics, got %d", slogMetricQueueSize, n)
+	}
+	if n := requests.Load(); n > 1 {
+		t.Errorf("expected metrics sent one at a time, got %d concurrent requests", n)
+	}
+}
+
+type slogTraceKey struct{}
+
+func TestSlogHandlerForwardsContext(t *testing.T) {
+	logger := NewLogger("test_key", "test-service",
+		WithLoggerMinLevel(LevelWarn),
+		WithLoggerContextExtractor(func(ctx context.Context) map[string]interface{} {
+			if id, ok := ctx.Value(slogTraceKey{}).(string); ok {
+				return map[string]interface{}{"trace_id": id}
+			}
+			return nil
+		}),
+	)
+	logger.BeginBatch()
+	slogLogger := slog.New(NewSlogHandler(logger))
+
+	ctx := context.WithValue(context.Background(), slogTraceKey{}, "trace-1")
+	slogLogger.WarnContext(ctx, "slow query")
+	slogLogger.DebugContext(WithLevel(ctx, LevelDebug), "verbose request")
+
+	if logger.BatchSize() != 2 {
+		t.Fatalf("expected 2 log entries, got %d", logger.BatchSize())
+	}
+	if logger.batchQueue[0].Tags["trace_id"] != "trace-1" {
+		t.Errorf("expected trace_id from context, got %v", logger.batchQueue[0].Tags)
+	}
+}
+
+func TestRequestSlogHandler(t *testing.T) {
+	logger := NewLogger("test_key", "test-service")
+	reqLogger := logger.WithContext(map[string]interface{}{"request_id": "req-42"})
+	reqLogger.BeginBatch()
+
+	req := httptest.NewRequest("GET", "/cart", nil)
+	req = req.WithContext(NewContext(req.Context(), reqLogger))
+
+	slog.New(RequestSlogHandler(req)).InfoContext(req.Context(), "loading cart", "cart_id", 7)
+
+	if reqLogger.BatchSize() != 1 {
+		t.Fatalf("expected 1 log entry on the request logger, got %d", reqLogger.BatchSize())
+	}
+	tags := reqLogger.batchQueue[0].Tags
+	if tags["request_id"] != "req-42" || tags["cart_id"] != 7 {
+		t.Errorf("expected request_id and cart_id tags, got %v", tags)
+	}
+}
+
+func TestRequestSlogHandlerWithoutLogger(t *testing.T) {
+	h := RequestSlogHandler(httptest.NewRequest("GET", "/", nil))
+	if h.Enabled(context.Background(), slog.LevelError) {
+		t.Error("expected handler without a logger to be disabled")
+	}
+
+	logger := NewLogger("test_key", "test-service")
+	logger.BeginBatch()
+	SetDefault(logger)
+	defer SetDefault(nil)
+
+	slog.New(RequestSlogHandler(httptest.NewRequest("GET", "/", nil))).Info("fallback")
+	if logger.BatchSize() != 1 {
+		t.Errorf("expected record sent to the default logger, got %d entries", logger.BatchSize())
+	}
+}
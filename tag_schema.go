@@ -0,0 +1,95 @@
+package logdot
+
+import (
+	"fmt"
+	"sort"
+)
+
+// TagType is the kind of value a tag may hold under a TagSchema
+type TagType int
+
+const (
+	TagString TagType = iota + 1
+	TagNumber
+	TagBool
+)
+
+// String returns the type's name
+func (t TagType) String() string {
+	switch t {
+	case TagString:
+		return "string"
+	case TagNumber:
+		return "number"
+	case TagBool:
+		return "bool"
+	default:
+		return "unknown"
+	}
+}
+
+// TagSchema describes the tags log entries must carry. It is checked
+// against each entry's final tags, after the logger's context and any
+// context extractor tags are merged in. Entries sent with NoTags are not
+// checked.
+type TagSchema struct {
+	// Required lists tag keys every entry must have
+	Required []string
+
+	// Types restricts the value type of the listed keys. Other keys are
+	// unconstrained.
+	Types map[string]TagType
+
+	// Strict rejects violating entries: the log call returns an error and
+	// nothing is sent. Otherwise tags of the wrong type are dropped, a
+	// missing required key is tolerated, and both are reported in debug
+	// output.
+	Strict bool
+}
+
+// check validates tags against the schema. It returns the tags without
+// the ones of the wrong type (a copy if any were dropped, so tags is never
+// modified) and a description of each violation, in a stable order.
+func (s *TagSchema) check(tags map[string]interface{}) (checked map[string]interface{}, dropped bool, problems []string) {
+	for _, key := range s.Required {
+		if _, ok := tags[key]; !ok {
+			problems = append(problems, fmt.Sprintf("missing required tag %q", key))
+		}
+	}
+
+	var wrong []string
+	for key, want := range s.Types {
+		v, ok := tags[key]
+		if !ok {
+			continue
+		}
+		if got := tagTypeOf(v); got != want {
+			wrong = append(wrong, key)
+			problems = append(problems, fmt.Sprintf("tag %q is %T, want %s", key, v, want))
+		}
+	}
+	if len(wrong) == 0 {
+		return tags, false, problems
+	}
+	sort.Strings(problems)
+
+	checked = copyTags(tags)
+	for _, key := range wrong {
+		delete(checked, key)
+	}
+	return checked, true, problems
+}
+
+// tagTypeOf classifies a tag value, returning 0 for unsupported types
+func tagTypeOf(v interface{}) TagType {
+	switch v.(type) {
+	case string:
+		return TagString
+	case bool:
+		return TagBool
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return TagNumber
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,65 @@
+package logdot
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestTagSchemaStrict(t *testing.T) {
+	logger := NewLogger("test_api_key", "test-service", WithLoggerTagSchema(TagSchema{
+		Required: []string{"team"},
+		Types:    map[string]TagType{"user_id": TagNumber},
+		Strict:   true,
+	}))
+	logger.BeginBatch()
+
+	ctx := context.Background()
+	err := logger.Info(ctx, "login", map[string]interface{}{"user_id": "42"})
+	if err == nil {
+		t.Fatal("Expected schema error")
+	}
+	if !strings.Contains(err.Error(), `missing required tag "team"`) || !strings.Contains(err.Error(), `tag "user_id" is string, want number`) {
+		t.Errorf("Expected descriptive error, got %v", err)
+	}
+	if logger.BatchSize() != 0 {
+		t.Errorf("Expected rejected entry not to be queued, got %d", logger.BatchSize())
+	}
+
+	if err := logger.Info(ctx, "login", map[string]interface{}{"team": "payments", "user_id": int64(42)}); err != nil {
+		t.Errorf("Expected valid entry to pass, got %v", err)
+	}
+}
+
+func TestTagSchemaLenientDropsWrongTypes(t *testing.T) {
+	logger := NewLogger("test_api_key", "test-service", WithLoggerTagSchema(TagSchema{
+		Required: []string{"team"},
+		Types:    map[string]TagType{"retry": TagBool},
+	}))
+	logger.BeginBatch()
+
+	tags := map[string]interface{}{"retry": "yes", "op": "sync"}
+	if err := logger.Info(context.Background(), "sync", tags); err != nil {
+		t.Fatalf("Expected lenient mode to accept the entry, got %v", err)
+	}
+
+	queued := logger.batchQueue[0].Tags
+	if _, ok := queued["retry"]; ok || queued["op"] != "sync" {
+		t.Errorf("Expected only the mistyped tag dropped, got %v", queued)
+	}
+	if tags["retry"] != "yes" {
+		t.Error("Expected caller's map to be left untouched")
+	}
+}
+
+func TestTagSchemaChecksContextTags(t *testing.T) {
+	logger := NewLogger("test_api_key", "test-service", WithLoggerTagSchema(TagSchema{
+		Required: []string{"team"},
+		Strict:   true,
+	})).WithContext(map[string]interface{}{"team": "payments"})
+	logger.BeginBatch()
+
+	if err := logger.Info(context.Background(), "ok", nil); err != nil {
+		t.Errorf("Expected required tag from context to satisfy the schema, got %v", err)
+	}
+}
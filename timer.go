@@ -0,0 +1,45 @@
+package logdot
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// Timer measures one operation and sends its duration as a metric. Create
+// one with BoundMetrics.Timer.
+type Timer struct {
+	bound   *BoundMetrics
+	name    string
+	tags    map[string]interface{}
+	start   time.Time
+	stopped atomic.Bool
+}
+
+// Timer starts timing an operation. Stop sends the elapsed time in
+// milliseconds as metric name with the given tags.
+//
+// Example:
+//
+//	timer := client.Timer("db.query", map[string]interface{}{"table": "users"})
+//	rows, err := db.QueryContext(ctx, query)
+//	timer.Stop(ctx)
+func (b *BoundMetrics) Timer(name string, tags map[string]interface{}) *Timer {
+	return &Timer{
+		bound: b,
+		name:  name,
+		tags:  tags,
+		start: b.http.clock.Now(),
+	}
+}
+
+// Stop sends the time elapsed since the timer was created. Only the first
+// call sends; later calls, including concurrent ones, do nothing and
+// return nil.
+func (t *Timer) Stop(ctx context.Context) error {
+	if !t.stopped.CompareAndSwap(false, true) {
+		return nil
+	}
+	elapsed := t.bound.http.clock.Now().Sub(t.start)
+	return t.bound.Send(ctx, t.name, float64(elapsed.Microseconds())/1000.0, "ms", t.tags)
+}
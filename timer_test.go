@@ -0,0 +1,56 @@
+package logdot
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTimerStop(t *testing.T) {
+	var mu sync.Mutex
+	var entries []MetricEntry
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var entry MetricEntry
+		json.NewDecoder(r.Body).Decode(&entry)
+		mu.Lock()
+		entries = append(entries, entry)
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	metrics := NewMetrics("test_key", WithMetricsClock(clock))
+	redirectToServer(metrics.http, server)
+
+	timer := metrics.ForEntity("entity-1").Timer("db.query", map[string]interface{}{"table": "users"})
+	clock.mu.Lock()
+	clock.now = clock.now.Add(1500 * time.Microsecond)
+	clock.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := timer.Stop(context.Background()); err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(entries) != 1 {
+		t.Fatalf("Expected a single metric, got %d", len(entries))
+	}
+	if entries[0].Name != "db.query" || entries[0].Value != 1.5 || entries[0].Unit != "ms" {
+		t.Errorf("Expected db.query 1.5ms, got %+v", entries[0])
+	}
+	if len(entries[0].Tags) != 1 || entries[0].Tags[0] != "table:users" {
+		t.Errorf("Expected table tag, got %v", entries[0].Tags)
+	}
+}
@@ -0,0 +1,61 @@
+package logdot
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// TraceIDsFunc returns the trace and span IDs of the span in ctx as hex
+// strings, or empty strings when ctx carries no span. The otel module's
+// logdototel.SpanIDs implements it for OpenTelemetry.
+type TraceIDsFunc func(ctx context.Context) (traceID, spanID string)
+
+// traceparentHeader carries the W3C trace context of an incoming request
+const traceparentHeader = "traceparent"
+
+// traceTags returns the trace_id and span_id tags for r, or nil when the
+// request has no trace. IDs come from traceIDs when set; otherwise the
+// trace ID is read from the traceparent header. The header's span ID
+// belongs to the caller, so span_id is only set from traceIDs.
+func traceTags(r *http.Request, traceIDs TraceIDsFunc) map[string]interface{} {
+	var traceID, spanID string
+	if traceIDs != nil {
+		traceID, spanID = traceIDs(r.Context())
+	} else {
+		traceID = traceIDFromHeader(r.Header.Get(traceparentHeader))
+	}
+	if !validHexID(traceID, 32) {
+		return nil
+	}
+
+	tags := map[string]interface{}{"trace_id": traceID}
+	if validHexID(spanID, 16) {
+		tags["span_id"] = spanID
+	}
+	return tags
+}
+
+// traceIDFromHeader returns the trace ID of a W3C traceparent header
+// ("version-traceid-parentid-flags"), or "" if it is malformed
+func traceIDFromHeader(header string) string {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return ""
+	}
+	return strings.ToLower(parts[1])
+}
+
+// validHexID reports whether id is n hex digits and not all zeros, which
+// the W3C spec reserves for "no trace"
+func validHexID(id string, n int) bool {
+	if len(id) != n || strings.Trim(id, "0") == "" {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
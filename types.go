@@ -1,7 +1,11 @@
 // Package logdot provides a client for the LogDot cloud logging and metrics service.
 package logdot
 
-import "time"
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
 
 // LogLevel represents log severity levels
 type LogLevel string
@@ -13,6 +17,20 @@ const (
 	LevelError LogLevel = "error"
 )
 
+// levelRank orders levels by severity. Unknown levels rank as info.
+func levelRank(level LogLevel) int {
+	switch level {
+	case LevelDebug:
+		return 0
+	case LevelWarn:
+		return 2
+	case LevelError:
+		return 3
+	default:
+		return 1
+	}
+}
+
 // LoggerConfig holds configuration for the logger
 type LoggerConfig struct {
 	APIKey         string
@@ -22,6 +40,108 @@ type LoggerConfig struct {
 	RetryBaseDelay time.Duration
 	RetryMaxDelay  time.Duration
 	Debug          bool
+	BatchID        bool
+	Clock          Clock
+	MinLevel       LogLevel
+	RetryDecider   RetryDecider
+	HTTPClient     *http.Client
+	Serializer     Serializer
+
+	// NoClientTimeout disables the HTTP client's Timeout so request
+	// deadlines come only from the context
+	NoClientTimeout bool
+
+	// MaxConcurrentSends bounds in-flight HTTP requests (0 = unbounded)
+	MaxConcurrentSends int
+
+	// DedupWindow collapses consecutive identical batched entries logged
+	// within this interval of each other (0 = disabled)
+	DedupWindow time.Duration
+
+	// OnSuccess is called after entries are acknowledged by the backend
+	OnSuccess func(entries []LogEntry, resp *APIResponse)
+
+	// FieldNames overrides the JSON keys of sent log entries
+	FieldNames FieldNameMap
+
+	// DebugRate caps SDK debug output lines per second (0 = unlimited)
+	DebugRate int
+
+	// ContextExtractor adds tags derived from each log call's context
+	ContextExtractor ContextExtractor
+
+	// ContextKeys lists context keys whose values are added as tags
+	ContextKeys []interface{}
+
+	// EmptyMessage replaces blank log messages
+	EmptyMessage string
+
+	// TagSchema, when set, is enforced on the tags of every entry
+	TagSchema *TagSchema
+
+	// StripANSI removes ANSI escape sequences from log messages
+	StripANSI bool
+
+	// ErrorClassifiers choose the level used by LogErrWithLevel
+	ErrorClassifiers []ErrorClassifier
+
+	// Transport replaces the default transport; ignored when HTTPClient
+	// is set
+	Transport *http.Transport
+
+	// MaxQueueSize caps the batch queue (0 = unbounded). On overflow the
+	// lowest-severity entries are dropped first.
+	MaxQueueSize int
+
+	// CompactBatches moves tags shared by every entry of a batch into
+	// BatchLogsPayload.CommonTags
+	CompactBatches bool
+
+	// Name is a logical logger name (e.g. "payments.worker") sent as the
+	// "logger" tag on every entry
+	Name string
+
+	// DialTimeout and ResponseHeaderTimeout bound connection setup and
+	// the wait for response headers, separately from Timeout (0 = the
+	// transport's default); ignored when HTTPClient is set
+	DialTimeout           time.Duration
+	ResponseHeaderTimeout time.Duration
+
+	// StreamBatches is the batch size from which batch payloads are
+	// encoded while being sent rather than up front (0 = never)
+	StreamBatches int
+
+	// HeartbeatInterval enables a periodic heartbeat log (0 = disabled)
+	HeartbeatInterval time.Duration
+
+	// HeartbeatMetrics, when set, also receives each heartbeat as a
+	// heartbeat.uptime metric
+	HeartbeatMetrics *BoundMetrics
+
+	// QueueWarnThreshold warns when the batch queue reaches this many
+	// unsent entries (0 = never)
+	QueueWarnThreshold int
+
+	// OnQueueWarning receives queue warnings instead of stderr
+	OnQueueWarning func(size int)
+
+	// AuthHeader and AuthScheme override how the API key is sent
+	// (default "Authorization: Bearer <key>"); AuthScheme may be empty
+	AuthHeader string
+	AuthScheme string
+
+	// AutoEnv tags every log with detected environment details
+	AutoEnv bool
+
+	// AutoEnvSkip lists AutoEnv detectors not to run
+	AutoEnvSkip []EnvDetector
+
+	// ValidatePayload checks outgoing log payloads in debug mode
+	ValidatePayload bool
+
+	// SuccessCodes are the HTTP statuses that count as a successful send
+	// (default 200 and 201)
+	SuccessCodes []int
 }
 
 // MetricsConfig holds configuration for the metrics client
@@ -32,6 +152,35 @@ type MetricsConfig struct {
 	RetryBaseDelay time.Duration
 	RetryMaxDelay  time.Duration
 	Debug          bool
+	Clock          Clock
+	HTTPClient     *http.Client
+	DefaultUnit    string
+	MaxTagLength   int
+
+	// DefaultTags are added to every metric; per-call tags take precedence
+	DefaultTags map[string]interface{}
+
+	// BatchShards splits each client's batch queue into this many
+	// independently locked shards (0 or 1 = a single queue)
+	BatchShards int
+
+	// StrictEntityNames makes entity lookups fail when a name is shared
+	// by several entities
+	StrictEntityNames bool
+
+	// AutoFlushSize is the queue size at which BoundMetrics.AddWithAutoFlush
+	// sends the batch (0 = never)
+	AutoFlushSize int
+
+	// UnitAliases maps unit spellings to the canonical unit sent instead
+	UnitAliases map[string]string
+
+	// Coalesce pre-aggregates identical metrics in multi-metric batches
+	Coalesce CoalesceMode
+
+	// SuccessCodes are the HTTP statuses that count as a successful send
+	// (default 200 and 201)
+	SuccessCodes []int
 }
 
 // Config is deprecated - use LoggerConfig or MetricsConfig instead
@@ -54,6 +203,12 @@ type Entity struct {
 	Description string
 }
 
+// GetOrCreateEntityResult is returned by Metrics.GetOrCreateEntityResult
+type GetOrCreateEntityResult struct {
+	Entity  *Entity
+	Created bool // true if the entity did not exist and was created
+}
+
 // CreateEntityOptions holds options for creating an entity
 type CreateEntityOptions struct {
 	Name        string
@@ -67,6 +222,49 @@ type LogEntry struct {
 	Level    LogLevel               `json:"severity"`
 	Hostname string                 `json:"hostname,omitempty"`
 	Tags     map[string]interface{} `json:"tags,omitempty"`
+
+	// Fields holds high-cardinality values that are stored but not
+	// indexed. Tags are indexed. See Logger.LogIndexed.
+	Fields map[string]interface{} `json:"fields,omitempty"`
+
+	// Timestamp is the event time set by the caller. When nil the backend
+	// uses the time the entry was received. See Logger.LogAt.
+	Timestamp *time.Time `json:"timestamp,omitempty"`
+}
+
+// MarshalJSON encodes the entry, writing an explicit empty "tags" object
+// for entries sent with NoTags, which omitempty would otherwise drop
+func (e LogEntry) MarshalJSON() ([]byte, error) {
+	type plain LogEntry
+	if !isNoTags(e.Tags) {
+		return json.Marshal(plain(e))
+	}
+	return json.Marshal(struct {
+		plain
+		Tags map[string]interface{} `json:"tags"`
+	}{plain(e), e.Tags})
+}
+
+// FieldNameMap overrides the JSON keys used for log entries on the wire,
+// for backends that expect e.g. "msg" instead of "message". Empty fields
+// keep the default key.
+type FieldNameMap struct {
+	Message  string // default "message"
+	Severity string // default "severity"
+	Hostname string // default "hostname"
+	Tags     string // default "tags"
+	Fields   string // default "fields"
+}
+
+// QueryOptions filters logs returned by Logger.Query. Zero values are
+// not sent as filters.
+type QueryOptions struct {
+	Level    LogLevel
+	Hostname string
+	Since    time.Time
+	Until    time.Time
+	Search   string
+	Limit    int
 }
 
 // MetricEntry represents a single metric entry
@@ -78,10 +276,24 @@ type MetricEntry struct {
 	Tags     []string `json:"tags,omitempty"`
 }
 
+// RateLimitStatus is the rate limit reported by the most recent LogDot
+// response that carried X-RateLimit-* headers. Fields whose header was
+// absent are -1 (counts) or zero (Reset).
+type RateLimitStatus struct {
+	Limit     int
+	Remaining int
+	Reset     time.Time
+
+	// UpdatedAt is when the status was read; zero if no response has
+	// carried rate limit headers yet
+	UpdatedAt time.Time
+}
+
 // BatchLogsPayload for batch log transmission
 type BatchLogsPayload struct {
-	Hostname string     `json:"hostname"`
-	Logs     []LogEntry `json:"logs"`
+	Hostname   string                 `json:"hostname"`
+	CommonTags map[string]interface{} `json:"common_tags,omitempty"`
+	Logs       []LogEntry             `json:"logs"`
 }
 
 // BatchMetricsPayload for batch metric transmission
@@ -106,6 +318,12 @@ type EntityPayload struct {
 	Metadata    map[string]interface{} `json:"metadata,omitempty"`
 }
 
+// queryLogsResponse is the response body of the log query endpoint
+type queryLogsResponse struct {
+	Data   []LogEntry `json:"data"`
+	Status string     `json:"status"`
+}
+
 // APIResponse represents a generic API response
 type APIResponse struct {
 	Data struct {
@@ -115,3 +333,17 @@ type APIResponse struct {
 	} `json:"data"`
 	Status string `json:"status"`
 }
+
+// MetricResponse is the server's acknowledgement of a single metric, as
+// returned by BoundMetrics.SendWithResponse
+type MetricResponse struct {
+	Data struct {
+		ID    string  `json:"id"`
+		Name  string  `json:"name"`
+		Value float64 `json:"value"`
+		Unit  string  `json:"unit"`
+		// Timestamp is the server's receive time; zero if not reported
+		Timestamp time.Time `json:"timestamp"`
+	} `json:"data"`
+	Status string `json:"status"`
+}
@@ -0,0 +1,95 @@
+package logdot
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// validatePayloads reports whether outgoing payloads are checked with
+// checkPayload: WithLoggerValidatePayload is set and debug is on
+func (l *Logger) validatePayloads() bool {
+	return l.config.ValidatePayload && l.debug
+}
+
+// reportPayload validates payload and prints any violations in debug
+// output. The payload is sent either way.
+func (l *Logger) reportPayload(payload interface{}, batch bool) {
+	if problems := l.checkPayload(payload, batch); len(problems) > 0 {
+		l.debugLog("Payload validation: " + strings.Join(problems, "; "))
+	}
+}
+
+// checkPayload encodes payload as JSON and checks it against the shape
+// the logs API expects: a hostname, and for each entry a non-empty
+// message, a known severity and a valid timestamp if present. Keys follow
+// WithLoggerFieldNames.
+func (l *Logger) checkPayload(payload interface{}, batch bool) []string {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return []string{"marshal: " + err.Error()}
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		return []string{"payload is not a JSON object"}
+	}
+
+	keys := l.wireKeys()
+	var problems []string
+	if s, _ := decoded[keys.Hostname].(string); s == "" {
+		problems = append(problems, fmt.Sprintf("missing %s", keys.Hostname))
+	}
+	if !batch {
+		return append(problems, checkWireEntry(decoded, keys, "")...)
+	}
+
+	logs, ok := decoded["logs"].([]interface{})
+	if !ok || len(logs) == 0 {
+		return append(problems, "missing or empty logs")
+	}
+	for i, raw := range logs {
+		entry, ok := raw.(map[string]interface{})
+		if !ok {
+			problems = append(problems, fmt.Sprintf("logs[%d] is not an object", i))
+			continue
+		}
+		problems = append(problems, checkWireEntry(entry, keys, fmt.Sprintf("logs[%d].", i))...)
+	}
+	return problems
+}
+
+// checkWireEntry checks one decoded log entry; prefix locates it in the
+// payload for messages
+func checkWireEntry(entry map[string]interface{}, keys FieldNameMap, prefix string) []string {
+	var problems []string
+	if s, _ := entry[keys.Message].(string); strings.TrimSpace(s) == "" {
+		problems = append(problems, fmt.Sprintf("%s%s missing or empty", prefix, keys.Message))
+	}
+	switch severity, _ := entry[keys.Severity].(string); LogLevel(severity) {
+	case LevelDebug, LevelInfo, LevelWarn, LevelError:
+	default:
+		problems = append(problems, fmt.Sprintf("%s%s %q is not debug, info, warn or error", prefix, keys.Severity, severity))
+	}
+	if raw, ok := entry["timestamp"]; ok {
+		s, _ := raw.(string)
+		if _, err := time.Parse(time.RFC3339Nano, s); err != nil {
+			problems = append(problems, fmt.Sprintf("%stimestamp %v is not RFC 3339", prefix, raw))
+		}
+	}
+	return problems
+}
+
+// wireKeys returns the JSON keys used for log entries on the wire
+func (l *Logger) wireKeys() FieldNameMap {
+	if l.fieldNames != nil {
+		return *l.fieldNames
+	}
+	return FieldNameMap{
+		Message:  "message",
+		Severity: "severity",
+		Hostname: "hostname",
+		Tags:     "tags",
+		Fields:   "fields",
+	}
+}
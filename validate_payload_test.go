@@ -0,0 +1,60 @@
+package logdot
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckPayloadEntry(t *testing.T) {
+	logger := NewLogger("test_api_key", "test-service")
+
+	valid := logger.wireEntry(LogEntry{Message: "ok", Level: LevelInfo, Hostname: "test-service"})
+	if problems := logger.checkPayload(valid, false); len(problems) != 0 {
+		t.Errorf("Expected valid entry, got %v", problems)
+	}
+
+	invalid := logger.wireEntry(LogEntry{Message: " ", Level: "", Hostname: "test-service"})
+	problems := strings.Join(logger.checkPayload(invalid, false), "; ")
+	if !strings.Contains(problems, "message missing or empty") || !strings.Contains(problems, `severity ""`) {
+		t.Errorf("Expected message and severity violations, got %q", problems)
+	}
+}
+
+func TestCheckPayloadBatch(t *testing.T) {
+	logger := NewLogger("test_api_key", "test-service",
+		WithLoggerFieldNames(FieldNameMap{Message: "msg", Severity: "level"}))
+
+	ts := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	valid := logger.wireBatch([]LogEntry{
+		{Message: "one", Level: LevelWarn},
+		{Message: "two", Level: LevelError, Timestamp: &ts},
+	})
+	if problems := logger.checkPayload(valid, true); len(problems) != 0 {
+		t.Errorf("Expected valid batch, got %v", problems)
+	}
+
+	invalid := logger.wireBatch([]LogEntry{
+		{Message: "one", Level: LevelInfo},
+		{Message: "two", Level: "fatal"},
+	})
+	problems := logger.checkPayload(invalid, true)
+	if len(problems) != 1 || !strings.Contains(problems[0], `logs[1].level "fatal"`) {
+		t.Errorf("Expected one severity violation on logs[1], got %v", problems)
+	}
+
+	if problems := logger.checkPayload(logger.wireBatch(nil), true); len(problems) != 1 {
+		t.Errorf("Expected empty batch to be reported, got %v", problems)
+	}
+}
+
+func TestValidatePayloadRequiresDebug(t *testing.T) {
+	logger := NewLogger("test_api_key", "test-service", WithLoggerValidatePayload(true))
+	if logger.validatePayloads() {
+		t.Error("Expected validation off without debug mode")
+	}
+	logger.SetDebug(true)
+	if !logger.validatePayloads() {
+		t.Error("Expected validation on in debug mode")
+	}
+}
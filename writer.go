@@ -0,0 +1,81 @@
+package logdot
+
+import (
+	"context"
+	"io"
+	"log"
+	"strings"
+)
+
+// LogWriter is an io.Writer that sends each write to LogDot as one log
+// entry. Trailing newlines are trimmed and empty writes are skipped.
+// Writes never fail, so a slow or unreachable backend can't break the
+// code writing to it.
+type LogWriter struct {
+	logger *Logger
+	level  LogLevel
+	tags   map[string]interface{}
+}
+
+// NewWriter returns an io.Writer that forwards each write to logger at the
+// given level, tagged with source "writer".
+//
+// Example:
+//
+//	cmd.Stderr = logdot.NewWriter(logger, logdot.LevelWarn)
+func NewWriter(logger *Logger, level LogLevel) *LogWriter {
+	return &LogWriter{
+		logger: logger,
+		level:  level,
+		tags:   map[string]interface{}{"source": "writer"},
+	}
+}
+
+// Write sends p as a single log entry
+func (w *LogWriter) Write(p []byte) (int, error) {
+	defer func() { recover() }() //nolint:errcheck // never crash
+
+	message := strings.TrimRight(string(p), "\r\n")
+	if message == "" {
+		return len(p), nil
+	}
+
+	w.logger.Log(context.Background(), w.level, truncateMessage(message), w.tags)
+	return len(p), nil
+}
+
+// StdLogger returns a standard library *log.Logger whose output is
+// forwarded to LogDot at the given level, tagged with source "stdlog".
+// Use it for libraries that accept a *log.Logger, such as
+// http.Server.ErrorLog.
+//
+// Example:
+//
+//	legacy := logdot.StdLogger(logger, logdot.LevelInfo)
+//	legacy.Printf("processed %d jobs", n)
+func StdLogger(logger *Logger, level LogLevel) *log.Logger {
+	w := NewWriter(logger, level)
+	w.tags = map[string]interface{}{"source": "stdlog"}
+	return log.New(w, "", 0)
+}
+
+// ServerErrorLog returns a *log.Logger for http.Server.ErrorLog that
+// forwards the server's own errors (TLS handshake failures, malformed
+// requests, etc.) to LogDot at error level, tagged with source
+// "http_server". These errors never reach handlers or middleware.
+//
+// Example:
+//
+//	srv := &http.Server{
+//	    Addr:     ":8080",
+//	    Handler:  logdot.Middleware(config)(mux),
+//	    ErrorLog: logdot.ServerErrorLog(logger),
+//	}
+func ServerErrorLog(logger *Logger) *log.Logger {
+	w := NewWriter(logger, LevelError)
+	w.tags = map[string]interface{}{"source": "http_server"}
+	return log.New(w, "", 0)
+}
+
+// Verify interface compliance at compile time.
+var _ io.Writer = (*LogWriter)(nil)
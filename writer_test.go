@@ -0,0 +1,64 @@
+package logdot
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestWriterForwardsWrites(t *testing.T) {
+	logger := NewLogger("test_key", "test-service")
+	logger.BeginBatch()
+
+	w := NewWriter(logger, LevelWarn)
+	fmt.Fprintln(w, "disk almost full")
+	w.Write([]byte("\n"))
+
+	if logger.BatchSize() != 1 {
+		t.Fatalf("expected 1 log entry, got %d", logger.BatchSize())
+	}
+	entry := logger.batchQueue[0]
+	if entry.Message != "disk almost full" || entry.Level != LevelWarn {
+		t.Errorf("unexpected entry: %+v", entry)
+	}
+	if entry.Tags["source"] != "writer" {
+		t.Errorf("expected source writer, got %v", entry.Tags["source"])
+	}
+}
+
+func TestStdLogger(t *testing.T) {
+	logger := NewLogger("test_key", "test-service")
+	logger.BeginBatch()
+
+	std := StdLogger(logger, LevelError)
+	std.Printf("failed after %d attempts", 3)
+
+	if logger.BatchSize() != 1 {
+		t.Fatalf("expected 1 log entry, got %d", logger.BatchSize())
+	}
+	entry := logger.batchQueue[0]
+	if entry.Message != "failed after 3 attempts" || entry.Level != LevelError {
+		t.Errorf("unexpected entry: %+v", entry)
+	}
+	if entry.Tags["source"] != "stdlog" {
+		t.Errorf("expected source stdlog, got %v", entry.Tags["source"])
+	}
+}
+
+func TestServerErrorLog(t *testing.T) {
+	logger := NewLogger("test_key", "test-service")
+	logger.BeginBatch()
+
+	errLog := ServerErrorLog(logger)
+	errLog.Printf("http: TLS handshake error from %s: EOF", "10.0.0.1:5000")
+
+	if logger.BatchSize() != 1 {
+		t.Fatalf("expected 1 log entry, got %d", logger.BatchSize())
+	}
+	entry := logger.batchQueue[0]
+	if entry.Level != LevelError {
+		t.Errorf("expected error level, got %s", entry.Level)
+	}
+	if entry.Tags["source"] != "http_server" {
+		t.Errorf("expected source http_server, got %v", entry.Tags["source"])
+	}
+}